@@ -1,12 +1,29 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"net/http"
+	"os"
+	"os/signal"
+	"slices"
+	"syscall"
+	"time"
 
 	docs "github.com/PharmaKart/gateway-svc/docs"
+	"github.com/PharmaKart/gateway-svc/internal/auth"
+	"github.com/PharmaKart/gateway-svc/internal/eventstore"
 	"github.com/PharmaKart/gateway-svc/internal/grpc"
+	"github.com/PharmaKart/gateway-svc/internal/handlers"
+	"github.com/PharmaKart/gateway-svc/internal/middleware"
+	"github.com/PharmaKart/gateway-svc/internal/payments"
+	"github.com/PharmaKart/gateway-svc/internal/ratelimit"
+	"github.com/PharmaKart/gateway-svc/internal/revocation"
 	"github.com/PharmaKart/gateway-svc/internal/routes"
+	"github.com/PharmaKart/gateway-svc/internal/tracing"
+	"github.com/PharmaKart/gateway-svc/internal/webhookqueue"
 	"github.com/PharmaKart/gateway-svc/pkg/config"
+	"github.com/PharmaKart/gateway-svc/pkg/metrics"
 	"github.com/PharmaKart/gateway-svc/pkg/utils"
 	"github.com/gin-gonic/gin"
 	swaggerFiles "github.com/swaggo/files"
@@ -29,14 +46,48 @@ func SwaggerAuthMiddleware() gin.HandlerFunc {
 }
 
 func main() {
-	// Initialize logger
-	utils.InitLogger()
-
 	// Load configuration
 	cfg := config.LoadConfig()
 
+	// Initialize logger
+	utils.InitLogger(cfg)
+
+	// Select the error format used when clients do not negotiate one
+	utils.InitErrorFormat(cfg.ErrorFormat, cfg.ProblemTypeBaseURI)
+
+	// Export traces over OTLP when a collector is configured
+	shutdownTracing, err := tracing.Init(context.Background(), cfg)
+	if err != nil {
+		utils.Logger.Fatal("Failed to initialize tracing", map[string]interface{}{
+			"error": err,
+		})
+	}
+
+	// Retry idempotent calls that fail with transient errors
+	retryPolicy := grpc.RetryPolicy{
+		MaxAttempts:       cfg.RetryMaxAttempts,
+		InitialBackoff:    cfg.RetryInitialBackoff,
+		MaxBackoff:        cfg.RetryMaxBackoff,
+		BackoffMultiplier: cfg.RetryBackoffMultiplier,
+		RetryableCodes:    grpc.ParseCodes(cfg.RetryableCodes),
+	}
+
+	// Fail fast while a downstream service is unhealthy
+	breakerOptions := grpc.CircuitBreakerOptions{
+		FailureThreshold: cfg.CircuitBreakerFailureThreshold,
+		OpenTimeout:      cfg.CircuitBreakerOpenTimeout,
+		HalfOpenMaxCalls: cfg.CircuitBreakerHalfOpenMaxCalls,
+	}
+	authBreaker := grpc.NewCircuitBreaker("auth-svc", breakerOptions)
+	productBreaker := grpc.NewCircuitBreaker("product-svc", breakerOptions)
+	orderBreaker := grpc.NewCircuitBreaker("order-svc", breakerOptions)
+	paymentBreaker := grpc.NewCircuitBreaker("payment-svc", breakerOptions)
+	reminderBreaker := grpc.NewCircuitBreaker("reminder-svc", breakerOptions)
+	breakers := []*grpc.CircuitBreaker{authBreaker, productBreaker, orderBreaker, paymentBreaker, reminderBreaker}
+	grpc.RegisterCircuitBreakerMetrics(breakers)
+
 	// Initialize gRPC client for authentication service
-	authConn, err := grpc.NewClient(cfg.AuthServiceURL)
+	authConn, err := grpc.NewClient(cfg.AuthServiceURL, grpc.WithMetrics("auth-svc"), grpc.WithTracing("auth-svc"), grpc.WithRequestID(), grpc.WithCircuitBreaker(authBreaker), grpc.WithTimeouts(cfg.AuthServiceTimeout, cfg.GrpcMethodTimeouts), grpc.WithRetries(retryPolicy), grpc.WithIdempotencyGate(grpc.IdempotentMethods))
 	if err != nil {
 		utils.Logger.Fatal("Failed to connect to authentication service", map[string]interface{}{
 			"error": err,
@@ -44,10 +95,38 @@ func main() {
 	}
 
 	authClient := grpc.NewAuthServiceClient(authConn.Conn())
-	defer authConn.Close()
+
+	// Share logouts and session revocations between replicas
+	revocationStore := revocation.NewStore(cfg)
+	revocations := revocation.NewList(revocationStore, cfg.TokenRevocationTTL)
+
+	// Verify tokens locally when a JWKS document is configured, falling back to auth-svc for unknown keys
+	var keySet *auth.KeySet
+	if cfg.JWKSURL != "" {
+		keySet, err = auth.NewKeySet(cfg.JWKSURL, cfg.JWKSRefreshInterval)
+		if err != nil {
+			utils.Error("Failed to load JWKS, verifying tokens with authentication service only", map[string]interface{}{
+				"error": err,
+			})
+			keySet = nil
+		} else if verifier, err := auth.NewVerifier(keySet, cfg.JWTIssuer, cfg.JWTAudience, cfg.JWTLeeway); err != nil {
+			utils.Error("Set JWT_ISSUER and JWT_AUDIENCE to verify tokens locally, verifying tokens with authentication service only", map[string]interface{}{
+				"error": err,
+			})
+			keySet.Close()
+			keySet = nil
+		} else {
+			authClient = grpc.NewLocalAuthClient(authClient, verifier, revocations)
+		}
+	}
+
+	// Cache token verifications so that auth-svc is not called on every request
+	tokenCache := grpc.NewTokenCache(cfg.TokenCacheSize, cfg.TokenCacheTTL, cfg.TokenCacheNegativeTTL)
+	authClient = grpc.NewCachedAuthClient(authClient, tokenCache, revocations)
+	grpc.RegisterTokenCacheMetrics(tokenCache)
 
 	// Initialize gRPC client for product service
-	productConn, err := grpc.NewClient(cfg.ProductServiceURL)
+	productConn, err := grpc.NewClient(cfg.ProductServiceURL, grpc.WithMetrics("product-svc"), grpc.WithTracing("product-svc"), grpc.WithRequestID(), grpc.WithCircuitBreaker(productBreaker), grpc.WithTimeouts(cfg.ProductServiceTimeout, cfg.GrpcMethodTimeouts), grpc.WithRetries(retryPolicy), grpc.WithIdempotencyGate(grpc.IdempotentMethods))
 	if err != nil {
 		utils.Logger.Fatal("Failed to connect to product service", map[string]interface{}{
 			"error": err,
@@ -55,10 +134,9 @@ func main() {
 	}
 
 	productClient := grpc.NewProductServiceClient(productConn.Conn())
-	defer productConn.Close()
 
 	// Initialize gRPC client for order service
-	orderConn, err := grpc.NewClient(cfg.OrderServiceURL)
+	orderConn, err := grpc.NewClient(cfg.OrderServiceURL, grpc.WithMetrics("order-svc"), grpc.WithTracing("order-svc"), grpc.WithRequestID(), grpc.WithCircuitBreaker(orderBreaker), grpc.WithTimeouts(cfg.OrderServiceTimeout, cfg.GrpcMethodTimeouts), grpc.WithRetries(retryPolicy), grpc.WithIdempotencyGate(grpc.IdempotentMethods))
 	if err != nil {
 		utils.Logger.Fatal("Failed to connect to order service", map[string]interface{}{
 			"error": err,
@@ -66,10 +144,9 @@ func main() {
 	}
 
 	orderClient := grpc.NewOrderServiceClient(orderConn.Conn())
-	defer orderConn.Close()
 
 	// Initialize gRPC client for payment service
-	paymentConn, err := grpc.NewClient(cfg.PaymentServiceURL)
+	paymentConn, err := grpc.NewClient(cfg.PaymentServiceURL, grpc.WithMetrics("payment-svc"), grpc.WithTracing("payment-svc"), grpc.WithRequestID(), grpc.WithCircuitBreaker(paymentBreaker), grpc.WithTimeouts(cfg.PaymentServiceTimeout, cfg.GrpcMethodTimeouts), grpc.WithRetries(retryPolicy), grpc.WithIdempotencyGate(grpc.IdempotentMethods))
 	if err != nil {
 		utils.Logger.Fatal("Failed to connect to payment service", map[string]interface{}{
 			"error": err,
@@ -77,10 +154,9 @@ func main() {
 	}
 
 	paymentClient := grpc.NewPaymentServiceClient(paymentConn.Conn())
-	defer paymentConn.Close()
 
 	// Initialize gRPC client for reminder service
-	reminderConn, err := grpc.NewClient(cfg.ReminderServiceURL)
+	reminderConn, err := grpc.NewClient(cfg.ReminderServiceURL, grpc.WithMetrics("reminder-svc"), grpc.WithTracing("reminder-svc"), grpc.WithRequestID(), grpc.WithCircuitBreaker(reminderBreaker), grpc.WithTimeouts(cfg.ReminderServiceTimeout, cfg.GrpcMethodTimeouts), grpc.WithRetries(retryPolicy), grpc.WithIdempotencyGate(grpc.IdempotentMethods))
 	if err != nil {
 		utils.Logger.Fatal("Failed to connect to reminder service", map[string]interface{}{
 			"error": err,
@@ -88,13 +164,71 @@ func main() {
 	}
 
 	reminderClient := grpc.NewReminderServiceClient(reminderConn.Conn())
-	defer reminderConn.Close()
+
+	// Initialize rate limit store shared by all limiters
+	rateLimitStore := ratelimit.NewStore(cfg)
+
+	// Record processed webhook events so that redeliveries are acknowledged without side effects
+	webhookEventStore := eventstore.NewStore(cfg)
+	deduplicator := eventstore.NewDeduplicator(webhookEventStore, cfg.WebhookEventLease, cfg.WebhookEventRetention)
+	paymentLocker := eventstore.NewLocker(webhookEventStore, cfg.WebhookEventLease)
+
+	// Verify and normalize the webhooks of the enabled payment providers
+	paymentProviders := payments.NewProviders(cfg)
+
+	// Process verified webhook events and queued refunds in the background, retrying them while payment-svc is unavailable
+	webhookQueue, err := webhookqueue.New(cfg.WebhookQueueDir, handlers.ProcessQueuedEvent(paymentProviders, paymentClient, orderClient, deduplicator, paymentLocker), webhookqueue.Options{
+		Workers:           cfg.WebhookQueueWorkers,
+		MaxAttempts:       cfg.WebhookQueueMaxAttempts,
+		InitialBackoff:    cfg.WebhookQueueInitialBackoff,
+		MaxBackoff:        cfg.WebhookQueueMaxBackoff,
+		RequirePersistent: cfg.WebhookQueueRequirePersistent,
+	})
+	if err != nil {
+		utils.Logger.Fatal("Failed to open webhook queue", map[string]interface{}{
+			"error": err,
+		})
+	}
+	webhookqueue.RegisterMetrics(webhookQueue)
+
+	// Check the health of every downstream service for readiness probes
+	healthChecker := grpc.NewHealthChecker(cfg.HealthCheckTimeout)
+	dependencies := []struct{ name, url string }{
+		{"auth-svc", cfg.AuthServiceURL},
+		{"product-svc", cfg.ProductServiceURL},
+		{"order-svc", cfg.OrderServiceURL},
+		{"payment-svc", cfg.PaymentServiceURL},
+		{"reminder-svc", cfg.ReminderServiceURL},
+	}
+	for _, dep := range dependencies {
+		if err := healthChecker.AddDependency(dep.name, dep.url, slices.Contains(cfg.CriticalDependencies, dep.name)); err != nil {
+			utils.Logger.Fatal("Failed to connect health check", map[string]interface{}{
+				"service": dep.name,
+				"error":   err,
+			})
+		}
+	}
 
 	// Set to Release mode once in production
 	gin.SetMode(gin.ReleaseMode)
 
-	// Initialize Gin router
-	r := gin.Default()
+	// Initialize Gin router. Requests are logged by the access log middleware instead of gin's logger.
+	r := gin.New()
+	r.Use(gin.Recovery())
+
+	// Only trust X-Forwarded-For from the load balancer, so that clients cannot pick the IP they are
+	// rate limited by
+	if err := r.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		utils.Logger.Fatal("Invalid trusted proxies", map[string]interface{}{
+			"error": err,
+		})
+	}
+	if cfg.RateLimitEnabled && len(cfg.TrustedProxies) == 0 {
+		utils.Warn("TRUSTED_PROXIES is not set, so behind a load balancer every client shares the rate limits of its IP", map[string]interface{}{
+			"rate_limit_rps":      cfg.RateLimitRPS,
+			"auth_rate_limit_rps": cfg.AuthRateLimitRPS,
+		})
+	}
 
 	// Add Swagger documentation
 	docs.SwaggerInfo.Title = "PharmaKart Gateway API"
@@ -104,6 +238,12 @@ func main() {
 	// Set CORS headers
 	r.Use(utils.NewCors())
 
+	// Trace requests, tag them with a request ID, and log and record request metrics
+	r.Use(middleware.Tracing())
+	r.Use(middleware.RequestID())
+	r.Use(middleware.AccessLog(cfg.AccessLogExcludePaths))
+	r.Use(middleware.Metrics())
+
 	// Redirect /swagger to /swagger/index.html
 	r.GET("/swagger", func(c *gin.Context) {
 		c.Redirect(302, "/swagger/index.html")
@@ -114,15 +254,117 @@ func main() {
 		swaggerFiles.Handler,
 		ginSwagger.DefaultModelsExpandDepth(-1),
 	)) // Register auth routes
-	routes.RegisterRoutes(r, cfg, authClient, productClient, orderClient, paymentClient, reminderClient)
+	routes.RegisterRoutes(r, cfg, rateLimitStore, tokenCache, breakers, healthChecker, authClient, productClient, orderClient, paymentClient, reminderClient, paymentProviders, webhookQueue)
+
+	srv := &http.Server{
+		Addr:              ":" + cfg.Port,
+		Handler:           r,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	// Serve metrics on their own port, which is not exposed publicly
+	metricsMux := http.NewServeMux()
+	metricsMux.Handle("/metrics", metrics.Handler())
+	metricsSrv := &http.Server{
+		Addr:              ":" + cfg.MetricsPort,
+		Handler:           metricsMux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		if err := metricsSrv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			utils.Logger.Fatal("Failed to start metrics server", map[string]interface{}{
+				"error": err,
+			})
+		}
+	}()
 
 	// Start server
-	utils.Info("Starting gateway service", map[string]interface{}{
-		"port": cfg.Port,
+	go func() {
+		utils.Info("Starting gateway service", map[string]interface{}{
+			"port": cfg.Port,
+		})
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			utils.Logger.Fatal("Failed to start server", map[string]interface{}{
+				"error": err,
+			})
+		}
+	}()
+
+	// Wait for a termination signal
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	sig := <-quit
+
+	utils.Info("Shutting down gateway service", map[string]interface{}{
+		"signal":       sig.String(),
+		"grace_period": cfg.ShutdownGracePeriod.String(),
 	})
-	if err := r.Run(":" + cfg.Port); err != nil {
-		utils.Logger.Fatal("Failed to start server", map[string]interface{}{
+
+	// Report unready so that the load balancer stops routing new requests here,
+	// and give it time to notice before the listener goes away
+	healthChecker.SetDraining()
+	time.Sleep(cfg.ShutdownDrainDelay)
+
+	// Stop accepting connections and wait for in-flight requests, such as uploads and webhooks, to finish
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownGracePeriod)
+	defer cancel()
+
+	if err := srv.Shutdown(ctx); err != nil {
+		utils.Error("Failed to drain in-flight requests", map[string]interface{}{
+			"error": err,
+		})
+	}
+
+	// Let the webhook workers finish the events they are processing. Unfinished events stay queued.
+	if err := webhookQueue.Close(ctx); err != nil {
+		utils.Error("Failed to stop webhook queue", map[string]interface{}{
+			"error": err,
+		})
+	}
+
+	// Close downstream connections once no handler can use them anymore
+	reminderConn.Close()
+	paymentConn.Close()
+	orderConn.Close()
+	productConn.Close()
+	authConn.Close()
+	healthChecker.Close()
+
+	if keySet != nil {
+		keySet.Close()
+	}
+	if err := rateLimitStore.Close(); err != nil {
+		utils.Error("Failed to close rate limit store", map[string]interface{}{
+			"error": err,
+		})
+	}
+	if err := revocationStore.Close(); err != nil {
+		utils.Error("Failed to close token revocation store", map[string]interface{}{
 			"error": err,
 		})
 	}
+	if err := webhookEventStore.Close(); err != nil {
+		utils.Error("Failed to close webhook event store", map[string]interface{}{
+			"error": err,
+		})
+	}
+
+	// Metrics are served until everything else has stopped
+	if err := metricsSrv.Shutdown(ctx); err != nil {
+		utils.Error("Failed to stop metrics server", map[string]interface{}{
+			"error": err,
+		})
+	}
+
+	// Flush spans recorded while draining
+	tracingCtx, tracingCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer tracingCancel()
+
+	if err := shutdownTracing(tracingCtx); err != nil {
+		utils.Error("Failed to flush traces", map[string]interface{}{
+			"error": err,
+		})
+	}
+
+	utils.Info("Gateway service stopped", nil)
 }
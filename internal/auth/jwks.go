@@ -0,0 +1,273 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/PharmaKart/gateway-svc/pkg/utils"
+)
+
+// minRefreshInterval stops a flood of tokens with unknown key IDs from hammering the JWKS source,
+// including while it is failing
+const minRefreshInterval = 30 * time.Second
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+type jwkSet struct {
+	Keys []jwk `json:"keys"`
+}
+
+// KeySet holds the public keys of a JWKS document loaded from a file or URL
+type KeySet struct {
+	source      string
+	client      *http.Client
+	minInterval time.Duration
+
+	mu   sync.RWMutex
+	keys map[string]crypto.PublicKey
+	// lastAttempt is the time of the last reload, successful or not
+	lastAttempt time.Time
+
+	refreshMu sync.Mutex
+	stop      chan struct{}
+	stopOnce  sync.Once
+}
+
+// NewKeySet loads the JWKS document at source and reloads it every interval.
+// Source is either an http(s) URL or a file path.
+func NewKeySet(source string, interval time.Duration) (*KeySet, error) {
+	ks := &KeySet{
+		source:      source,
+		client:      &http.Client{Timeout: 5 * time.Second},
+		minInterval: minRefreshInterval,
+		keys:        make(map[string]crypto.PublicKey),
+		stop:        make(chan struct{}),
+	}
+
+	if err := ks.Refresh(context.Background()); err != nil {
+		return nil, err
+	}
+
+	if interval > 0 {
+		go ks.refreshLoop(interval)
+	}
+
+	return ks, nil
+}
+
+// Key returns the public key with the given key ID
+func (ks *KeySet) Key(kid string) (crypto.PublicKey, bool) {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	key, ok := ks.keys[kid]
+	return key, ok
+}
+
+// Keys returns every known public key
+func (ks *KeySet) Keys() []crypto.PublicKey {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	keys := make([]crypto.PublicKey, 0, len(ks.keys))
+	for _, key := range ks.keys {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// RefreshIfStale reloads the key set unless a reload was attempted very recently. Concurrent callers
+// share a single reload.
+func (ks *KeySet) RefreshIfStale(ctx context.Context) error {
+	if !ks.stale() {
+		return nil
+	}
+
+	ks.refreshMu.Lock()
+	defer ks.refreshMu.Unlock()
+
+	// Another caller may have reloaded the key set while this one waited
+	if !ks.stale() {
+		return nil
+	}
+	return ks.refresh(ctx)
+}
+
+// Refresh reloads the key set from its source
+func (ks *KeySet) Refresh(ctx context.Context) error {
+	ks.refreshMu.Lock()
+	defer ks.refreshMu.Unlock()
+
+	return ks.refresh(ctx)
+}
+
+func (ks *KeySet) stale() bool {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	return time.Since(ks.lastAttempt) >= ks.minInterval
+}
+
+func (ks *KeySet) refresh(ctx context.Context) error {
+	data, err := ks.fetch(ctx)
+
+	ks.mu.Lock()
+	ks.lastAttempt = time.Now()
+	ks.mu.Unlock()
+
+	if err != nil {
+		return err
+	}
+
+	var set jwkSet
+	if err := json.Unmarshal(data, &set); err != nil {
+		return fmt.Errorf("invalid JWKS document: %w", err)
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+
+		key, err := k.publicKey()
+		if err != nil {
+			utils.Warn("Skipping invalid JWKS key", map[string]interface{}{
+				"kid":   k.Kid,
+				"error": err,
+			})
+			continue
+		}
+		keys[k.Kid] = key
+	}
+
+	ks.mu.Lock()
+	ks.keys = keys
+	ks.mu.Unlock()
+
+	return nil
+}
+
+// Close stops the background refresh
+func (ks *KeySet) Close() {
+	ks.stopOnce.Do(func() {
+		close(ks.stop)
+	})
+}
+
+func (ks *KeySet) refreshLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := ks.Refresh(context.Background()); err != nil {
+				utils.Error("Failed to refresh JWKS", map[string]interface{}{
+					"source": ks.source,
+					"error":  err,
+				})
+			}
+		case <-ks.stop:
+			return
+		}
+	}
+}
+
+func (ks *KeySet) fetch(ctx context.Context) ([]byte, error) {
+	if !strings.HasPrefix(ks.source, "http://") && !strings.HasPrefix(ks.source, "https://") {
+		return os.ReadFile(strings.TrimPrefix(ks.source, "file://"))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, ks.source, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := ks.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected JWKS response status: %s", resp.Status)
+	}
+
+	return io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+}
+
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		if !e.IsInt64() {
+			return nil, fmt.Errorf("RSA exponent too large")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, fmt.Errorf("EC point is not on curve %s", k.Crv)
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
+
+func decodeBigInt(value string) (*big.Int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(data), nil
+}
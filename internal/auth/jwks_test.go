@@ -0,0 +1,120 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestKeySetLoadsFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	document := `{"keys": [
+		{"kty": "RSA", "kid": "rsa", "use": "sig", "n": "sXchDaQebHnPiGvyDOAT4saGEUetSyo9MKLOoWFsueri23bOdgWp4Dy1WlUzewbgBHod5pcM9H95GQRV3JDXboIRROSBigeC5yjU1hGzHHyXss8UDprecbAYxknTcQkhslANGRUZmdTOQ5qTRsLAt6BTYuyvVRdhS8exSZEy_c4gs_7svlJJQ4H9_NxsiIoLwAEk7-Q3UXERGYw_75IDrGA84-lA_-Ct4eTlXHBIY2EaV7t7LjJaynVJCpkv4LKjTTAumiGUIuQhrNhZLuF_RJLqHpM2kgWFLU7-VTdL1VbC2tejvcI2BlMkEpk1BzBZI0KQB0GaDWFLN-aEAw3vRw", "e": "AQAB"},
+		{"kty": "RSA", "kid": "enc", "use": "enc", "n": "sXchDaQebHnPiGvyDOAT4saGEUetSyo9MKLOoWFsueri23bOdgWp4Dy1WlUzewbgBHod5pcM9H95GQRV3JDXboIRROSBigeC5yjU1hGzHHyXss8UDprecbAYxknTcQkhslANGRUZmdTOQ5qTRsLAt6BTYuyvVRdhS8exSZEy_c4gs_7svlJJQ4H9_NxsiIoLwAEk7-Q3UXERGYw_75IDrGA84-lA_-Ct4eTlXHBIY2EaV7t7LjJaynVJCpkv4LKjTTAumiGUIuQhrNhZLuF_RJLqHpM2kgWFLU7-VTdL1VbC2tejvcI2BlMkEpk1BzBZI0KQB0GaDWFLN-aEAw3vRw", "e": "AQAB"},
+		{"kty": "EC", "kid": "off-curve", "crv": "P-256", "x": "AQ", "y": "AQ"},
+		{"kty": "oct", "kid": "secret", "k": "c2VjcmV0"}
+	]}`
+	if err := os.WriteFile(path, []byte(document), 0o600); err != nil {
+		t.Fatalf("write JWKS: %v", err)
+	}
+
+	keys, err := NewKeySet(path, 0)
+	if err != nil {
+		t.Fatalf("NewKeySet: %v", err)
+	}
+	defer keys.Close()
+
+	if _, ok := keys.Key("rsa"); !ok {
+		t.Error("signing key was not loaded")
+	}
+	for _, kid := range []string{"enc", "off-curve", "secret"} {
+		if _, ok := keys.Key(kid); ok {
+			t.Errorf("key %q was loaded", kid)
+		}
+	}
+}
+
+func TestNewKeySetFailsWithoutDocument(t *testing.T) {
+	server := newJWKSServer(t)
+	server.setFailing(true)
+
+	if _, err := NewKeySet(server.URL, 0); err == nil {
+		t.Fatal("NewKeySet succeeded without a JWKS document")
+	}
+}
+
+func TestVerifyReloadsRotatedKeys(t *testing.T) {
+	server := newJWKSServer(t)
+	server.addRSAKey("old", &newRSAKey(t).PublicKey)
+	verifier := newVerifier(t, server)
+	verifier.keys.minInterval = 0
+
+	rotated := newRSAKey(t)
+	server.addRSAKey("new", &rotated.PublicKey)
+
+	claims, err := verifier.Verify(context.Background(), sign(t, jwt.SigningMethodRS256, "new", rotated, validClaims()))
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if claims.User() != "user-1" {
+		t.Fatalf("user = %q, want user-1", claims.User())
+	}
+}
+
+func TestVerifyBacksOffWhileJWKSFails(t *testing.T) {
+	server := newJWKSServer(t)
+	server.addRSAKey("known", &newRSAKey(t).PublicKey)
+	verifier := newVerifier(t, server)
+	verifier.keys.minInterval = time.Hour
+	// Let the first unknown key trigger a reload
+	verifier.keys.lastAttempt = time.Time{}
+
+	server.setFailing(true)
+	startRequests := server.requests.Load()
+
+	unknown := newRSAKey(t)
+	token := sign(t, jwt.SigningMethodRS256, "unknown", unknown, validClaims())
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			if _, err := verifier.Verify(context.Background(), token); !errors.Is(err, ErrUnknownKey) {
+				t.Errorf("Verify error = %v, want %v", err, ErrUnknownKey)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if _, err := verifier.Verify(context.Background(), token); !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("Verify error = %v, want %v", err, ErrUnknownKey)
+	}
+
+	if requests := server.requests.Load() - startRequests; requests != 1 {
+		t.Fatalf("JWKS was fetched %d times, want 1", requests)
+	}
+}
+
+func TestKeySetKeepsKeysWhenReloadFails(t *testing.T) {
+	key := newRSAKey(t)
+	server := newJWKSServer(t)
+	server.addRSAKey("known", &key.PublicKey)
+	verifier := newVerifier(t, server)
+
+	server.setFailing(true)
+	if err := verifier.keys.Refresh(context.Background()); err == nil {
+		t.Fatal("Refresh succeeded while the JWKS source fails")
+	}
+
+	if _, err := verifier.Verify(context.Background(), sign(t, jwt.SigningMethodRS256, "known", key, validClaims())); err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+}
@@ -0,0 +1,117 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	// ErrUnknownKey means the token was signed by a key the gateway does not know about
+	ErrUnknownKey = errors.New("unknown signing key")
+	// ErrInvalidToken means the token is malformed or its signature does not match
+	ErrInvalidToken = errors.New("invalid token")
+	// ErrTokenExpired means the token is outside its validity window
+	ErrTokenExpired = errors.New("token has expired")
+	// ErrInvalidClaims means the expiry, issuer or audience is missing or does not match
+	ErrInvalidClaims = errors.New("invalid token claims")
+	// ErrVerifierConfig means local verification cannot be enabled safely
+	ErrVerifierConfig = errors.New("local token verification requires an issuer and an audience")
+)
+
+// signingMethods are the asymmetric algorithms accepted for tokens signed by JWKS keys
+var signingMethods = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}
+
+// Claims are the token claims the gateway relies on
+type Claims struct {
+	jwt.RegisteredClaims
+	UserID string `json:"user_id"`
+	Role   string `json:"role"`
+}
+
+// User returns the user ID carried by the token
+func (c *Claims) User() string {
+	if c.UserID != "" {
+		return c.UserID
+	}
+	return c.Subject
+}
+
+// Verifier validates JWTs locally against a JWKS key set
+type Verifier struct {
+	keys   *KeySet
+	parser *jwt.Parser
+}
+
+// NewVerifier creates a verifier that requires tokens to carry an expiry and to match issuer and audience
+func NewVerifier(keys *KeySet, issuer, audience string, leeway time.Duration) (*Verifier, error) {
+	if issuer == "" || audience == "" {
+		return nil, ErrVerifierConfig
+	}
+
+	return &Verifier{
+		keys: keys,
+		parser: jwt.NewParser(
+			jwt.WithValidMethods(signingMethods),
+			jwt.WithExpirationRequired(),
+			jwt.WithIssuer(issuer),
+			jwt.WithAudience(audience),
+			jwt.WithLeeway(leeway),
+		),
+	}, nil
+}
+
+// Verify checks the signature, validity window, issuer and audience of token
+func (v *Verifier) Verify(ctx context.Context, token string) (*Claims, error) {
+	var claims Claims
+	_, err := v.parser.ParseWithClaims(token, &claims, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		return v.verificationKeys(ctx, kid)
+	})
+
+	switch {
+	case err == nil:
+		return &claims, nil
+	case errors.Is(err, ErrUnknownKey):
+		return nil, ErrUnknownKey
+	case errors.Is(err, jwt.ErrTokenExpired), errors.Is(err, jwt.ErrTokenNotValidYet):
+		return nil, ErrTokenExpired
+	case errors.Is(err, jwt.ErrTokenInvalidClaims):
+		return nil, ErrInvalidClaims
+	default:
+		return nil, ErrInvalidToken
+	}
+}
+
+// verificationKeys returns the keys that may have signed a token with the given key ID,
+// reloading the key set if the key ID is not known yet
+func (v *Verifier) verificationKeys(ctx context.Context, kid string) (interface{}, error) {
+	if kid == "" {
+		keys := v.keys.Keys()
+		if len(keys) == 0 {
+			return nil, ErrUnknownKey
+		}
+
+		set := jwt.VerificationKeySet{Keys: make([]jwt.VerificationKey, 0, len(keys))}
+		for _, key := range keys {
+			set.Keys = append(set.Keys, key)
+		}
+		return set, nil
+	}
+
+	if key, ok := v.keys.Key(kid); ok {
+		return key, nil
+	}
+
+	if err := v.keys.RefreshIfStale(ctx); err != nil {
+		return nil, ErrUnknownKey
+	}
+
+	if key, ok := v.keys.Key(kid); ok {
+		return key, nil
+	}
+
+	return nil, ErrUnknownKey
+}
@@ -0,0 +1,328 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/PharmaKart/gateway-svc/pkg/config"
+	"github.com/PharmaKart/gateway-svc/pkg/utils"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	testIssuer   = "https://auth.pharmakart.test"
+	testAudience = "gateway"
+)
+
+func TestMain(m *testing.M) {
+	utils.InitLogger(&config.Config{LogLevel: "panic"})
+	os.Exit(m.Run())
+}
+
+// jwksServer serves a JWKS document holding the public keys it is given, and counts the requests
+type jwksServer struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	keys     []jwk
+	failing  bool
+	requests atomic.Int64
+}
+
+func newJWKSServer(t *testing.T) *jwksServer {
+	t.Helper()
+
+	s := &jwksServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.requests.Add(1)
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		if s.failing {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(jwkSet{Keys: s.keys})
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *jwksServer) addRSAKey(kid string, key *rsa.PublicKey) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.keys = append(s.keys, jwk{
+		Kty: "RSA",
+		Kid: kid,
+		Use: "sig",
+		N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	})
+}
+
+func (s *jwksServer) addECKey(kid string, key *ecdsa.PublicKey) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.keys = append(s.keys, jwk{
+		Kty: "EC",
+		Kid: kid,
+		Crv: "P-256",
+		X:   base64.RawURLEncoding.EncodeToString(key.X.FillBytes(make([]byte, 32))),
+		Y:   base64.RawURLEncoding.EncodeToString(key.Y.FillBytes(make([]byte, 32))),
+	})
+}
+
+func (s *jwksServer) setFailing(failing bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.failing = failing
+}
+
+func newRSAKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate RSA key: %v", err)
+	}
+	return key
+}
+
+func sign(t *testing.T, method jwt.SigningMethod, kid string, key interface{}, claims jwt.MapClaims) string {
+	t.Helper()
+
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatalf("sign token: %v", err)
+	}
+	return signed
+}
+
+// validClaims returns the claims of a token that passes every check
+func validClaims() jwt.MapClaims {
+	now := time.Now()
+	return jwt.MapClaims{
+		"sub":  "user-1",
+		"role": "customer",
+		"iss":  testIssuer,
+		"aud":  testAudience,
+		"iat":  now.Unix(),
+		"exp":  now.Add(time.Hour).Unix(),
+	}
+}
+
+func with(claims jwt.MapClaims, changes map[string]interface{}) jwt.MapClaims {
+	for name, value := range changes {
+		if value == nil {
+			delete(claims, name)
+			continue
+		}
+		claims[name] = value
+	}
+	return claims
+}
+
+func newVerifier(t *testing.T, server *jwksServer) *Verifier {
+	t.Helper()
+
+	keys, err := NewKeySet(server.URL, 0)
+	if err != nil {
+		t.Fatalf("NewKeySet: %v", err)
+	}
+	t.Cleanup(keys.Close)
+
+	verifier, err := NewVerifier(keys, testIssuer, testAudience, 30*time.Second)
+	if err != nil {
+		t.Fatalf("NewVerifier: %v", err)
+	}
+	return verifier
+}
+
+func TestVerify(t *testing.T) {
+	rsaKey := newRSAKey(t)
+	otherKey := newRSAKey(t)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate EC key: %v", err)
+	}
+
+	server := newJWKSServer(t)
+	server.addRSAKey("rsa", &rsaKey.PublicKey)
+	server.addECKey("ec", &ecKey.PublicKey)
+	verifier := newVerifier(t, server)
+
+	now := time.Now()
+	tests := []struct {
+		name    string
+		token   string
+		wantErr error
+	}{
+		{
+			name:  "valid RS256",
+			token: sign(t, jwt.SigningMethodRS256, "rsa", rsaKey, validClaims()),
+		},
+		{
+			name:  "valid PS256",
+			token: sign(t, jwt.SigningMethodPS256, "rsa", rsaKey, validClaims()),
+		},
+		{
+			name:  "valid ES256",
+			token: sign(t, jwt.SigningMethodES256, "ec", ecKey, validClaims()),
+		},
+		{
+			name:  "without key ID",
+			token: sign(t, jwt.SigningMethodRS256, "", rsaKey, validClaims()),
+		},
+		{
+			name:  "audience list",
+			token: sign(t, jwt.SigningMethodRS256, "rsa", rsaKey, with(validClaims(), map[string]interface{}{"aud": []string{"other", testAudience}})),
+		},
+		{
+			name:  "expired within leeway",
+			token: sign(t, jwt.SigningMethodRS256, "rsa", rsaKey, with(validClaims(), map[string]interface{}{"exp": now.Add(-10 * time.Second).Unix()})),
+		},
+		{
+			name:    "expired",
+			token:   sign(t, jwt.SigningMethodRS256, "rsa", rsaKey, with(validClaims(), map[string]interface{}{"exp": now.Add(-time.Minute).Unix()})),
+			wantErr: ErrTokenExpired,
+		},
+		{
+			name:    "not valid yet",
+			token:   sign(t, jwt.SigningMethodRS256, "rsa", rsaKey, with(validClaims(), map[string]interface{}{"nbf": now.Add(time.Minute).Unix()})),
+			wantErr: ErrTokenExpired,
+		},
+		{
+			name:    "without expiry",
+			token:   sign(t, jwt.SigningMethodRS256, "rsa", rsaKey, with(validClaims(), map[string]interface{}{"exp": nil})),
+			wantErr: ErrInvalidClaims,
+		},
+		{
+			name:    "wrong issuer",
+			token:   sign(t, jwt.SigningMethodRS256, "rsa", rsaKey, with(validClaims(), map[string]interface{}{"iss": "https://evil.test"})),
+			wantErr: ErrInvalidClaims,
+		},
+		{
+			name:    "without issuer",
+			token:   sign(t, jwt.SigningMethodRS256, "rsa", rsaKey, with(validClaims(), map[string]interface{}{"iss": nil})),
+			wantErr: ErrInvalidClaims,
+		},
+		{
+			name:    "wrong audience",
+			token:   sign(t, jwt.SigningMethodRS256, "rsa", rsaKey, with(validClaims(), map[string]interface{}{"aud": "other"})),
+			wantErr: ErrInvalidClaims,
+		},
+		{
+			name:    "without audience",
+			token:   sign(t, jwt.SigningMethodRS256, "rsa", rsaKey, with(validClaims(), map[string]interface{}{"aud": nil})),
+			wantErr: ErrInvalidClaims,
+		},
+		{
+			name:    "signed by another key",
+			token:   sign(t, jwt.SigningMethodRS256, "rsa", otherKey, validClaims()),
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name:    "signed by another key without key ID",
+			token:   sign(t, jwt.SigningMethodRS256, "", otherKey, validClaims()),
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name:    "HMAC with the public key",
+			token:   sign(t, jwt.SigningMethodHS256, "rsa", []byte("public key material"), validClaims()),
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name:    "unsigned",
+			token:   sign(t, jwt.SigningMethodNone, "rsa", jwt.UnsafeAllowNoneSignatureType, validClaims()),
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name:    "unknown key",
+			token:   sign(t, jwt.SigningMethodRS256, "rotated", otherKey, validClaims()),
+			wantErr: ErrUnknownKey,
+		},
+		{
+			name:    "malformed",
+			token:   "not.a.token",
+			wantErr: ErrInvalidToken,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := verifier.Verify(context.Background(), tt.token)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Verify error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Verify: %v", err)
+			}
+			if claims.User() != "user-1" || claims.Role != "customer" {
+				t.Fatalf("claims = %+v, want user-1 with role customer", claims)
+			}
+		})
+	}
+}
+
+func TestClaimsUserPrefersUserID(t *testing.T) {
+	claims := Claims{UserID: "user-2"}
+	claims.Subject = "user-1"
+
+	if got := claims.User(); got != "user-2" {
+		t.Fatalf("User() = %q, want user-2", got)
+	}
+}
+
+func TestNewVerifierRequiresIssuerAndAudience(t *testing.T) {
+	server := newJWKSServer(t)
+	keys, err := NewKeySet(server.URL, 0)
+	if err != nil {
+		t.Fatalf("NewKeySet: %v", err)
+	}
+	defer keys.Close()
+
+	tests := []struct {
+		name     string
+		issuer   string
+		audience string
+	}{
+		{"no issuer", "", testAudience},
+		{"no audience", testIssuer, ""},
+		{"neither", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewVerifier(keys, tt.issuer, tt.audience, 0); !errors.Is(err, ErrVerifierConfig) {
+				t.Fatalf("NewVerifier error = %v, want %v", err, ErrVerifierConfig)
+			}
+		})
+	}
+}
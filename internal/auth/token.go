@@ -0,0 +1,43 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// HashToken returns the key under which token is cached or revoked, so that tokens are never stored
+// in the clear
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// ClaimTime reads a numeric date claim of a JWT without verifying it. It must only be used to bound
+// lifetimes or on tokens that have already been verified.
+func ClaimTime(token, claim string) (time.Time, bool) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return time.Time{}, false
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	var claims map[string]interface{}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return time.Time{}, false
+	}
+
+	value, ok := claims[claim].(float64)
+	if !ok {
+		return time.Time{}, false
+	}
+
+	return time.Unix(int64(value), 0), true
+}
@@ -0,0 +1,178 @@
+package eventstore
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/PharmaKart/gateway-svc/pkg/metrics"
+	"github.com/PharmaKart/gateway-svc/pkg/utils"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const pollInterval = 100 * time.Millisecond
+
+var duplicateEvents = metrics.Factory.NewCounterVec(prometheus.CounterOpts{
+	Name: "gateway_webhook_duplicate_events_total",
+	Help: "Webhook deliveries acknowledged without processing because the event was already processed.",
+}, []string{"source"})
+
+// Deduplicator processes each event at most once across deliveries
+type Deduplicator struct {
+	store     Store
+	lease     time.Duration
+	retention time.Duration
+}
+
+// NewDeduplicator creates a Deduplicator. An event is claimed for lease, which is renewed while the
+// event is processed, and remembered for retention once processed, which should cover the provider's
+// redelivery window.
+func NewDeduplicator(store Store, lease, retention time.Duration) *Deduplicator {
+	return &Deduplicator{
+		store:     store,
+		lease:     lease,
+		retention: retention,
+	}
+}
+
+// errClaimLost means the lease of an event expired while it was processed, so another delivery may process it
+var errClaimLost = errors.New("webhook event claim was lost")
+
+// Process runs handle unless the event has already been processed, and reports whether the event was
+// a duplicate. A delivery that arrives while the same event is being processed waits for it to finish.
+// When handle fails the claim is released, so that a redelivery processes the event again. Events are
+// keyed by source, since event IDs are only unique per provider.
+func (d *Deduplicator) Process(ctx context.Context, source, eventID string, handle func(ctx context.Context) error) (bool, error) {
+	key := source + ":" + eventID
+	owner := newOwner()
+
+	for {
+		status, err := d.store.Claim(ctx, key, owner, d.lease)
+		if err != nil {
+			// Processing twice is safer than dropping the event, and downstream calls carry the event ID as an idempotency key
+			utils.ErrorContext(ctx, "Failed to claim webhook event, processing without deduplication", map[string]interface{}{
+				"error":  err,
+				"source": source,
+				"event":  eventID,
+			})
+			return false, handle(ctx)
+		}
+
+		switch status {
+		case Processed:
+			duplicateEvents.WithLabelValues(source).Inc()
+			return true, nil
+		case Claimed:
+			return false, d.run(ctx, key, owner, handle)
+		}
+
+		// Another delivery owns the event, so wait for it to complete or give up its claim
+		if err := poll(ctx); err != nil {
+			return false, err
+		}
+	}
+}
+
+func (d *Deduplicator) run(ctx context.Context, key, owner string, handle func(ctx context.Context) error) error {
+	// Record the outcome even if the delivery's request was cancelled meanwhile
+	storeCtx := context.WithoutCancel(ctx)
+
+	handleCtx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	stopRenewing := renewLease(handleCtx, d.store, key, owner, d.lease, cancel)
+	err := handle(handleCtx)
+	stopRenewing()
+
+	if err != nil {
+		if cause := context.Cause(handleCtx); errors.Is(cause, errClaimLost) {
+			err = cause
+		}
+		if releaseErr := d.store.Release(storeCtx, key, owner); releaseErr != nil {
+			utils.ErrorContext(ctx, "Failed to release webhook event", map[string]interface{}{
+				"error": releaseErr,
+				"event": key,
+			})
+		}
+		return err
+	}
+
+	if err := d.store.Complete(storeCtx, key, owner, d.retention); err != nil {
+		utils.ErrorContext(ctx, "Failed to mark webhook event as processed", map[string]interface{}{
+			"error": err,
+			"event": key,
+		})
+	}
+	return nil
+}
+
+// renewLease extends the lease of a claimed key while it is processed, so that a slow handler keeps its
+// claim. When the claim is lost anyway, e.g. after the store was unreachable for a whole lease, the
+// handler is cancelled so that two deliveries are not processed at the same time.
+func renewLease(ctx context.Context, store Store, key, owner string, lease time.Duration, lost context.CancelCauseFunc) (stop func()) {
+	interval := lease / 3
+	if interval <= 0 {
+		return func() {}
+	}
+
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			held, err := store.Extend(ctx, key, owner, lease)
+			if err != nil {
+				utils.WarnContext(ctx, "Failed to extend webhook event lease", map[string]interface{}{
+					"error": err,
+					"event": key,
+				})
+				continue
+			}
+			if !held {
+				utils.ErrorContext(ctx, "Webhook event lease expired while processing, cancelling", map[string]interface{}{
+					"event": key,
+				})
+				lost(errClaimLost)
+				return
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+// poll waits before a claim is attempted again
+func poll(ctx context.Context) error {
+	timer := time.NewTimer(pollInterval)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+func newOwner() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,219 @@
+package eventstore
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// faultyStore fails claims, or reports every claim as lost when it is extended
+type faultyStore struct {
+	Store
+	claimErr  error
+	claimLost bool
+}
+
+func (s *faultyStore) Claim(ctx context.Context, eventID, owner string, lease time.Duration) (ClaimStatus, error) {
+	if s.claimErr != nil {
+		return 0, s.claimErr
+	}
+	return s.Store.Claim(ctx, eventID, owner, lease)
+}
+
+func (s *faultyStore) Extend(ctx context.Context, eventID, owner string, lease time.Duration) (bool, error) {
+	if s.claimLost {
+		return false, nil
+	}
+	return s.Store.Extend(ctx, eventID, owner, lease)
+}
+
+func TestDeduplicatorProcess(t *testing.T) {
+	ctx := context.Background()
+	errHandle := errors.New("payment-svc is down")
+
+	for name, backend := range newStores(t) {
+		store := backend.Store
+		t.Run(name, func(t *testing.T) {
+			d := NewDeduplicator(store, time.Minute, time.Hour)
+
+			var calls atomic.Int64
+			succeed := func(ctx context.Context) error {
+				calls.Add(1)
+				return nil
+			}
+			fail := func(ctx context.Context) error {
+				calls.Add(1)
+				return errHandle
+			}
+
+			if duplicate, err := d.Process(ctx, "stripe", "evt_1", fail); duplicate || !errors.Is(err, errHandle) {
+				t.Fatalf("failed delivery = %v, %v, want the handler error", duplicate, err)
+			}
+			if duplicate, err := d.Process(ctx, "stripe", "evt_1", succeed); duplicate || err != nil {
+				t.Fatalf("redelivery after a failure = %v, %v, want processed", duplicate, err)
+			}
+			if duplicate, err := d.Process(ctx, "stripe", "evt_1", succeed); !duplicate || err != nil {
+				t.Fatalf("redelivery after success = %v, %v, want a duplicate", duplicate, err)
+			}
+
+			// Event IDs are only unique per provider
+			if duplicate, err := d.Process(ctx, "paypal", "evt_1", succeed); duplicate || err != nil {
+				t.Fatalf("same ID from another provider = %v, %v, want processed", duplicate, err)
+			}
+
+			if got := calls.Load(); got != 3 {
+				t.Fatalf("handler ran %d times, want 3", got)
+			}
+		})
+	}
+}
+
+func TestDeduplicatorConcurrentDeliveries(t *testing.T) {
+	for name, backend := range newStores(t) {
+		store := backend.Store
+		t.Run(name, func(t *testing.T) {
+			// Deliveries race on separate deduplicators, as they would on separate replicas
+			const deliveries = 20
+
+			var calls atomic.Int64
+			var duplicates atomic.Int64
+			var wg sync.WaitGroup
+			for i := 0; i < deliveries; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+
+					d := NewDeduplicator(store, time.Minute, time.Hour)
+					duplicate, err := d.Process(context.Background(), "stripe", "evt_concurrent", func(ctx context.Context) error {
+						calls.Add(1)
+						time.Sleep(50 * time.Millisecond)
+						return nil
+					})
+					if err != nil {
+						t.Errorf("Process: %v", err)
+					}
+					if duplicate {
+						duplicates.Add(1)
+					}
+				}()
+			}
+			wg.Wait()
+
+			if got := calls.Load(); got != 1 {
+				t.Fatalf("handler ran %d times, want once", got)
+			}
+			if got := duplicates.Load(); got != deliveries-1 {
+				t.Fatalf("%d deliveries were duplicates, want %d", got, deliveries-1)
+			}
+		})
+	}
+}
+
+func TestDeduplicatorConcurrentDeliveryTakesOverFailure(t *testing.T) {
+	for name, backend := range newStores(t) {
+		store := backend.Store
+		t.Run(name, func(t *testing.T) {
+			d := NewDeduplicator(store, time.Minute, time.Hour)
+
+			started := make(chan struct{})
+			first := make(chan error, 1)
+			go func() {
+				_, err := d.Process(context.Background(), "stripe", "evt_takeover", func(ctx context.Context) error {
+					close(started)
+					time.Sleep(50 * time.Millisecond)
+					return errors.New("payment-svc is down")
+				})
+				first <- err
+			}()
+			<-started
+
+			// The second delivery waits for the first one, then processes the event itself
+			var ran bool
+			duplicate, err := d.Process(context.Background(), "stripe", "evt_takeover", func(ctx context.Context) error {
+				ran = true
+				return nil
+			})
+			if duplicate || err != nil || !ran {
+				t.Fatalf("second delivery = %v, %v, ran = %v, want processed", duplicate, err, ran)
+			}
+			if err := <-first; err == nil {
+				t.Fatal("first delivery succeeded, want the handler error")
+			}
+		})
+	}
+}
+
+func TestDeduplicatorRenewsLease(t *testing.T) {
+	const lease = 60 * time.Millisecond
+	d := NewDeduplicator(NewMemoryStore(), lease, time.Hour)
+
+	var calls atomic.Int64
+	handle := func(ctx context.Context) error {
+		calls.Add(1)
+		// Runs for several leases
+		select {
+		case <-time.After(5 * lease):
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	first := make(chan error, 1)
+	go func() {
+		_, err := d.Process(context.Background(), "stripe", "evt_slow", handle)
+		first <- err
+	}()
+
+	time.Sleep(2 * lease)
+	duplicate, err := d.Process(context.Background(), "stripe", "evt_slow", handle)
+	if !duplicate || err != nil {
+		t.Fatalf("delivery during processing = %v, %v, want a duplicate once the first completes", duplicate, err)
+	}
+	if err := <-first; err != nil {
+		t.Fatalf("first delivery: %v", err)
+	}
+	if got := calls.Load(); got != 1 {
+		t.Fatalf("handler ran %d times, want once", got)
+	}
+}
+
+func TestDeduplicatorCancelsHandlerWhenClaimIsLost(t *testing.T) {
+	store := &faultyStore{Store: NewMemoryStore(), claimLost: true}
+	d := NewDeduplicator(store, 30*time.Millisecond, time.Hour)
+
+	duplicate, err := d.Process(context.Background(), "stripe", "evt_lost", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	if duplicate || !errors.Is(err, errClaimLost) {
+		t.Fatalf("Process = %v, %v, want %v", duplicate, err, errClaimLost)
+	}
+
+	// The claim is released, so that a redelivery processes the event
+	store.claimLost = false
+	if duplicate, err := d.Process(context.Background(), "stripe", "evt_lost", func(ctx context.Context) error { return nil }); duplicate || err != nil {
+		t.Fatalf("redelivery = %v, %v, want processed", duplicate, err)
+	}
+}
+
+func TestDeduplicatorProcessesWhenStoreIsDown(t *testing.T) {
+	d := NewDeduplicator(&faultyStore{Store: NewMemoryStore(), claimErr: errors.New("store is down")}, time.Minute, time.Hour)
+
+	var calls int
+	for i := 0; i < 2; i++ {
+		duplicate, err := d.Process(context.Background(), "stripe", "evt_1", func(ctx context.Context) error {
+			calls++
+			return nil
+		})
+		if duplicate || err != nil {
+			t.Fatalf("Process = %v, %v, want processed", duplicate, err)
+		}
+	}
+	if calls != 2 {
+		t.Fatalf("handler ran %d times, want every delivery processed", calls)
+	}
+}
@@ -0,0 +1,75 @@
+package eventstore
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/PharmaKart/gateway-svc/pkg/utils"
+)
+
+// lockPrefix keeps lock keys apart from the keys of events
+const lockPrefix = "lock:"
+
+// Locker runs work on the same key one at a time across the gateway replicas sharing its store
+type Locker struct {
+	store Store
+	lease time.Duration
+}
+
+// NewLocker creates a Locker. A lock is held for lease, which is renewed while its work runs, so that
+// the lock of a replica that stopped is taken over after lease at most.
+func NewLocker(store Store, lease time.Duration) *Locker {
+	return &Locker{
+		store: store,
+		lease: lease,
+	}
+}
+
+// Do runs fn while holding the lock on key, waiting for the current holder to release it first. fn is
+// cancelled if the lock is lost while it runs.
+func (l *Locker) Do(ctx context.Context, key string, fn func(ctx context.Context) error) error {
+	key = lockPrefix + key
+	owner := newOwner()
+
+	for {
+		status, err := l.store.Claim(ctx, key, owner, l.lease)
+		if err != nil {
+			// Work that is retried until the store is back would pile up, so run it unlocked like the
+			// deduplicator processes events without a claim
+			utils.ErrorContext(ctx, "Failed to take lock, running without it", map[string]interface{}{
+				"error": err,
+				"key":   key,
+			})
+			return fn(ctx)
+		}
+		if status == Claimed {
+			break
+		}
+
+		if err := poll(ctx); err != nil {
+			return err
+		}
+	}
+
+	fnCtx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	stopRenewing := renewLease(fnCtx, l.store, key, owner, l.lease, cancel)
+	err := fn(fnCtx)
+	stopRenewing()
+
+	if err != nil {
+		if cause := context.Cause(fnCtx); errors.Is(cause, errClaimLost) {
+			err = cause
+		}
+	}
+	if releaseErr := l.store.Release(context.WithoutCancel(ctx), key, owner); releaseErr != nil {
+		// The lock is taken over once its lease expires
+		utils.ErrorContext(ctx, "Failed to release lock", map[string]interface{}{
+			"error": releaseErr,
+			"key":   key,
+		})
+	}
+	return err
+}
@@ -0,0 +1,99 @@
+package eventstore
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLockerRunsOneAtATime(t *testing.T) {
+	for name, backend := range newStores(t) {
+		store := backend.Store
+		t.Run(name, func(t *testing.T) {
+			const workers = 10
+
+			var running, overlaps, calls atomic.Int64
+			var wg sync.WaitGroup
+			for i := 0; i < workers; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+
+					// Each worker has its own locker, as replicas would
+					err := NewLocker(store, time.Minute).Do(context.Background(), "stripe:pi_1", func(ctx context.Context) error {
+						if running.Add(1) > 1 {
+							overlaps.Add(1)
+						}
+						calls.Add(1)
+						time.Sleep(10 * time.Millisecond)
+						running.Add(-1)
+						return nil
+					})
+					if err != nil {
+						t.Errorf("Do: %v", err)
+					}
+				}()
+			}
+			wg.Wait()
+
+			if got := calls.Load(); got != workers {
+				t.Fatalf("work ran %d times, want %d", got, workers)
+			}
+			if got := overlaps.Load(); got != 0 {
+				t.Fatalf("work overlapped %d times", got)
+			}
+		})
+	}
+}
+
+func TestLockerKeysAreIndependent(t *testing.T) {
+	for name, backend := range newStores(t) {
+		store := backend.Store
+		t.Run(name, func(t *testing.T) {
+			locker := NewLocker(store, time.Minute)
+
+			err := locker.Do(context.Background(), "stripe:pi_1", func(ctx context.Context) error {
+				// Holding the lock of one key does not block another
+				return locker.Do(ctx, "stripe:pi_2", func(ctx context.Context) error { return nil })
+			})
+			if err != nil {
+				t.Fatalf("Do: %v", err)
+			}
+		})
+	}
+}
+
+func TestLockerReleasesAfterFailure(t *testing.T) {
+	for name, backend := range newStores(t) {
+		store := backend.Store
+		t.Run(name, func(t *testing.T) {
+			locker := NewLocker(store, time.Minute)
+			errWork := errors.New("payment-svc is down")
+
+			if err := locker.Do(context.Background(), "stripe:pi_1", func(ctx context.Context) error { return errWork }); !errors.Is(err, errWork) {
+				t.Fatalf("Do = %v, want the work error", err)
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			if err := locker.Do(ctx, "stripe:pi_1", func(ctx context.Context) error { return nil }); err != nil {
+				t.Fatalf("Do after a failure = %v, want the lock released", err)
+			}
+		})
+	}
+}
+
+func TestLockerRunsWhenStoreIsDown(t *testing.T) {
+	locker := NewLocker(&faultyStore{Store: NewMemoryStore(), claimErr: errors.New("store is down")}, time.Minute)
+
+	var ran bool
+	if err := locker.Do(context.Background(), "stripe:pi_1", func(ctx context.Context) error {
+		ran = true
+		return nil
+	}); err != nil || !ran {
+		t.Fatalf("Do = %v, ran = %v, want the work run unlocked", err, ran)
+	}
+}
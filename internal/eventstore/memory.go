@@ -0,0 +1,107 @@
+package eventstore
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type memoryEntry struct {
+	owner     string
+	processed bool
+	expiresAt time.Time
+}
+
+type memoryStore struct {
+	mu        sync.Mutex
+	events    map[string]*memoryEntry
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// NewMemoryStore creates a Store local to this gateway instance
+func NewMemoryStore() Store {
+	return newMemoryStore(time.Now)
+}
+
+// newMemoryStore creates a memory store that reads the time from now
+func newMemoryStore(now func() time.Time) *memoryStore {
+	return &memoryStore{
+		events: make(map[string]*memoryEntry),
+		now:    now,
+	}
+}
+
+func (s *memoryStore) Claim(ctx context.Context, eventID, owner string, lease time.Duration) (ClaimStatus, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.sweep(now)
+
+	if entry, ok := s.events[eventID]; ok && now.Before(entry.expiresAt) {
+		if entry.processed {
+			return Processed, nil
+		}
+		return InProgress, nil
+	}
+
+	s.events[eventID] = &memoryEntry{
+		owner:     owner,
+		expiresAt: now.Add(lease),
+	}
+	return Claimed, nil
+}
+
+func (s *memoryStore) Extend(ctx context.Context, eventID, owner string, lease time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	entry, ok := s.events[eventID]
+	if !ok || entry.owner != owner || entry.processed || !now.Before(entry.expiresAt) {
+		return false, nil
+	}
+
+	entry.expiresAt = now.Add(lease)
+	return true, nil
+}
+
+func (s *memoryStore) Complete(ctx context.Context, eventID, owner string, retention time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if entry, ok := s.events[eventID]; ok && entry.owner == owner {
+		entry.processed = true
+		entry.expiresAt = s.now().Add(retention)
+	}
+	return nil
+}
+
+func (s *memoryStore) Release(ctx context.Context, eventID, owner string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if entry, ok := s.events[eventID]; ok && entry.owner == owner && !entry.processed {
+		delete(s.events, eventID)
+	}
+	return nil
+}
+
+func (s *memoryStore) Close() error {
+	return nil
+}
+
+// sweep drops expired events at most once a minute
+func (s *memoryStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < time.Minute {
+		return
+	}
+	s.lastSweep = now
+
+	for eventID, entry := range s.events {
+		if !now.Before(entry.expiresAt) {
+			delete(s.events, eventID)
+		}
+	}
+}
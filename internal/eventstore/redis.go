@@ -0,0 +1,101 @@
+package eventstore
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const processedMarker = "processed"
+
+// claimScript sets the owner of an unclaimed event, and otherwise returns the current value
+var claimScript = redis.NewScript(`
+local value = redis.call('GET', KEYS[1])
+if value then
+	return value
+end
+redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2])
+return ''
+`)
+
+// extendScript renews the claim of the owner, and returns whether it still holds it
+var extendScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	redis.call('PEXPIRE', KEYS[1], ARGV[2])
+	return 1
+end
+return 0
+`)
+
+// completeScript marks an event as processed if it is still claimed by the owner
+var completeScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	redis.call('SET', KEYS[1], ARGV[2], 'PX', ARGV[3])
+end
+return 1
+`)
+
+// releaseScript deletes the claim of the owner
+var releaseScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	redis.call('DEL', KEYS[1])
+end
+return 1
+`)
+
+type redisStore struct {
+	client    redis.UniversalClient
+	keyPrefix string
+}
+
+// NewRedisStore creates a Store backed by a Redis server, shared by every gateway replica. The store
+// owns the client.
+func NewRedisStore(client redis.UniversalClient, keyPrefix string) Store {
+	return &redisStore{
+		client:    client,
+		keyPrefix: keyPrefix,
+	}
+}
+
+func (s *redisStore) Claim(ctx context.Context, eventID, owner string, lease time.Duration) (ClaimStatus, error) {
+	reply, err := claimScript.Run(ctx, s.client, []string{s.keyPrefix + eventID}, owner, lease.Milliseconds()).Result()
+	if err != nil {
+		return 0, err
+	}
+
+	value, ok := reply.(string)
+	if !ok {
+		return 0, fmt.Errorf("unexpected event claim reply: %v", reply)
+	}
+
+	switch value {
+	case "":
+		return Claimed, nil
+	case processedMarker:
+		return Processed, nil
+	default:
+		return InProgress, nil
+	}
+}
+
+func (s *redisStore) Extend(ctx context.Context, eventID, owner string, lease time.Duration) (bool, error) {
+	held, err := extendScript.Run(ctx, s.client, []string{s.keyPrefix + eventID}, owner, lease.Milliseconds()).Int()
+	if err != nil {
+		return false, err
+	}
+	return held == 1, nil
+}
+
+func (s *redisStore) Complete(ctx context.Context, eventID, owner string, retention time.Duration) error {
+	return completeScript.Run(ctx, s.client, []string{s.keyPrefix + eventID}, owner, processedMarker, retention.Milliseconds()).Err()
+}
+
+func (s *redisStore) Release(ctx context.Context, eventID, owner string) error {
+	return releaseScript.Run(ctx, s.client, []string{s.keyPrefix + eventID}, owner).Err()
+}
+
+func (s *redisStore) Close() error {
+	return s.client.Close()
+}
@@ -0,0 +1,51 @@
+// Package eventstore records which webhook events have been processed, so that redelivered events
+// are acknowledged without repeating their side effects, and locks the work that related events share.
+package eventstore
+
+import (
+	"context"
+	"time"
+
+	"github.com/PharmaKart/gateway-svc/pkg/config"
+	"github.com/redis/go-redis/v9"
+)
+
+// ClaimStatus is the outcome of claiming an event for processing
+type ClaimStatus int
+
+const (
+	// Claimed means the caller now owns the event and must complete or release it
+	Claimed ClaimStatus = iota
+	// Processed means the event has already been processed
+	Processed
+	// InProgress means another delivery of the event is being processed
+	InProgress
+)
+
+// Store tracks the processing state of events so that deliveries can be shared between gateway replicas
+type Store interface {
+	// Claim marks the event as being processed by owner until lease expires, unless it is already
+	// processed or claimed by another owner
+	Claim(ctx context.Context, eventID, owner string, lease time.Duration) (ClaimStatus, error)
+	// Extend renews the lease of an event claimed by owner, and reports whether owner still holds the claim
+	Extend(ctx context.Context, eventID, owner string, lease time.Duration) (bool, error)
+	// Complete marks an event claimed by owner as processed, and remembers it for retention
+	Complete(ctx context.Context, eventID, owner string, retention time.Duration) error
+	// Release gives up a claim so that a later delivery can process the event
+	Release(ctx context.Context, eventID, owner string) error
+	Close() error
+}
+
+// NewStore creates the Store selected by the configuration
+func NewStore(cfg *config.Config) Store {
+	switch cfg.WebhookEventStore {
+	case "redis":
+		return NewRedisStore(redis.NewClient(&redis.Options{
+			Addr:     cfg.RedisAddr,
+			Password: cfg.RedisPassword,
+			DB:       cfg.RedisDB,
+		}), "gateway:webhook:event:")
+	default:
+		return NewMemoryStore()
+	}
+}
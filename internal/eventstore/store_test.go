@@ -0,0 +1,204 @@
+package eventstore
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PharmaKart/gateway-svc/internal/testutil"
+	"github.com/PharmaKart/gateway-svc/pkg/config"
+	"github.com/PharmaKart/gateway-svc/pkg/utils"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestMain(m *testing.M) {
+	utils.InitLogger(&config.Config{LogLevel: "panic"})
+	os.Exit(m.Run())
+}
+
+// newStores returns every Store implementation, with the Redis store backed by an in-process server
+func newStores(t *testing.T) map[string]testutil.Backend[Store] {
+	return testutil.Stores(t,
+		func(now func() time.Time) Store { return newMemoryStore(now) },
+		func(client *redis.Client) Store { return NewRedisStore(client, "test:") },
+	)
+}
+
+func TestStoreClaim(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name string
+		// steps are taken by owner "a" before owner "b" claims the event
+		steps      func(t *testing.T, store Store, eventID string)
+		wantStatus ClaimStatus
+	}{
+		{
+			name:       "unclaimed",
+			steps:      func(t *testing.T, store Store, eventID string) {},
+			wantStatus: Claimed,
+		},
+		{
+			name: "claimed by another owner",
+			steps: func(t *testing.T, store Store, eventID string) {
+				mustClaim(t, store, eventID, "a", Claimed)
+			},
+			wantStatus: InProgress,
+		},
+		{
+			name: "completed",
+			steps: func(t *testing.T, store Store, eventID string) {
+				mustClaim(t, store, eventID, "a", Claimed)
+				if err := store.Complete(ctx, eventID, "a", time.Hour); err != nil {
+					t.Fatalf("Complete: %v", err)
+				}
+			},
+			wantStatus: Processed,
+		},
+		{
+			name: "released",
+			steps: func(t *testing.T, store Store, eventID string) {
+				mustClaim(t, store, eventID, "a", Claimed)
+				if err := store.Release(ctx, eventID, "a"); err != nil {
+					t.Fatalf("Release: %v", err)
+				}
+			},
+			wantStatus: Claimed,
+		},
+		{
+			name: "completed by another owner",
+			steps: func(t *testing.T, store Store, eventID string) {
+				mustClaim(t, store, eventID, "a", Claimed)
+				if err := store.Complete(ctx, eventID, "c", time.Hour); err != nil {
+					t.Fatalf("Complete: %v", err)
+				}
+			},
+			wantStatus: InProgress,
+		},
+		{
+			name: "released by another owner",
+			steps: func(t *testing.T, store Store, eventID string) {
+				mustClaim(t, store, eventID, "a", Claimed)
+				if err := store.Release(ctx, eventID, "c"); err != nil {
+					t.Fatalf("Release: %v", err)
+				}
+			},
+			wantStatus: InProgress,
+		},
+		{
+			name: "released after completion",
+			steps: func(t *testing.T, store Store, eventID string) {
+				mustClaim(t, store, eventID, "a", Claimed)
+				if err := store.Complete(ctx, eventID, "a", time.Hour); err != nil {
+					t.Fatalf("Complete: %v", err)
+				}
+				if err := store.Release(ctx, eventID, "a"); err != nil {
+					t.Fatalf("Release: %v", err)
+				}
+			},
+			wantStatus: Processed,
+		},
+	}
+
+	for name, backend := range newStores(t) {
+		store := backend.Store
+		for _, tt := range tests {
+			t.Run(name+"/"+tt.name, func(t *testing.T) {
+				eventID := "evt_" + strings.ReplaceAll(tt.name, " ", "_")
+				tt.steps(t, store, eventID)
+				mustClaim(t, store, eventID, "b", tt.wantStatus)
+			})
+		}
+	}
+}
+
+func TestStoreLeases(t *testing.T) {
+	ctx := context.Background()
+
+	for name, backend := range newStores(t) {
+		store := backend.Store
+		t.Run(name, func(t *testing.T) {
+			const lease = 200 * time.Millisecond
+
+			if status, err := store.Claim(ctx, "expiring", "a", lease); err != nil || status != Claimed {
+				t.Fatalf("Claim = %v, %v, want claimed", status, err)
+			}
+			if status, err := store.Claim(ctx, "extended", "a", lease); err != nil || status != Claimed {
+				t.Fatalf("Claim = %v, %v, want claimed", status, err)
+			}
+
+			if held, err := store.Extend(ctx, "extended", "b", time.Hour); err != nil || held {
+				t.Fatalf("Extend by another owner = %v, %v, want not held", held, err)
+			}
+			if held, err := store.Extend(ctx, "extended", "a", time.Hour); err != nil || !held {
+				t.Fatalf("Extend = %v, %v, want held", held, err)
+			}
+
+			backend.Advance(2 * lease)
+
+			if status, err := store.Claim(ctx, "expiring", "b", lease); err != nil || status != Claimed {
+				t.Fatalf("Claim after the lease = %v, %v, want claimed", status, err)
+			}
+			if status, err := store.Claim(ctx, "extended", "b", lease); err != nil || status != InProgress {
+				t.Fatalf("Claim of an extended lease = %v, %v, want in progress", status, err)
+			}
+			if held, err := store.Extend(ctx, "expiring", "a", lease); err != nil || held {
+				t.Fatalf("Extend of an expired claim = %v, %v, want not held", held, err)
+			}
+		})
+	}
+}
+
+func TestStoreRetention(t *testing.T) {
+	ctx := context.Background()
+
+	for name, backend := range newStores(t) {
+		store := backend.Store
+		t.Run(name, func(t *testing.T) {
+			if _, err := store.Claim(ctx, "evt", "a", time.Hour); err != nil {
+				t.Fatalf("Claim: %v", err)
+			}
+			if err := store.Complete(ctx, "evt", "a", 200*time.Millisecond); err != nil {
+				t.Fatalf("Complete: %v", err)
+			}
+			if held, err := store.Extend(ctx, "evt", "a", time.Hour); err != nil || held {
+				t.Fatalf("Extend after completion = %v, %v, want not held", held, err)
+			}
+
+			backend.Advance(400 * time.Millisecond)
+
+			if status, err := store.Claim(ctx, "evt", "b", time.Hour); err != nil || status != Claimed {
+				t.Fatalf("Claim after the retention = %v, %v, want claimed", status, err)
+			}
+		})
+	}
+}
+
+func TestRedisStoreUnavailable(t *testing.T) {
+	server := miniredis.RunT(t)
+	store := NewRedisStore(redis.NewClient(&redis.Options{Addr: server.Addr(), MaxRetries: -1}), "test:")
+	defer store.Close()
+	server.Close()
+
+	if _, err := store.Claim(context.Background(), "evt", "a", time.Minute); err == nil {
+		t.Fatal("Claim succeeded without a Redis server")
+	}
+	if _, err := store.Extend(context.Background(), "evt", "a", time.Minute); err == nil {
+		t.Fatal("Extend succeeded without a Redis server")
+	}
+}
+
+func mustClaim(t *testing.T, store Store, eventID, owner string, want ClaimStatus) {
+	t.Helper()
+
+	status, err := store.Claim(context.Background(), eventID, owner, time.Hour)
+	if err != nil {
+		t.Fatalf("Claim: %v", err)
+	}
+	if status != want {
+		t.Fatalf("Claim by %s = %v, want %v", owner, status, want)
+	}
+}
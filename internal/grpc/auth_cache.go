@@ -0,0 +1,274 @@
+package grpc
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/PharmaKart/gateway-svc/internal/auth"
+	"github.com/PharmaKart/gateway-svc/internal/proto"
+	"github.com/PharmaKart/gateway-svc/internal/revocation"
+	"github.com/PharmaKart/gateway-svc/pkg/utils"
+)
+
+// TokenCacheStats reports how effective the token cache is
+type TokenCacheStats struct {
+	Hits         uint64  `json:"hits"`
+	NegativeHits uint64  `json:"negative_hits"`
+	Misses       uint64  `json:"misses"`
+	Evictions    uint64  `json:"evictions"`
+	Size         int     `json:"size"`
+	HitRatio     float64 `json:"hit_ratio"`
+}
+
+type tokenCacheEntry struct {
+	key       string
+	resp      *proto.VerifyTokenResponse
+	expiresAt time.Time
+}
+
+// TokenCache is a bounded LRU cache of VerifyToken results keyed by token hash
+type TokenCache struct {
+	mu          sync.Mutex
+	size        int
+	ttl         time.Duration
+	negativeTTL time.Duration
+	entries     map[string]*list.Element
+	order       *list.List
+
+	hits         atomic.Uint64
+	negativeHits atomic.Uint64
+	misses       atomic.Uint64
+	evictions    atomic.Uint64
+}
+
+// NewTokenCache creates a cache holding up to size tokens. Successful verifications are kept for ttl
+// (or until the token expires, whichever is sooner) and failed ones for negativeTTL.
+func NewTokenCache(size int, ttl, negativeTTL time.Duration) *TokenCache {
+	return &TokenCache{
+		size:        size,
+		ttl:         ttl,
+		negativeTTL: negativeTTL,
+		entries:     make(map[string]*list.Element),
+		order:       list.New(),
+	}
+}
+
+// Get returns the cached verification result for token, if any
+func (c *TokenCache) Get(token string) (*proto.VerifyTokenResponse, bool) {
+	key := auth.HashToken(token)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[key]
+	if !ok {
+		c.misses.Add(1)
+		return nil, false
+	}
+
+	entry := elem.Value.(*tokenCacheEntry)
+	if time.Now().After(entry.expiresAt) {
+		c.removeElement(elem)
+		c.misses.Add(1)
+		return nil, false
+	}
+
+	c.order.MoveToFront(elem)
+	if entry.resp.Success {
+		c.hits.Add(1)
+	} else {
+		c.negativeHits.Add(1)
+	}
+
+	return entry.resp, true
+}
+
+// Set stores the verification result for token
+func (c *TokenCache) Set(token string, resp *proto.VerifyTokenResponse) {
+	if c.size <= 0 {
+		return
+	}
+
+	ttl := c.negativeTTL
+	if resp.Success {
+		ttl = c.ttl
+	}
+	expiresAt := time.Now().Add(ttl)
+
+	// Never keep a token past its own expiry
+	if exp, ok := auth.ClaimTime(token, "exp"); ok && exp.Before(expiresAt) {
+		expiresAt = exp
+	}
+	if !expiresAt.After(time.Now()) {
+		return
+	}
+
+	key := auth.HashToken(token)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[key]; ok {
+		entry := elem.Value.(*tokenCacheEntry)
+		entry.resp = resp
+		entry.expiresAt = expiresAt
+		c.order.MoveToFront(elem)
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(&tokenCacheEntry{
+		key:       key,
+		resp:      resp,
+		expiresAt: expiresAt,
+	})
+
+	for c.order.Len() > c.size {
+		c.removeElement(c.order.Back())
+		c.evictions.Add(1)
+	}
+}
+
+// Invalidate drops token from the cache
+func (c *TokenCache) Invalidate(token string) {
+	key := auth.HashToken(token)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[key]; ok {
+		c.removeElement(elem)
+	}
+}
+
+// InvalidateUser drops every cached token of userID
+func (c *TokenCache) InvalidateUser(userID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for elem := c.order.Front(); elem != nil; {
+		next := elem.Next()
+		if elem.Value.(*tokenCacheEntry).resp.UserId == userID {
+			c.removeElement(elem)
+		}
+		elem = next
+	}
+}
+
+// Stats returns the cache counters
+func (c *TokenCache) Stats() TokenCacheStats {
+	c.mu.Lock()
+	size := c.order.Len()
+	c.mu.Unlock()
+
+	stats := TokenCacheStats{
+		Hits:         c.hits.Load(),
+		NegativeHits: c.negativeHits.Load(),
+		Misses:       c.misses.Load(),
+		Evictions:    c.evictions.Load(),
+		Size:         size,
+	}
+
+	if total := stats.Hits + stats.NegativeHits + stats.Misses; total > 0 {
+		stats.HitRatio = float64(stats.Hits+stats.NegativeHits) / float64(total)
+	}
+
+	return stats
+}
+
+func (c *TokenCache) removeElement(elem *list.Element) {
+	c.order.Remove(elem)
+	delete(c.entries, elem.Value.(*tokenCacheEntry).key)
+}
+
+type cachedAuthClient struct {
+	AuthClient
+	cache       *TokenCache
+	revocations *revocation.List
+}
+
+// NewCachedAuthClient wraps client so that VerifyToken results are served from cache when possible.
+// Cached tokens are checked against revocations, which may have been made through another replica.
+func NewCachedAuthClient(client AuthClient, cache *TokenCache, revocations *revocation.List) AuthClient {
+	return &cachedAuthClient{
+		AuthClient:  client,
+		cache:       cache,
+		revocations: revocations,
+	}
+}
+
+func (c *cachedAuthClient) VerifyToken(ctx context.Context, req *proto.VerifyTokenRequest) (*proto.VerifyTokenResponse, error) {
+	if resp, ok := c.cache.Get(req.Token); ok {
+		if !resp.Success {
+			return resp, nil
+		}
+
+		revoked, err := c.revocations.Revoked(ctx, req.Token, resp.UserId)
+		if err == nil && !revoked {
+			return resp, nil
+		}
+
+		c.cache.Invalidate(req.Token)
+		if revoked {
+			return revokedTokenResponse(), nil
+		}
+
+		// Verify the token again, with the wrapped client checking revocations itself or asking auth-svc
+		utils.WarnContext(ctx, "Failed to check token revocation, verifying token again", map[string]interface{}{
+			"error": err,
+		})
+	}
+
+	resp, err := c.AuthClient.VerifyToken(ctx, req)
+	if err != nil {
+		// Transport errors say nothing about the token, so they are never cached
+		return nil, err
+	}
+
+	c.cache.Set(req.Token, resp)
+
+	return resp, nil
+}
+
+func (c *cachedAuthClient) Logout(ctx context.Context, req *proto.LogoutRequest) (*proto.LogoutResponse, error) {
+	resp, err := c.AuthClient.Logout(ctx, req)
+	if err == nil && resp.Success {
+		c.cache.Invalidate(req.Token)
+		if err := c.revocations.RevokeToken(ctx, req.Token); err != nil {
+			// auth-svc has revoked the token, but other replicas may accept it until their cache expires
+			utils.ErrorContext(ctx, "Failed to share token revocation", map[string]interface{}{
+				"error": err,
+			})
+		}
+	}
+
+	return resp, err
+}
+
+func (c *cachedAuthClient) RevokeAllSessions(ctx context.Context, req *proto.RevokeAllSessionsRequest) (*proto.RevokeAllSessionsResponse, error) {
+	resp, err := c.AuthClient.RevokeAllSessions(ctx, req)
+	if err == nil && resp.Success {
+		c.cache.InvalidateUser(req.UserId)
+		if err := c.revocations.RevokeUser(ctx, req.UserId); err != nil {
+			utils.ErrorContext(ctx, "Failed to share session revocation", map[string]interface{}{
+				"error":   err,
+				"user_id": req.UserId,
+			})
+		}
+	}
+
+	return resp, err
+}
+
+func revokedTokenResponse() *proto.VerifyTokenResponse {
+	return &proto.VerifyTokenResponse{
+		Success: false,
+		Message: "Token has been revoked",
+		Error: &proto.Error{
+			Type:    "AUTH_ERROR",
+			Message: "Token has been revoked",
+		},
+	}
+}
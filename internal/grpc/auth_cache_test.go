@@ -0,0 +1,312 @@
+package grpc
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/PharmaKart/gateway-svc/internal/proto"
+	"github.com/PharmaKart/gateway-svc/internal/revocation"
+	"github.com/PharmaKart/gateway-svc/pkg/config"
+	"github.com/PharmaKart/gateway-svc/pkg/utils"
+)
+
+func TestMain(m *testing.M) {
+	utils.InitLogger(&config.Config{LogLevel: "panic"})
+	os.Exit(m.Run())
+}
+
+func newRevocations() *revocation.List {
+	return revocation.NewList(revocation.NewMemoryStore(), time.Hour)
+}
+
+// unsignedToken builds a JWT-shaped token carrying claims. The cache never verifies signatures.
+func unsignedToken(t *testing.T, claims map[string]interface{}) string {
+	t.Helper()
+
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatalf("marshal claims: %v", err)
+	}
+	return "eyJhbGciOiJub25lIn0." + base64.RawURLEncoding.EncodeToString(payload) + ".sig"
+}
+
+// fakeAuthClient answers VerifyToken with verify and counts the calls. Logout and RevokeAllSessions
+// always succeed.
+type fakeAuthClient struct {
+	AuthClient
+	verify func(token string) (*proto.VerifyTokenResponse, error)
+	calls  atomic.Int64
+}
+
+func (c *fakeAuthClient) VerifyToken(ctx context.Context, req *proto.VerifyTokenRequest) (*proto.VerifyTokenResponse, error) {
+	c.calls.Add(1)
+	return c.verify(req.Token)
+}
+
+func (c *fakeAuthClient) Logout(ctx context.Context, req *proto.LogoutRequest) (*proto.LogoutResponse, error) {
+	return &proto.LogoutResponse{Success: true}, nil
+}
+
+func (c *fakeAuthClient) RevokeAllSessions(ctx context.Context, req *proto.RevokeAllSessionsRequest) (*proto.RevokeAllSessionsResponse, error) {
+	return &proto.RevokeAllSessionsResponse{Success: true}, nil
+}
+
+func verified(userID, role string) func(token string) (*proto.VerifyTokenResponse, error) {
+	return func(token string) (*proto.VerifyTokenResponse, error) {
+		return &proto.VerifyTokenResponse{Success: true, UserId: userID, Role: role}, nil
+	}
+}
+
+func TestTokenCacheGetSet(t *testing.T) {
+	cache := NewTokenCache(10, time.Minute, time.Second)
+	token := unsignedToken(t, map[string]interface{}{"sub": "u1"})
+
+	if _, ok := cache.Get(token); ok {
+		t.Fatal("empty cache returned a result")
+	}
+
+	cache.Set(token, &proto.VerifyTokenResponse{Success: true, UserId: "u1"})
+	resp, ok := cache.Get(token)
+	if !ok || resp.UserId != "u1" {
+		t.Fatalf("Get = %v, %v, want cached result for u1", resp, ok)
+	}
+
+	cache.Invalidate(token)
+	if _, ok := cache.Get(token); ok {
+		t.Fatal("invalidated token is still cached")
+	}
+
+	stats := cache.Stats()
+	if stats.Hits != 1 || stats.Misses != 2 || stats.Size != 0 {
+		t.Fatalf("stats = %+v, want 1 hit, 2 misses and no entries", stats)
+	}
+}
+
+func TestTokenCacheExpiry(t *testing.T) {
+	tests := []struct {
+		name    string
+		claims  map[string]interface{}
+		resp    *proto.VerifyTokenResponse
+		wait    time.Duration
+		wantHit bool
+	}{
+		{
+			name:    "within ttl",
+			claims:  map[string]interface{}{"sub": "u1"},
+			resp:    &proto.VerifyTokenResponse{Success: true},
+			wantHit: true,
+		},
+		{
+			name:   "failures use the negative ttl",
+			claims: map[string]interface{}{"sub": "u1"},
+			resp:   &proto.VerifyTokenResponse{Success: false},
+			wait:   80 * time.Millisecond,
+		},
+		{
+			name:   "bounded by the token expiry",
+			claims: map[string]interface{}{"sub": "u1", "exp": time.Now().Add(time.Second).Unix()},
+			resp:   &proto.VerifyTokenResponse{Success: true},
+			wait:   1100 * time.Millisecond,
+		},
+		{
+			name:   "expired tokens are not cached",
+			claims: map[string]interface{}{"sub": "u1", "exp": time.Now().Add(-time.Second).Unix()},
+			resp:   &proto.VerifyTokenResponse{Success: true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			cache := NewTokenCache(10, time.Minute, 50*time.Millisecond)
+			token := unsignedToken(t, tt.claims)
+
+			cache.Set(token, tt.resp)
+			time.Sleep(tt.wait)
+
+			if _, ok := cache.Get(token); ok != tt.wantHit {
+				t.Fatalf("cached = %v, want %v", ok, tt.wantHit)
+			}
+		})
+	}
+}
+
+func TestTokenCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	cache := NewTokenCache(2, time.Minute, time.Minute)
+	tokens := make([]string, 3)
+	for i := range tokens {
+		tokens[i] = unsignedToken(t, map[string]interface{}{"sub": fmt.Sprint(i)})
+	}
+
+	cache.Set(tokens[0], &proto.VerifyTokenResponse{Success: true})
+	cache.Set(tokens[1], &proto.VerifyTokenResponse{Success: true})
+	cache.Get(tokens[0])
+	cache.Set(tokens[2], &proto.VerifyTokenResponse{Success: true})
+
+	if _, ok := cache.Get(tokens[1]); ok {
+		t.Error("least recently used token was kept")
+	}
+	for _, i := range []int{0, 2} {
+		if _, ok := cache.Get(tokens[i]); !ok {
+			t.Errorf("token %d was evicted", i)
+		}
+	}
+	if stats := cache.Stats(); stats.Evictions != 1 || stats.Size != 2 {
+		t.Fatalf("stats = %+v, want 1 eviction and 2 entries", stats)
+	}
+}
+
+func TestTokenCacheDisabled(t *testing.T) {
+	cache := NewTokenCache(0, time.Minute, time.Minute)
+	token := unsignedToken(t, map[string]interface{}{"sub": "u1"})
+
+	cache.Set(token, &proto.VerifyTokenResponse{Success: true})
+	if _, ok := cache.Get(token); ok {
+		t.Fatal("cache of size 0 stored a token")
+	}
+}
+
+func TestCachedAuthClientVerifiesOnce(t *testing.T) {
+	backend := &fakeAuthClient{verify: verified("u1", "customer")}
+	client := NewCachedAuthClient(backend, NewTokenCache(10, time.Minute, time.Minute), newRevocations())
+	token := unsignedToken(t, map[string]interface{}{"sub": "u1"})
+
+	for i := 0; i < 3; i++ {
+		resp, err := client.VerifyToken(context.Background(), &proto.VerifyTokenRequest{Token: token})
+		if err != nil || !resp.Success || resp.UserId != "u1" {
+			t.Fatalf("VerifyToken = %v, %v", resp, err)
+		}
+	}
+
+	if calls := backend.calls.Load(); calls != 1 {
+		t.Fatalf("auth-svc was called %d times, want 1", calls)
+	}
+}
+
+func TestCachedAuthClientDoesNotCacheErrors(t *testing.T) {
+	backend := &fakeAuthClient{verify: func(token string) (*proto.VerifyTokenResponse, error) {
+		return nil, errors.New("unavailable")
+	}}
+	client := NewCachedAuthClient(backend, NewTokenCache(10, time.Minute, time.Minute), newRevocations())
+	token := unsignedToken(t, map[string]interface{}{"sub": "u1"})
+
+	for i := 0; i < 2; i++ {
+		if _, err := client.VerifyToken(context.Background(), &proto.VerifyTokenRequest{Token: token}); err == nil {
+			t.Fatal("VerifyToken succeeded")
+		}
+	}
+
+	if calls := backend.calls.Load(); calls != 2 {
+		t.Fatalf("auth-svc was called %d times, want 2", calls)
+	}
+}
+
+func TestTokenCacheInvalidateUser(t *testing.T) {
+	cache := NewTokenCache(10, time.Minute, time.Minute)
+	first := unsignedToken(t, map[string]interface{}{"sub": "u1", "jti": "1"})
+	second := unsignedToken(t, map[string]interface{}{"sub": "u1", "jti": "2"})
+	other := unsignedToken(t, map[string]interface{}{"sub": "u2"})
+
+	cache.Set(first, &proto.VerifyTokenResponse{Success: true, UserId: "u1"})
+	cache.Set(second, &proto.VerifyTokenResponse{Success: true, UserId: "u1"})
+	cache.Set(other, &proto.VerifyTokenResponse{Success: true, UserId: "u2"})
+
+	cache.InvalidateUser("u1")
+
+	for _, token := range []string{first, second} {
+		if _, ok := cache.Get(token); ok {
+			t.Error("token of u1 is still cached")
+		}
+	}
+	if _, ok := cache.Get(other); !ok {
+		t.Error("token of u2 was dropped")
+	}
+}
+
+func TestCachedAuthClientHonorsRevocationsFromOtherReplicas(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name   string
+		claims map[string]interface{}
+		revoke func(ctx context.Context, client AuthClient, token string) error
+	}{
+		{
+			name:   "logout",
+			claims: map[string]interface{}{"sub": "u1", "iat": now.Unix()},
+			revoke: func(ctx context.Context, client AuthClient, token string) error {
+				_, err := client.Logout(ctx, &proto.LogoutRequest{Token: token})
+				return err
+			},
+		},
+		{
+			name:   "all sessions",
+			claims: map[string]interface{}{"sub": "u1", "iat": now.Add(-time.Second).Unix()},
+			revoke: func(ctx context.Context, client AuthClient, token string) error {
+				_, err := client.RevokeAllSessions(ctx, &proto.RevokeAllSessionsRequest{UserId: "u1"})
+				return err
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			store := revocation.NewMemoryStore()
+			backend := &fakeAuthClient{verify: verified("u1", "customer")}
+
+			// Two replicas sharing the revocation store, each with its own cache
+			first := NewCachedAuthClient(backend, NewTokenCache(10, time.Minute, time.Minute), revocation.NewList(store, time.Hour))
+			second := NewCachedAuthClient(backend, NewTokenCache(10, time.Minute, time.Minute), revocation.NewList(store, time.Hour))
+			token := unsignedToken(t, tt.claims)
+
+			if resp, err := second.VerifyToken(ctx, &proto.VerifyTokenRequest{Token: token}); err != nil || !resp.Success {
+				t.Fatalf("VerifyToken = %v, %v", resp, err)
+			}
+
+			if err := tt.revoke(ctx, first, token); err != nil {
+				t.Fatalf("revoke: %v", err)
+			}
+
+			resp, err := second.VerifyToken(ctx, &proto.VerifyTokenRequest{Token: token})
+			if err != nil {
+				t.Fatalf("VerifyToken: %v", err)
+			}
+			if resp.Success {
+				t.Fatal("revoked token was served from the cache of another replica")
+			}
+		})
+	}
+}
+
+func TestCachedAuthClientReverifiesWhenRevocationIsUndetermined(t *testing.T) {
+	ctx := context.Background()
+	store := revocation.NewMemoryStore()
+	backend := &fakeAuthClient{verify: verified("u1", "customer")}
+	first := NewCachedAuthClient(backend, NewTokenCache(10, time.Minute, time.Minute), revocation.NewList(store, time.Hour))
+	second := NewCachedAuthClient(backend, NewTokenCache(10, time.Minute, time.Minute), revocation.NewList(store, time.Hour))
+	// Without iat the token cannot be matched against the revocation of its user's sessions
+	token := unsignedToken(t, map[string]interface{}{"sub": "u1"})
+
+	if _, err := second.VerifyToken(ctx, &proto.VerifyTokenRequest{Token: token}); err != nil {
+		t.Fatalf("VerifyToken: %v", err)
+	}
+	if _, err := first.RevokeAllSessions(ctx, &proto.RevokeAllSessionsRequest{UserId: "u1"}); err != nil {
+		t.Fatalf("RevokeAllSessions: %v", err)
+	}
+	if _, err := second.VerifyToken(ctx, &proto.VerifyTokenRequest{Token: token}); err != nil {
+		t.Fatalf("VerifyToken: %v", err)
+	}
+
+	// The cached result was dropped and auth-svc decided again
+	if calls := backend.calls.Load(); calls != 2 {
+		t.Fatalf("auth-svc was called %d times, want 2", calls)
+	}
+}
@@ -11,6 +11,9 @@ type AuthClient interface {
 	Register(ctx context.Context, req *proto.RegisterRequest) (*proto.RegisterResponse, error)
 	Login(ctx context.Context, req *proto.LoginRequest) (*proto.LoginResponse, error)
 	VerifyToken(ctx context.Context, req *proto.VerifyTokenRequest) (*proto.VerifyTokenResponse, error)
+	Refresh(ctx context.Context, req *proto.RefreshRequest) (*proto.RefreshResponse, error)
+	Logout(ctx context.Context, req *proto.LogoutRequest) (*proto.LogoutResponse, error)
+	RevokeAllSessions(ctx context.Context, req *proto.RevokeAllSessionsRequest) (*proto.RevokeAllSessionsResponse, error)
 }
 
 type authClient struct {
@@ -34,3 +37,15 @@ func (c *authClient) Login(ctx context.Context, req *proto.LoginRequest) (*proto
 func (c *authClient) VerifyToken(ctx context.Context, req *proto.VerifyTokenRequest) (*proto.VerifyTokenResponse, error) {
 	return c.client.VerifyToken(ctx, req)
 }
+
+func (c *authClient) Refresh(ctx context.Context, req *proto.RefreshRequest) (*proto.RefreshResponse, error) {
+	return c.client.Refresh(ctx, req)
+}
+
+func (c *authClient) Logout(ctx context.Context, req *proto.LogoutRequest) (*proto.LogoutResponse, error) {
+	return c.client.Logout(ctx, req)
+}
+
+func (c *authClient) RevokeAllSessions(ctx context.Context, req *proto.RevokeAllSessionsRequest) (*proto.RevokeAllSessionsResponse, error) {
+	return c.client.RevokeAllSessions(ctx, req)
+}
@@ -0,0 +1,69 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+
+	"github.com/PharmaKart/gateway-svc/internal/auth"
+	"github.com/PharmaKart/gateway-svc/internal/proto"
+	"github.com/PharmaKart/gateway-svc/internal/revocation"
+)
+
+type localAuthClient struct {
+	AuthClient
+	verifier    *auth.Verifier
+	revocations *revocation.List
+}
+
+// NewLocalAuthClient wraps client so that tokens are verified locally against the JWKS key set, and
+// checked against the revocations shared by every replica. Tokens signed by an unknown key, without
+// a role claim, or whose revocation cannot be checked are still verified by auth-svc.
+func NewLocalAuthClient(client AuthClient, verifier *auth.Verifier, revocations *revocation.List) AuthClient {
+	return &localAuthClient{
+		AuthClient:  client,
+		verifier:    verifier,
+		revocations: revocations,
+	}
+}
+
+func (c *localAuthClient) VerifyToken(ctx context.Context, req *proto.VerifyTokenRequest) (*proto.VerifyTokenResponse, error) {
+	claims, err := c.verifier.Verify(ctx, req.Token)
+	if errors.Is(err, auth.ErrUnknownKey) {
+		return c.AuthClient.VerifyToken(ctx, req)
+	}
+
+	if err != nil {
+		message := "Invalid token"
+		if errors.Is(err, auth.ErrTokenExpired) {
+			message = "Token has expired"
+		}
+
+		return &proto.VerifyTokenResponse{
+			Success: false,
+			Message: message,
+			Error: &proto.Error{
+				Type:    "AUTH_ERROR",
+				Message: message,
+			},
+		}, nil
+	}
+
+	if claims.User() == "" || claims.Role == "" {
+		return c.AuthClient.VerifyToken(ctx, req)
+	}
+
+	revoked, err := c.revocations.Revoked(ctx, req.Token, claims.User())
+	if err != nil {
+		return c.AuthClient.VerifyToken(ctx, req)
+	}
+	if revoked {
+		return revokedTokenResponse(), nil
+	}
+
+	return &proto.VerifyTokenResponse{
+		Success: true,
+		Message: "Token verified",
+		UserId:  claims.User(),
+		Role:    claims.Role,
+	}, nil
+}
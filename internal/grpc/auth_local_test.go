@@ -0,0 +1,181 @@
+package grpc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/PharmaKart/gateway-svc/internal/auth"
+	"github.com/PharmaKart/gateway-svc/internal/proto"
+	"github.com/PharmaKart/gateway-svc/internal/revocation"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// newLocalVerifier returns a verifier trusting a single RSA key, and that key
+func newLocalVerifier(t *testing.T) (*auth.Verifier, *rsa.PrivateKey) {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate RSA key: %v", err)
+	}
+
+	document, err := json.Marshal(map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "test",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}},
+	})
+	if err != nil {
+		t.Fatalf("marshal JWKS: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, document, 0o600); err != nil {
+		t.Fatalf("write JWKS: %v", err)
+	}
+
+	keys, err := auth.NewKeySet(path, 0)
+	if err != nil {
+		t.Fatalf("NewKeySet: %v", err)
+	}
+	t.Cleanup(keys.Close)
+
+	verifier, err := auth.NewVerifier(keys, "issuer", "gateway", 0)
+	if err != nil {
+		t.Fatalf("NewVerifier: %v", err)
+	}
+	return verifier, key
+}
+
+func signedToken(t *testing.T, key *rsa.PrivateKey, claims jwt.MapClaims) string {
+	t.Helper()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = "test"
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatalf("sign token: %v", err)
+	}
+	return signed
+}
+
+func TestLocalAuthClientHonorsSharedRevocations(t *testing.T) {
+	verifier, key := newLocalVerifier(t)
+	now := time.Now()
+
+	tests := []struct {
+		name string
+		// iat is the issue time of the token, relative to the revocation
+		iat         time.Time
+		revoke      func(ctx context.Context, revocations *revocation.List, token string) error
+		wantSuccess bool
+		wantCalls   int64
+	}{
+		{
+			name:        "not revoked",
+			iat:         now,
+			revoke:      func(ctx context.Context, revocations *revocation.List, token string) error { return nil },
+			wantSuccess: true,
+		},
+		{
+			name: "token revoked",
+			iat:  now,
+			revoke: func(ctx context.Context, revocations *revocation.List, token string) error {
+				return revocations.RevokeToken(ctx, token)
+			},
+		},
+		{
+			name: "issued in the second before the revocation",
+			iat:  now.Add(-time.Second),
+			revoke: func(ctx context.Context, revocations *revocation.List, token string) error {
+				return revocations.RevokeUser(ctx, "u1")
+			},
+		},
+		{
+			name: "issued after the revocation",
+			iat:  now.Add(2 * time.Second),
+			revoke: func(ctx context.Context, revocations *revocation.List, token string) error {
+				return revocations.RevokeUser(ctx, "u1")
+			},
+			wantSuccess: true,
+		},
+		{
+			name: "another user revoked",
+			iat:  now,
+			revoke: func(ctx context.Context, revocations *revocation.List, token string) error {
+				return revocations.RevokeUser(ctx, "u2")
+			},
+			wantSuccess: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			store := revocation.NewMemoryStore()
+			backend := &fakeAuthClient{verify: verified("u1", "customer")}
+			client := NewLocalAuthClient(backend, verifier, revocation.NewList(store, time.Hour))
+
+			token := signedToken(t, key, jwt.MapClaims{
+				"sub":  "u1",
+				"role": "customer",
+				"iss":  "issuer",
+				"aud":  "gateway",
+				"iat":  tt.iat.Unix(),
+				"exp":  now.Add(time.Hour).Unix(),
+			})
+
+			// Revoke through another replica sharing the store
+			if err := tt.revoke(ctx, revocation.NewList(store, time.Hour), token); err != nil {
+				t.Fatalf("revoke: %v", err)
+			}
+
+			resp, err := client.VerifyToken(ctx, &proto.VerifyTokenRequest{Token: token})
+			if err != nil {
+				t.Fatalf("VerifyToken: %v", err)
+			}
+			if resp.Success != tt.wantSuccess {
+				t.Fatalf("success = %v, want %v", resp.Success, tt.wantSuccess)
+			}
+			if calls := backend.calls.Load(); calls != tt.wantCalls {
+				t.Fatalf("auth-svc was called %d times, want %d", calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestLocalAuthClientFallsBackWhenRevocationIsUndetermined(t *testing.T) {
+	verifier, key := newLocalVerifier(t)
+	ctx := context.Background()
+	revocations := revocation.NewList(revocation.NewMemoryStore(), time.Hour)
+	backend := &fakeAuthClient{verify: verified("u1", "customer")}
+	client := NewLocalAuthClient(backend, verifier, revocations)
+
+	token := signedToken(t, key, jwt.MapClaims{
+		"sub":  "u1",
+		"role": "customer",
+		"iss":  "issuer",
+		"aud":  "gateway",
+		"exp":  time.Now().Add(time.Hour).Unix(),
+	})
+	if err := revocations.RevokeUser(ctx, "u1"); err != nil {
+		t.Fatalf("RevokeUser: %v", err)
+	}
+
+	if _, err := client.VerifyToken(ctx, &proto.VerifyTokenRequest{Token: token}); err != nil {
+		t.Fatalf("VerifyToken: %v", err)
+	}
+	if calls := backend.calls.Load(); calls != 1 {
+		t.Fatalf("auth-svc was called %d times, want 1", calls)
+	}
+}
@@ -0,0 +1,54 @@
+package grpc
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// DownstreamCall describes a gRPC call made while handling a request
+type DownstreamCall struct {
+	Service   string  `json:"service"`
+	Method    string  `json:"method"`
+	Code      string  `json:"code"`
+	LatencyMs float64 `json:"latency_ms"`
+}
+
+// CallLog collects the downstream calls made with a request context
+type CallLog struct {
+	mu    sync.Mutex
+	calls []DownstreamCall
+}
+
+type callLogKey struct{}
+
+// ContextWithCallLog returns a copy of ctx that records the calls made on instrumented connections into the returned log
+func ContextWithCallLog(ctx context.Context) (context.Context, *CallLog) {
+	log := &CallLog{}
+	return context.WithValue(ctx, callLogKey{}, log), log
+}
+
+// Calls returns the calls recorded so far
+func (l *CallLog) Calls() []DownstreamCall {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return append([]DownstreamCall(nil), l.calls...)
+}
+
+func recordCall(ctx context.Context, service, method, code string, latency time.Duration) {
+	log, ok := ctx.Value(callLogKey{}).(*CallLog)
+	if !ok {
+		return
+	}
+
+	log.mu.Lock()
+	defer log.mu.Unlock()
+
+	log.calls = append(log.calls, DownstreamCall{
+		Service:   service,
+		Method:    method,
+		Code:      code,
+		LatencyMs: float64(latency.Microseconds()) / 1000,
+	})
+}
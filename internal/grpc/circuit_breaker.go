@@ -0,0 +1,200 @@
+package grpc
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/PharmaKart/gateway-svc/pkg/utils"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Circuit breaker states
+const (
+	CircuitClosed   = "closed"
+	CircuitOpen     = "open"
+	CircuitHalfOpen = "half_open"
+)
+
+// CircuitBreakerOptions configures when a breaker opens and how it recovers
+type CircuitBreakerOptions struct {
+	// FailureThreshold is the number of consecutive failures that opens the breaker
+	FailureThreshold int
+	// OpenTimeout is how long the breaker stays open before letting probe calls through
+	OpenTimeout time.Duration
+	// HalfOpenMaxCalls is the number of concurrent probe calls allowed while half open
+	HalfOpenMaxCalls int
+}
+
+// CircuitBreakerState is a snapshot of a breaker for the admin endpoint
+type CircuitBreakerState struct {
+	Service             string     `json:"service"`
+	State               string     `json:"state"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	TotalFailures       uint64     `json:"total_failures"`
+	Rejected            uint64     `json:"rejected"`
+	OpenedAt            *time.Time `json:"opened_at,omitempty"`
+}
+
+// CircuitBreaker fails calls to a downstream service fast while it is unhealthy
+type CircuitBreaker struct {
+	service string
+	opts    CircuitBreakerOptions
+
+	mu                  sync.Mutex
+	state               string
+	consecutiveFailures int
+	totalFailures       uint64
+	rejected            uint64
+	openedAt            time.Time
+	probes              int
+}
+
+// NewCircuitBreaker creates a closed breaker for service
+func NewCircuitBreaker(service string, opts CircuitBreakerOptions) *CircuitBreaker {
+	if opts.HalfOpenMaxCalls <= 0 {
+		opts.HalfOpenMaxCalls = 1
+	}
+
+	return &CircuitBreaker{
+		service: service,
+		opts:    opts,
+		state:   CircuitClosed,
+	}
+}
+
+// WithCircuitBreaker guards every call on the connection with breaker
+func WithCircuitBreaker(breaker *CircuitBreaker) grpc.DialOption {
+	return grpc.WithChainUnaryInterceptor(breaker.interceptor())
+}
+
+// Service returns the name of the guarded service
+func (b *CircuitBreaker) Service() string {
+	return b.service
+}
+
+// State returns a snapshot of the breaker
+func (b *CircuitBreaker) State() CircuitBreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.advance(time.Now())
+
+	state := CircuitBreakerState{
+		Service:             b.service,
+		State:               b.state,
+		ConsecutiveFailures: b.consecutiveFailures,
+		TotalFailures:       b.totalFailures,
+		Rejected:            b.rejected,
+	}
+	if b.state != CircuitClosed {
+		openedAt := b.openedAt
+		state.OpenedAt = &openedAt
+	}
+
+	return state
+}
+
+func (b *CircuitBreaker) interceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if b.opts.FailureThreshold <= 0 {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+
+		probe, ok := b.allow()
+		if !ok {
+			return status.Errorf(codes.Unavailable, "%s is unavailable (circuit breaker open)", b.service)
+		}
+
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		b.record(ctx, probe, err)
+
+		return err
+	}
+}
+
+// allow reports whether a call is a half-open probe, and whether it may proceed
+func (b *CircuitBreaker) allow() (probe, ok bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.advance(time.Now())
+
+	switch b.state {
+	case CircuitOpen:
+		b.rejected++
+		return false, false
+	case CircuitHalfOpen:
+		if b.probes >= b.opts.HalfOpenMaxCalls {
+			b.rejected++
+			return false, false
+		}
+		b.probes++
+		return true, true
+	default:
+		return false, true
+	}
+}
+
+func (b *CircuitBreaker) record(ctx context.Context, probe bool, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if probe {
+		b.probes--
+	}
+
+	// A caller that went away, or ran out of its own request budget, says nothing about the health of
+	// the service. Deadlines of the call itself are set below the breaker and do not end ctx.
+	code := status.Code(err)
+	if code == codes.Canceled || (code == codes.DeadlineExceeded && ctx.Err() != nil) {
+		return
+	}
+
+	if !isBreakerFailure(err) {
+		if b.state != CircuitClosed {
+			utils.Info("Circuit breaker closed", map[string]interface{}{
+				"service": b.service,
+			})
+		}
+		b.state = CircuitClosed
+		b.consecutiveFailures = 0
+		return
+	}
+
+	b.totalFailures++
+	b.consecutiveFailures++
+
+	if b.state == CircuitHalfOpen || b.consecutiveFailures >= b.opts.FailureThreshold {
+		if b.state != CircuitOpen {
+			utils.Warn("Circuit breaker opened", map[string]interface{}{
+				"service":              b.service,
+				"consecutive_failures": b.consecutiveFailures,
+				"error":                err,
+			})
+		}
+		b.state = CircuitOpen
+		b.openedAt = time.Now()
+	}
+}
+
+// advance moves an open breaker to half open once its timeout has passed
+func (b *CircuitBreaker) advance(now time.Time) {
+	if b.state == CircuitOpen && now.Sub(b.openedAt) >= b.opts.OpenTimeout {
+		b.state = CircuitHalfOpen
+		b.probes = 0
+	}
+}
+
+// isBreakerFailure reports whether err indicates the service itself is unhealthy.
+// Application errors such as NotFound or InvalidArgument do not count.
+func isBreakerFailure(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.Internal, codes.Unknown, codes.DataLoss:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,139 @@
+package grpc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// call makes one call through breaker that ends with err
+func call(ctx context.Context, breaker *CircuitBreaker, err error) error {
+	return breaker.interceptor()(ctx, "/order.OrderService/GetOrder", nil, nil, nil,
+		func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+			return err
+		})
+}
+
+func newTestBreaker() *CircuitBreaker {
+	return NewCircuitBreaker("order-svc", CircuitBreakerOptions{
+		FailureThreshold: 3,
+		OpenTimeout:      30 * time.Millisecond,
+		HalfOpenMaxCalls: 1,
+	})
+}
+
+func TestCircuitBreakerCountsFailures(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		wantState string
+	}{
+		{"unavailable", status.Error(codes.Unavailable, "down"), CircuitOpen},
+		{"internal", status.Error(codes.Internal, "boom"), CircuitOpen},
+		{"call deadline", status.Error(codes.DeadlineExceeded, "slow"), CircuitOpen},
+		{"not found", status.Error(codes.NotFound, "no order"), CircuitClosed},
+		{"invalid argument", status.Error(codes.InvalidArgument, "bad"), CircuitClosed},
+		{"cancelled", status.Error(codes.Canceled, "gone"), CircuitClosed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			breaker := newTestBreaker()
+			for i := 0; i < 3; i++ {
+				call(context.Background(), breaker, tt.err)
+			}
+
+			if state := breaker.State().State; state != tt.wantState {
+				t.Fatalf("state = %q, want %q", state, tt.wantState)
+			}
+		})
+	}
+}
+
+func TestCircuitBreakerIgnoresCallerDeadline(t *testing.T) {
+	breaker := newTestBreaker()
+
+	// The request budget of the caller ran out
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	<-ctx.Done()
+
+	for i := 0; i < 3; i++ {
+		call(ctx, breaker, status.Error(codes.DeadlineExceeded, "context deadline exceeded"))
+	}
+
+	state := breaker.State()
+	if state.State != CircuitClosed || state.ConsecutiveFailures != 0 {
+		t.Fatalf("state = %+v, want closed without failures", state)
+	}
+}
+
+func TestCircuitBreakerNeutralErrorsDoNotReset(t *testing.T) {
+	breaker := newTestBreaker()
+	unavailable := status.Error(codes.Unavailable, "down")
+
+	call(context.Background(), breaker, unavailable)
+	call(context.Background(), breaker, unavailable)
+	call(context.Background(), breaker, status.Error(codes.Canceled, "gone"))
+	call(context.Background(), breaker, unavailable)
+
+	if state := breaker.State().State; state != CircuitOpen {
+		t.Fatalf("state = %q, want %q", state, CircuitOpen)
+	}
+}
+
+func TestCircuitBreakerRecovers(t *testing.T) {
+	breaker := newTestBreaker()
+	unavailable := status.Error(codes.Unavailable, "down")
+
+	for i := 0; i < 3; i++ {
+		call(context.Background(), breaker, unavailable)
+	}
+
+	// Calls fail fast while the breaker is open
+	if err := call(context.Background(), breaker, nil); status.Code(err) != codes.Unavailable {
+		t.Fatalf("call while open = %v, want Unavailable", err)
+	}
+	if rejected := breaker.State().Rejected; rejected != 1 {
+		t.Fatalf("rejected = %d, want 1", rejected)
+	}
+
+	// A failed probe opens the breaker again
+	time.Sleep(40 * time.Millisecond)
+	if state := breaker.State().State; state != CircuitHalfOpen {
+		t.Fatalf("state = %q, want %q", state, CircuitHalfOpen)
+	}
+	call(context.Background(), breaker, unavailable)
+	if state := breaker.State().State; state != CircuitOpen {
+		t.Fatalf("state after failed probe = %q, want %q", state, CircuitOpen)
+	}
+
+	// A successful probe closes it
+	time.Sleep(40 * time.Millisecond)
+	if err := call(context.Background(), breaker, nil); err != nil {
+		t.Fatalf("probe: %v", err)
+	}
+	if state := breaker.State(); state.State != CircuitClosed || state.OpenedAt != nil {
+		t.Fatalf("state after probe = %+v, want closed", state)
+	}
+}
+
+func TestCircuitBreakerLimitsProbes(t *testing.T) {
+	breaker := newTestBreaker()
+	for i := 0; i < 3; i++ {
+		call(context.Background(), breaker, status.Error(codes.Unavailable, "down"))
+	}
+	time.Sleep(40 * time.Millisecond)
+
+	probe, ok := breaker.allow()
+	if !probe || !ok {
+		t.Fatalf("allow = %v, %v, want a probe", probe, ok)
+	}
+	if probe, ok := breaker.allow(); probe || ok {
+		t.Fatalf("second allow = %v, %v, want rejection while the probe runs", probe, ok)
+	}
+}
@@ -15,8 +15,10 @@ type grpcClient struct {
 	conn *grpc.ClientConn
 }
 
-func NewClient(url string) (GrpcClient, error) {
-	conn, err := grpc.NewClient(url, grpc.WithTransportCredentials(insecure.NewCredentials()))
+func NewClient(url string, opts ...grpc.DialOption) (GrpcClient, error) {
+	opts = append([]grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}, opts...)
+
+	conn, err := grpc.NewClient(url, opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,161 @@
+package grpc
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/connectivity"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+// Dependency health statuses
+const (
+	HealthServing     = "SERVING"
+	HealthNotServing  = "NOT_SERVING"
+	HealthUnreachable = "UNREACHABLE"
+	// HealthUnknown is reported by backends that answer but do not expose the health service
+	HealthUnknown = "UNKNOWN"
+)
+
+// DependencyStatus is the health of a single downstream service
+type DependencyStatus struct {
+	Name            string  `json:"name"`
+	Status          string  `json:"status"`
+	ConnectionState string  `json:"connection_state"`
+	Critical        bool    `json:"critical"`
+	LatencyMs       float64 `json:"latency_ms"`
+	Error           string  `json:"error,omitempty"`
+}
+
+// Healthy reports whether the dependency reported itself as serving
+func (s DependencyStatus) Healthy() bool {
+	return s.Status == HealthServing
+}
+
+// Down reports whether the dependency cannot serve requests. A dependency of unknown health answered
+// the check, so it is not considered down.
+func (s DependencyStatus) Down() bool {
+	return !s.Healthy() && s.Status != HealthUnknown
+}
+
+type dependency struct {
+	name     string
+	client   GrpcClient
+	critical bool
+}
+
+// HealthChecker queries the standard grpc.health.v1 service of every downstream service. Checks use
+// their own connections, so that they are not retried, counted in call metrics or rejected by a
+// circuit breaker.
+type HealthChecker struct {
+	timeout      time.Duration
+	dependencies []dependency
+	draining     atomic.Bool
+}
+
+// NewHealthChecker creates a checker that bounds each dependency check by timeout
+func NewHealthChecker(timeout time.Duration) *HealthChecker {
+	return &HealthChecker{timeout: timeout}
+}
+
+// AddDependency registers the downstream service at target. The gateway is unready while a critical
+// dependency is down.
+func (h *HealthChecker) AddDependency(name, target string, critical bool) error {
+	client, err := NewClient(target)
+	if err != nil {
+		return err
+	}
+
+	h.dependencies = append(h.dependencies, dependency{
+		name:     name,
+		client:   client,
+		critical: critical,
+	})
+	return nil
+}
+
+// Close closes the connections of every dependency
+func (h *HealthChecker) Close() {
+	for _, dep := range h.dependencies {
+		dep.client.Close()
+	}
+}
+
+// SetDraining marks the gateway as shutting down, after which it never reports ready again
+func (h *HealthChecker) SetDraining() {
+	h.draining.Store(true)
+}
+
+// Draining reports whether the gateway is shutting down
+func (h *HealthChecker) Draining() bool {
+	return h.draining.Load()
+}
+
+// Check queries every dependency concurrently. It reports ready when no critical dependency is down
+// and the gateway is not shutting down.
+func (h *HealthChecker) Check(ctx context.Context) (bool, []DependencyStatus) {
+	if h.Draining() {
+		return false, nil
+	}
+
+	statuses := make([]DependencyStatus, len(h.dependencies))
+
+	var wg sync.WaitGroup
+	for i, dep := range h.dependencies {
+		wg.Add(1)
+		go func(i int, dep dependency) {
+			defer wg.Done()
+			statuses[i] = h.checkDependency(ctx, dep)
+		}(i, dep)
+	}
+	wg.Wait()
+
+	ready := true
+	for _, s := range statuses {
+		if s.Critical && s.Down() {
+			ready = false
+		}
+	}
+
+	return ready, statuses
+}
+
+func (h *HealthChecker) checkDependency(ctx context.Context, dep dependency) DependencyStatus {
+	ctx, cancel := context.WithTimeout(ctx, h.timeout)
+	defer cancel()
+
+	result := DependencyStatus{
+		Name:     dep.name,
+		Critical: dep.critical,
+	}
+
+	conn := dep.client.Conn()
+
+	// Leave idle connections to connect on first use
+	conn.Connect()
+
+	start := time.Now()
+	resp, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{})
+	result.LatencyMs = float64(time.Since(start).Microseconds()) / 1000
+	result.ConnectionState = conn.GetState().String()
+
+	switch {
+	case err == nil:
+		result.Status = resp.GetStatus().String()
+	case status.Code(err) == codes.Unimplemented:
+		// The backend does not expose the health service, but it did answer
+		result.Status = HealthUnknown
+	default:
+		result.Status = HealthUnreachable
+		if conn.GetState() == connectivity.Ready {
+			result.Status = HealthNotServing
+		}
+		result.Error = err.Error()
+	}
+
+	return result
+}
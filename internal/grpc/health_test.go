@@ -0,0 +1,124 @@
+package grpc
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// serveHealth starts a gRPC server on a local port and returns its address. A nil status serves no
+// health service at all.
+func serveHealth(t *testing.T, servingStatus *healthpb.HealthCheckResponse_ServingStatus) string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+
+	server := grpc.NewServer()
+	if servingStatus != nil {
+		healthServer := health.NewServer()
+		healthServer.SetServingStatus("", *servingStatus)
+		healthpb.RegisterHealthServer(server, healthServer)
+	}
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	return listener.Addr().String()
+}
+
+// closedAddress returns a local address nothing listens on
+func closedAddress(t *testing.T) string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+	return addr
+}
+
+func TestHealthCheckerStatuses(t *testing.T) {
+	serving := healthpb.HealthCheckResponse_SERVING
+	notServing := healthpb.HealthCheckResponse_NOT_SERVING
+
+	tests := []struct {
+		name       string
+		target     func(t *testing.T) string
+		wantStatus string
+		wantDown   bool
+	}{
+		{"serving", func(t *testing.T) string { return serveHealth(t, &serving) }, HealthServing, false},
+		{"not serving", func(t *testing.T) string { return serveHealth(t, &notServing) }, HealthNotServing, true},
+		{"no health service", func(t *testing.T) string { return serveHealth(t, nil) }, HealthUnknown, false},
+		{"unreachable", closedAddress, HealthUnreachable, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checker := NewHealthChecker(time.Second)
+			defer checker.Close()
+			if err := checker.AddDependency("order-svc", tt.target(t), true); err != nil {
+				t.Fatalf("AddDependency: %v", err)
+			}
+
+			ready, statuses := checker.Check(context.Background())
+			if len(statuses) != 1 {
+				t.Fatalf("got %d statuses, want 1", len(statuses))
+			}
+			if statuses[0].Status != tt.wantStatus {
+				t.Fatalf("status = %q, want %q (error %q)", statuses[0].Status, tt.wantStatus, statuses[0].Error)
+			}
+			if statuses[0].Down() != tt.wantDown {
+				t.Fatalf("down = %v, want %v", statuses[0].Down(), tt.wantDown)
+			}
+			if ready == tt.wantDown {
+				t.Fatalf("ready = %v with a critical dependency down = %v", ready, tt.wantDown)
+			}
+		})
+	}
+}
+
+func TestHealthCheckerIgnoresNonCriticalDependencies(t *testing.T) {
+	serving := healthpb.HealthCheckResponse_SERVING
+
+	checker := NewHealthChecker(time.Second)
+	defer checker.Close()
+	if err := checker.AddDependency("order-svc", serveHealth(t, &serving), true); err != nil {
+		t.Fatalf("AddDependency: %v", err)
+	}
+	if err := checker.AddDependency("reminder-svc", closedAddress(t), false); err != nil {
+		t.Fatalf("AddDependency: %v", err)
+	}
+
+	ready, statuses := checker.Check(context.Background())
+	if !ready {
+		t.Fatalf("not ready with only a non-critical dependency down: %+v", statuses)
+	}
+	if statuses[1].Status != HealthUnreachable {
+		t.Fatalf("reminder-svc status = %q, want %q", statuses[1].Status, HealthUnreachable)
+	}
+}
+
+func TestHealthCheckerDraining(t *testing.T) {
+	serving := healthpb.HealthCheckResponse_SERVING
+
+	checker := NewHealthChecker(time.Second)
+	defer checker.Close()
+	if err := checker.AddDependency("order-svc", serveHealth(t, &serving), true); err != nil {
+		t.Fatalf("AddDependency: %v", err)
+	}
+
+	checker.SetDraining()
+	if ready, _ := checker.Check(context.Background()); ready {
+		t.Fatal("draining gateway reported ready")
+	}
+}
@@ -0,0 +1,94 @@
+package grpc
+
+import (
+	"context"
+	"time"
+
+	"github.com/PharmaKart/gateway-svc/pkg/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	grpcClientRequests = metrics.Factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "gateway_grpc_client_requests_total",
+		Help: "Total number of gRPC calls made to downstream services.",
+	}, []string{"service", "method", "code"})
+	grpcClientDuration = metrics.Factory.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "gateway_grpc_client_request_duration_seconds",
+		Help:    "Latency of gRPC calls made to downstream services, including retries.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"service", "method"})
+)
+
+// WithMetrics records the count and latency of every call on the connection, labelled by service and
+// method. Calls are also added to the call log of the request context, if any.
+func WithMetrics(service string) grpc.DialOption {
+	return grpc.WithChainUnaryInterceptor(func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		start := time.Now()
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		latency := time.Since(start)
+		code := status.Code(err).String()
+
+		grpcClientDuration.WithLabelValues(service, method).Observe(latency.Seconds())
+		grpcClientRequests.WithLabelValues(service, method, code).Inc()
+		recordCall(ctx, service, method, code, latency)
+
+		return err
+	})
+}
+
+// RegisterTokenCacheMetrics exports the counters of cache
+func RegisterTokenCacheMetrics(cache *TokenCache) {
+	metrics.Factory.NewCounterFunc(prometheus.CounterOpts{
+		Name: "gateway_token_cache_hits_total",
+		Help: "Token verifications served from the cache.",
+	}, func() float64 {
+		return float64(cache.Stats().Hits)
+	})
+	metrics.Factory.NewCounterFunc(prometheus.CounterOpts{
+		Name: "gateway_token_cache_negative_hits_total",
+		Help: "Rejected tokens served from the cache.",
+	}, func() float64 {
+		return float64(cache.Stats().NegativeHits)
+	})
+	metrics.Factory.NewCounterFunc(prometheus.CounterOpts{
+		Name: "gateway_token_cache_misses_total",
+		Help: "Token verifications that missed the cache.",
+	}, func() float64 {
+		return float64(cache.Stats().Misses)
+	})
+	metrics.Factory.NewCounterFunc(prometheus.CounterOpts{
+		Name: "gateway_token_cache_evictions_total",
+		Help: "Entries evicted from the token cache.",
+	}, func() float64 {
+		return float64(cache.Stats().Evictions)
+	})
+	metrics.Factory.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "gateway_token_cache_entries",
+		Help: "Entries currently held in the token cache.",
+	}, func() float64 {
+		return float64(cache.Stats().Size)
+	})
+}
+
+// RegisterCircuitBreakerMetrics exports the state of every breaker, as 0 closed, 1 half open and 2 open
+func RegisterCircuitBreakerMetrics(breakers []*CircuitBreaker) {
+	for _, breaker := range breakers {
+		metrics.Factory.NewGaugeFunc(prometheus.GaugeOpts{
+			Name:        "gateway_circuit_breaker_state",
+			Help:        "State of the circuit breaker guarding each downstream service.",
+			ConstLabels: prometheus.Labels{"service": breaker.Service()},
+		}, func() float64 {
+			switch breaker.State().State {
+			case CircuitOpen:
+				return 2
+			case CircuitHalfOpen:
+				return 1
+			default:
+				return 0
+			}
+		})
+	}
+}
@@ -11,8 +11,10 @@ type PaymentClient interface {
 	StorePayment(ctx context.Context, req *proto.StorePaymentRequest) (*proto.StorePaymentResponse, error)
 	RefundPayment(ctx context.Context, req *proto.RefundPaymentRequest) (*proto.RefundPaymentResponse, error)
 	GetPaymentByTransactionID(ctx context.Context, req *proto.GetPaymentByTransactionIDRequest) (*proto.GetPaymentResponse, error)
+	GetPaymentByPaymentIntentID(ctx context.Context, req *proto.GetPaymentByPaymentIntentIDRequest) (*proto.GetPaymentResponse, error)
 	GetPayment(ctx context.Context, req *proto.GetPaymentRequest) (*proto.GetPaymentResponse, error)
 	GetPaymentByOrderID(ctx context.Context, req *proto.GetPaymentByOrderIDRequest) (*proto.GetPaymentResponse, error)
+	ListPayments(ctx context.Context, req *proto.ListPaymentsRequest) (*proto.ListPaymentsResponse, error)
 }
 
 type paymentClient struct {
@@ -37,6 +39,10 @@ func (c *paymentClient) GetPaymentByTransactionID(ctx context.Context, req *prot
 	return c.client.GetPaymentByTransactionID(ctx, req)
 }
 
+func (c *paymentClient) GetPaymentByPaymentIntentID(ctx context.Context, req *proto.GetPaymentByPaymentIntentIDRequest) (*proto.GetPaymentResponse, error) {
+	return c.client.GetPaymentByPaymentIntentID(ctx, req)
+}
+
 func (c *paymentClient) GetPayment(ctx context.Context, req *proto.GetPaymentRequest) (*proto.GetPaymentResponse, error) {
 	return c.client.GetPayment(ctx, req)
 }
@@ -44,3 +50,7 @@ func (c *paymentClient) GetPayment(ctx context.Context, req *proto.GetPaymentReq
 func (c *paymentClient) GetPaymentByOrderID(ctx context.Context, req *proto.GetPaymentByOrderIDRequest) (*proto.GetPaymentResponse, error) {
 	return c.client.GetPaymentByOrderID(ctx, req)
 }
+
+func (c *paymentClient) ListPayments(ctx context.Context, req *proto.ListPaymentsRequest) (*proto.ListPaymentsResponse, error) {
+	return c.client.ListPayments(ctx, req)
+}
@@ -0,0 +1,25 @@
+package grpc
+
+import (
+	"context"
+	"strings"
+
+	"github.com/PharmaKart/gateway-svc/pkg/utils"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// WithRequestID forwards the request ID of the calling request to the backend in the outgoing metadata
+func WithRequestID() grpc.DialOption {
+	header := strings.ToLower(utils.RequestIDHeader)
+
+	return grpc.WithChainUnaryInterceptor(func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if requestID := utils.RequestIDFromContext(ctx); requestID != "" {
+			if md, ok := metadata.FromOutgoingContext(ctx); !ok || len(md.Get(header)) == 0 {
+				ctx = metadata.AppendToOutgoingContext(ctx, header, requestID)
+			}
+		}
+
+		return invoker(ctx, method, req, reply, cc, opts...)
+	})
+}
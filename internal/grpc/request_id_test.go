@@ -0,0 +1,68 @@
+package grpc
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/PharmaKart/gateway-svc/pkg/utils"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+)
+
+// metadataHealthServer records the request IDs received in the incoming metadata
+type metadataHealthServer struct {
+	grpc_health_v1.UnimplementedHealthServer
+	requestIDs []string
+}
+
+func (s *metadataHealthServer) Check(ctx context.Context, req *grpc_health_v1.HealthCheckRequest) (*grpc_health_v1.HealthCheckResponse, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	s.requestIDs = md.Get(strings.ToLower(utils.RequestIDHeader))
+	return &grpc_health_v1.HealthCheckResponse{Status: grpc_health_v1.HealthCheckResponse_SERVING}, nil
+}
+
+func TestWithRequestID(t *testing.T) {
+	tests := []struct {
+		name string
+		ctx  func() context.Context
+		want []string
+	}{
+		{
+			name: "forwards the request ID",
+			ctx: func() context.Context {
+				return utils.ContextWithRequestID(context.Background(), "req-1")
+			},
+			want: []string{"req-1"},
+		},
+		{
+			name: "keeps an ID set by the caller",
+			ctx: func() context.Context {
+				ctx := utils.ContextWithRequestID(context.Background(), "req-1")
+				return metadata.AppendToOutgoingContext(ctx, "x-request-id", "explicit")
+			},
+			want: []string{"explicit"},
+		},
+		{
+			name: "without a request ID",
+			ctx:  context.Background,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := &metadataHealthServer{}
+			conn := dialBufconn(t, func(s *grpc.Server) {
+				grpc_health_v1.RegisterHealthServer(s, server)
+			}, WithRequestID())
+
+			if _, err := grpc_health_v1.NewHealthClient(conn).Check(tt.ctx(), &grpc_health_v1.HealthCheckRequest{}); err != nil {
+				t.Fatalf("Check: %v", err)
+			}
+			if strings.Join(server.requestIDs, ",") != strings.Join(tt.want, ",") {
+				t.Fatalf("backend received request IDs %q, want %q", server.requestIDs, tt.want)
+			}
+		})
+	}
+}
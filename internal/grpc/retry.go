@@ -0,0 +1,124 @@
+package grpc
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PharmaKart/gateway-svc/pkg/utils"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+)
+
+// IdempotencyKeyHeader is the outgoing metadata key that marks a call as safe to retry
+const IdempotencyKeyHeader = "idempotency-key"
+
+// IdempotentMethods are the read-only methods that are always safe to retry
+var IdempotentMethods = []string{
+	"/auth.AuthService/VerifyToken",
+	"/product.ProductService/GetProduct",
+	"/product.ProductService/ListProducts",
+	"/product.ProductService/GetInventoryLogs",
+	"/order.OrderService/GetOrder",
+	"/order.OrderService/ListCustomersOrders",
+	"/order.OrderService/ListAllOrders",
+	"/payment.PaymentService/GetPayment",
+	"/payment.PaymentService/GetPaymentByOrderID",
+	"/payment.PaymentService/GetPaymentByTransactionID",
+	"/payment.PaymentService/GetPaymentByPaymentIntentID",
+	"/payment.PaymentService/ListPayments",
+	"/reminder.ReminderService/ListReminders",
+	"/reminder.ReminderService/ListCustomerReminders",
+	"/reminder.ReminderService/ListReminderLogs",
+}
+
+// RetryPolicy describes how failed calls are retried
+type RetryPolicy struct {
+	// MaxAttempts includes the first attempt. gRPC caps it at 5.
+	MaxAttempts       int
+	InitialBackoff    time.Duration
+	MaxBackoff        time.Duration
+	BackoffMultiplier float64
+	RetryableCodes    []codes.Code
+}
+
+// ParseCodes converts status code names such as "UNAVAILABLE" to codes, skipping unknown names
+func ParseCodes(names []string) []codes.Code {
+	var result []codes.Code
+	for _, name := range names {
+		var code codes.Code
+		if err := code.UnmarshalJSON([]byte(`"` + strings.ToUpper(strings.TrimSpace(name)) + `"`)); err != nil {
+			utils.Warn("Ignoring unknown gRPC status code", map[string]interface{}{
+				"code": name,
+			})
+			continue
+		}
+		result = append(result, code)
+	}
+	return result
+}
+
+// WithRetries retries calls that fail with one of the policy's retryable codes. The retries are made by
+// the channel itself, configured through the default service config. Use it with WithIdempotencyGate so
+// that calls which are not safe to repeat are never retried.
+func WithRetries(policy RetryPolicy) grpc.DialOption {
+	if policy.MaxAttempts <= 1 || len(policy.RetryableCodes) == 0 {
+		return grpc.EmptyDialOption{}
+	}
+
+	return grpc.WithDefaultServiceConfig(policy.serviceConfig())
+}
+
+// serviceConfig renders the policy as a service config applying to every method
+func (p RetryPolicy) serviceConfig() string {
+	config := map[string]interface{}{
+		"methodConfig": []map[string]interface{}{{
+			"name": []map[string]string{{}},
+			"retryPolicy": map[string]interface{}{
+				"maxAttempts":          p.MaxAttempts,
+				"initialBackoff":       serviceConfigDuration(p.InitialBackoff),
+				"maxBackoff":           serviceConfigDuration(p.MaxBackoff),
+				"backoffMultiplier":    p.BackoffMultiplier,
+				"retryableStatusCodes": p.RetryableCodes,
+			},
+		}},
+	}
+
+	data, _ := json.Marshal(config)
+	return string(data)
+}
+
+func serviceConfigDuration(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', -1, 64) + "s"
+}
+
+// WithIdempotencyGate stops the retries of calls that are neither in idempotentMethods nor carry an
+// idempotency key in their outgoing metadata
+func WithIdempotencyGate(idempotentMethods []string) grpc.DialOption {
+	methods := make(map[string]bool, len(idempotentMethods))
+	for _, method := range idempotentMethods {
+		methods[method] = true
+	}
+
+	return grpc.WithChainUnaryInterceptor(idempotencyInterceptor(methods))
+}
+
+func idempotencyInterceptor(idempotentMethods map[string]bool) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if !idempotentMethods[method] && !hasIdempotencyKey(ctx) {
+			// A call that may not buffer its request for replay is committed as soon as the request is
+			// sent, and is then never retried
+			opts = append(opts[:len(opts):len(opts)], grpc.MaxRetryRPCBufferSize(0))
+		}
+
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+func hasIdempotencyKey(ctx context.Context) bool {
+	md, ok := metadata.FromOutgoingContext(ctx)
+	return ok && len(md.Get(IdempotencyKeyHeader)) > 0 && md.Get(IdempotencyKeyHeader)[0] != ""
+}
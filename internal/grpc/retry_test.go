@@ -0,0 +1,173 @@
+package grpc
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// flakyHealthServer fails the first failures calls with code, and counts every call
+type flakyHealthServer struct {
+	grpc_health_v1.UnimplementedHealthServer
+	failures int64
+	code     codes.Code
+	calls    atomic.Int64
+}
+
+func (s *flakyHealthServer) Check(ctx context.Context, req *grpc_health_v1.HealthCheckRequest) (*grpc_health_v1.HealthCheckResponse, error) {
+	if s.calls.Add(1) <= s.failures {
+		return nil, status.Error(s.code, "try again")
+	}
+	return &grpc_health_v1.HealthCheckResponse{Status: grpc_health_v1.HealthCheckResponse_SERVING}, nil
+}
+
+// dialBufconn serves server in process and returns a connection to it made with opts
+func dialBufconn(t *testing.T, register func(*grpc.Server), opts ...grpc.DialOption) *grpc.ClientConn {
+	t.Helper()
+
+	listener := bufconn.Listen(1 << 20)
+	server := grpc.NewServer()
+	register(server)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	opts = append([]grpc.DialOption{
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+	}, opts...)
+	conn, err := grpc.NewClient("passthrough:///bufconn", opts...)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestRetries(t *testing.T) {
+	const checkMethod = "/grpc.health.v1.Health/Check"
+
+	policy := RetryPolicy{
+		MaxAttempts:       3,
+		InitialBackoff:    time.Millisecond,
+		MaxBackoff:        5 * time.Millisecond,
+		BackoffMultiplier: 2,
+		RetryableCodes:    ParseCodes([]string{"UNAVAILABLE", "resource_exhausted"}),
+	}
+
+	tests := []struct {
+		name           string
+		idempotent     bool
+		idempotencyKey string
+		failures       int64
+		code           codes.Code
+		wantCode       codes.Code
+		wantCalls      int64
+	}{
+		{
+			name:       "idempotent method recovers",
+			idempotent: true,
+			failures:   2,
+			code:       codes.Unavailable,
+			wantCode:   codes.OK,
+			wantCalls:  3,
+		},
+		{
+			name:       "attempts are bounded",
+			idempotent: true,
+			failures:   5,
+			code:       codes.ResourceExhausted,
+			wantCode:   codes.ResourceExhausted,
+			wantCalls:  3,
+		},
+		{
+			name:       "code is not retryable",
+			idempotent: true,
+			failures:   1,
+			code:       codes.Internal,
+			wantCode:   codes.Internal,
+			wantCalls:  1,
+		},
+		{
+			name:      "other method is not retried",
+			failures:  1,
+			code:      codes.Unavailable,
+			wantCode:  codes.Unavailable,
+			wantCalls: 1,
+		},
+		{
+			name:           "other method with an idempotency key recovers",
+			idempotencyKey: "order-1",
+			failures:       1,
+			code:           codes.Unavailable,
+			wantCode:       codes.OK,
+			wantCalls:      2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := &flakyHealthServer{failures: tt.failures, code: tt.code}
+
+			var idempotentMethods []string
+			if tt.idempotent {
+				idempotentMethods = []string{checkMethod}
+			}
+			conn := dialBufconn(t, func(s *grpc.Server) {
+				grpc_health_v1.RegisterHealthServer(s, server)
+			}, WithRetries(policy), WithIdempotencyGate(idempotentMethods))
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if tt.idempotencyKey != "" {
+				ctx = metadata.AppendToOutgoingContext(ctx, IdempotencyKeyHeader, tt.idempotencyKey)
+			}
+
+			_, err := grpc_health_v1.NewHealthClient(conn).Check(ctx, &grpc_health_v1.HealthCheckRequest{})
+			if code := status.Code(err); code != tt.wantCode {
+				t.Fatalf("code = %v, want %v", code, tt.wantCode)
+			}
+			if calls := server.calls.Load(); calls != tt.wantCalls {
+				t.Fatalf("server was called %d times, want %d", calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestRetriesDisabled(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy RetryPolicy
+	}{
+		{"single attempt", RetryPolicy{MaxAttempts: 1, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond, BackoffMultiplier: 1, RetryableCodes: []codes.Code{codes.Unavailable}}},
+		{"no retryable codes", RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond, BackoffMultiplier: 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := &flakyHealthServer{failures: 1, code: codes.Unavailable}
+			conn := dialBufconn(t, func(s *grpc.Server) {
+				grpc_health_v1.RegisterHealthServer(s, server)
+			}, WithRetries(tt.policy))
+
+			_, err := grpc_health_v1.NewHealthClient(conn).Check(context.Background(), &grpc_health_v1.HealthCheckRequest{})
+			if status.Code(err) != codes.Unavailable {
+				t.Fatalf("code = %v, want %v", status.Code(err), codes.Unavailable)
+			}
+			if calls := server.calls.Load(); calls != 1 {
+				t.Fatalf("server was called %d times, want 1", calls)
+			}
+		})
+	}
+}
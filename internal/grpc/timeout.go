@@ -0,0 +1,48 @@
+package grpc
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+// WithTimeouts bounds every call on the connection by defaultTimeout, or by the timeout configured
+// for its method. Method timeouts are keyed by full method name ("/product.ProductService/ListProducts")
+// or bare method name ("ListProducts"). A caller deadline that is already shorter always wins, so the
+// remaining request budget propagates to the backend.
+func WithTimeouts(defaultTimeout time.Duration, methodTimeouts map[string]time.Duration) grpc.DialOption {
+	return grpc.WithChainUnaryInterceptor(timeoutInterceptor(defaultTimeout, methodTimeouts))
+}
+
+func timeoutInterceptor(defaultTimeout time.Duration, methodTimeouts map[string]time.Duration) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		timeout := methodTimeout(method, defaultTimeout, methodTimeouts)
+		if timeout <= 0 {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) <= timeout {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+func methodTimeout(method string, defaultTimeout time.Duration, methodTimeouts map[string]time.Duration) time.Duration {
+	if timeout, ok := methodTimeouts[method]; ok {
+		return timeout
+	}
+	if timeout, ok := methodTimeouts[strings.TrimPrefix(method, "/")]; ok {
+		return timeout
+	}
+	if timeout, ok := methodTimeouts[method[strings.LastIndex(method, "/")+1:]]; ok {
+		return timeout
+	}
+	return defaultTimeout
+}
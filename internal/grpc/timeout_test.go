@@ -0,0 +1,72 @@
+package grpc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+func TestTimeoutInterceptor(t *testing.T) {
+	methodTimeouts := map[string]time.Duration{
+		"/order.OrderService/PlaceOrder":    4 * time.Second,
+		"product.ProductService/GetProduct": 3 * time.Second,
+		"ListProducts":                      2 * time.Second,
+		"Unbounded":                         0,
+	}
+
+	tests := []struct {
+		name           string
+		method         string
+		callerDeadline time.Duration
+		// wantTimeout is the time left when the backend is called, or 0 for no deadline
+		wantTimeout time.Duration
+	}{
+		{"default", "/order.OrderService/GetOrder", 0, 5 * time.Second},
+		{"full method name", "/order.OrderService/PlaceOrder", 0, 4 * time.Second},
+		{"method name without leading slash", "/product.ProductService/GetProduct", 0, 3 * time.Second},
+		{"bare method name", "/product.ProductService/ListProducts", 0, 2 * time.Second},
+		{"disabled for the method", "/reminder.ReminderService/Unbounded", 0, 0},
+		{"shorter caller deadline wins", "/order.OrderService/PlaceOrder", time.Second, time.Second},
+		{"longer caller deadline is bounded", "/order.OrderService/PlaceOrder", time.Minute, 4 * time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.callerDeadline > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tt.callerDeadline)
+				defer cancel()
+			}
+
+			var (
+				deadline    time.Time
+				hasDeadline bool
+			)
+			invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+				deadline, hasDeadline = ctx.Deadline()
+				return nil
+			}
+
+			interceptor := timeoutInterceptor(5*time.Second, methodTimeouts)
+			if err := interceptor(ctx, tt.method, nil, nil, nil, invoker); err != nil {
+				t.Fatalf("interceptor: %v", err)
+			}
+
+			if tt.wantTimeout == 0 {
+				if hasDeadline {
+					t.Fatalf("backend got a deadline in %v, want none", time.Until(deadline))
+				}
+				return
+			}
+			if !hasDeadline {
+				t.Fatal("backend got no deadline")
+			}
+			if left := time.Until(deadline); left > tt.wantTimeout || left < tt.wantTimeout-100*time.Millisecond {
+				t.Fatalf("backend deadline in %v, want %v", left, tt.wantTimeout)
+			}
+		})
+	}
+}
@@ -0,0 +1,17 @@
+package grpc
+
+import (
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc/filters"
+	"go.opentelemetry.io/otel/attribute"
+	"google.golang.org/grpc"
+)
+
+// WithTracing creates a client span for every call attempt on the connection and propagates the
+// W3C trace context to service in the outgoing metadata. Health checks are not traced.
+func WithTracing(service string) grpc.DialOption {
+	return grpc.WithStatsHandler(otelgrpc.NewClientHandler(
+		otelgrpc.WithSpanAttributes(attribute.String("peer.service", service)),
+		otelgrpc.WithFilter(filters.Not(filters.HealthCheck())),
+	))
+}
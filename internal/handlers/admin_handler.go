@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/PharmaKart/gateway-svc/internal/grpc"
+	"github.com/PharmaKart/gateway-svc/internal/webhookqueue"
+	"github.com/PharmaKart/gateway-svc/pkg/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// GetTokenCacheStats returns the token verification cache counters
+// @Summary Get token cache statistics
+// @Description Returns hit, miss and eviction counters of the gateway token verification cache
+// @Tags Admin
+// @Produce json
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer token"
+// @Success 200 {object} grpc.TokenCacheStats
+// @Failure 401 {object} utils.ErrorResponse "Unauthorized"
+// @Failure 403 {object} utils.ErrorResponse "Forbidden"
+// @Router /api/v1/admin/auth/cache [get]
+func GetTokenCacheStats(tokenCache *grpc.TokenCache) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, tokenCache.Stats())
+	}
+}
+
+// GetCircuitBreakers returns the state of the circuit breaker of every downstream service
+// @Summary Get circuit breaker states
+// @Description Returns whether calls to each downstream service are flowing, failing fast or being probed
+// @Tags Admin
+// @Produce json
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer token"
+// @Success 200 {array} grpc.CircuitBreakerState
+// @Failure 401 {object} utils.ErrorResponse "Unauthorized"
+// @Failure 403 {object} utils.ErrorResponse "Forbidden"
+// @Router /api/v1/admin/circuit-breakers [get]
+func GetCircuitBreakers(breakers []*grpc.CircuitBreaker) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		states := make([]grpc.CircuitBreakerState, len(breakers))
+		for i, breaker := range breakers {
+			states[i] = breaker.State()
+		}
+
+		c.JSON(http.StatusOK, states)
+	}
+}
+
+// WebhookDeadLetters lists the webhook events that could not be processed
+type WebhookDeadLetters struct {
+	Stats       webhookqueue.Stats   `json:"stats"`
+	DeadLetters []webhookqueue.Event `json:"dead_letters"`
+}
+
+// ListWebhookDeadLetters returns the webhook events that exhausted their processing attempts
+// @Summary List webhook dead letters
+// @Description Returns the queue depth and the webhook events that failed every processing attempt, most recent failure first
+// @Tags Admin
+// @Produce json
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer token"
+// @Success 200 {object} WebhookDeadLetters
+// @Failure 401 {object} utils.ErrorResponse "Unauthorized"
+// @Failure 403 {object} utils.ErrorResponse "Forbidden"
+// @Router /api/v1/admin/webhooks/dead-letters [get]
+func ListWebhookDeadLetters(webhookQueue *webhookqueue.Queue) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, WebhookDeadLetters{
+			Stats:       webhookQueue.Stats(),
+			DeadLetters: webhookQueue.DeadLetters(),
+		})
+	}
+}
+
+// ReplayWebhookDeadLetter queues a dead-lettered webhook event for processing again
+// @Summary Replay a webhook dead letter
+// @Description Moves a dead-lettered webhook event back to the queue with a fresh set of attempts
+// @Tags Admin
+// @Produce json
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer token"
+// @Param id path string true "Event ID"
+// @Success 202 {object} nil "Accepted"
+// @Failure 401 {object} utils.ErrorResponse "Unauthorized"
+// @Failure 403 {object} utils.ErrorResponse "Forbidden"
+// @Failure 404 {object} utils.ErrorResponse "Not Found"
+// @Failure 500 {object} utils.ErrorResponse "Internal Server Error"
+// @Router /api/v1/admin/webhooks/dead-letters/{id}/replay [post]
+func ReplayWebhookDeadLetter(webhookQueue *webhookqueue.Queue) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		eventID := c.Param("id")
+
+		if err := webhookQueue.Replay(eventID); err != nil {
+			if errors.Is(err, webhookqueue.ErrNotFound) {
+				utils.WriteError(c, http.StatusNotFound, utils.ErrorResponse{
+					Type:    "NOT_FOUND_ERROR",
+					Message: "Webhook event not found in dead letters",
+				})
+				return
+			}
+
+			utils.ErrorContext(c.Request.Context(), "Failed to replay webhook event", map[string]interface{}{
+				"error": err,
+				"event": eventID,
+			})
+			utils.WriteError(c, http.StatusInternalServerError, utils.ErrorResponse{
+				Type:    "INTERNAL_ERROR",
+				Message: "Failed to replay webhook event",
+			})
+			return
+		}
+
+		c.JSON(http.StatusAccepted, gin.H{
+			"success": true,
+			"message": "Webhook event queued for processing",
+		})
+	}
+}
+
+// ReplayWebhookDeadLetters queues every dead-lettered webhook event for processing again
+// @Summary Replay all webhook dead letters
+// @Description Moves every dead-lettered webhook event back to the queue with a fresh set of attempts
+// @Tags Admin
+// @Produce json
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer token"
+// @Success 202 {object} nil "Accepted"
+// @Failure 401 {object} utils.ErrorResponse "Unauthorized"
+// @Failure 403 {object} utils.ErrorResponse "Forbidden"
+// @Failure 500 {object} utils.ErrorResponse "Internal Server Error"
+// @Router /api/v1/admin/webhooks/dead-letters/replay [post]
+func ReplayWebhookDeadLetters(webhookQueue *webhookqueue.Queue) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		replayed, err := webhookQueue.ReplayAll()
+		if err != nil {
+			utils.ErrorContext(c.Request.Context(), "Failed to replay webhook events", map[string]interface{}{
+				"error":    err,
+				"replayed": replayed,
+			})
+			utils.WriteError(c, http.StatusInternalServerError, utils.ErrorResponse{
+				Type:    "INTERNAL_ERROR",
+				Message: "Failed to replay webhook events",
+			})
+			return
+		}
+
+		c.JSON(http.StatusAccepted, gin.H{
+			"success":  true,
+			"replayed": replayed,
+		})
+	}
+}
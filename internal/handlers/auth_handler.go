@@ -41,7 +41,7 @@ func Register(authClient grpc.AuthClient) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var req RegisterRequest
 		if err := c.ShouldBindJSON(&req); err != nil {
-			c.JSON(http.StatusBadRequest, utils.ErrorResponse{
+			utils.WriteError(c, http.StatusBadRequest, utils.ErrorResponse{
 				Type:    "VALIDATION_ERROR",
 				Message: "Invalid request format",
 				Details: map[string]string{"format": err.Error()},
@@ -67,29 +67,27 @@ func Register(authClient grpc.AuthClient) gin.HandlerFunc {
 		})
 
 		if err != nil {
-			utils.Error("Failed to register user", map[string]interface{}{
+			utils.ErrorContext(c.Request.Context(), "Failed to register user", map[string]interface{}{
 				"error": err,
 			})
-			c.JSON(http.StatusInternalServerError, utils.ErrorResponse{
-				Type:    "INTERNAL_ERROR",
-				Message: "Failed to register user",
-			})
+			errorResp, statusCode := utils.ConvertGrpcErrorToResponse(err, "Failed to register user")
+			utils.WriteError(c, statusCode, errorResp)
 			return
 		}
 
 		if !resp.Success {
-			utils.Error("Failed to register user", map[string]interface{}{
+			utils.ErrorContext(c.Request.Context(), "Failed to register user", map[string]interface{}{
 				"error": resp.Message,
 			})
 
 			if resp.Error != nil {
 				errorResp, statusCode := utils.ConvertProtoErrorToResponse(resp.Error)
-				c.JSON(statusCode, errorResp)
+				utils.WriteError(c, statusCode, errorResp)
 				return
 			}
 
 			// Fallback if error structure is not available
-			c.JSON(http.StatusBadRequest, utils.ErrorResponse{
+			utils.WriteError(c, http.StatusBadRequest, utils.ErrorResponse{
 				Type:    "UNKNOWN_ERROR",
 				Message: resp.Message,
 			})
@@ -127,10 +125,10 @@ func Login(authClient grpc.AuthClient) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var req LoginRequest
 		if err := c.ShouldBindJSON(&req); err != nil {
-			utils.Error("Failed to bind JSON", map[string]interface{}{
+			utils.ErrorContext(c.Request.Context(), "Failed to bind JSON", map[string]interface{}{
 				"error": err,
 			})
-			c.JSON(http.StatusBadRequest, utils.ErrorResponse{
+			utils.WriteError(c, http.StatusBadRequest, utils.ErrorResponse{
 				Type:    "VALIDATION_ERROR",
 				Message: "Invalid request format",
 				Details: map[string]string{"format": err.Error()},
@@ -146,29 +144,27 @@ func Login(authClient grpc.AuthClient) gin.HandlerFunc {
 		})
 
 		if err != nil {
-			utils.Error("Failed to login", map[string]interface{}{
+			utils.ErrorContext(c.Request.Context(), "Failed to login", map[string]interface{}{
 				"error": err,
 			})
-			c.JSON(http.StatusInternalServerError, utils.ErrorResponse{
-				Type:    "INTERNAL_ERROR",
-				Message: "Failed to login",
-			})
+			errorResp, statusCode := utils.ConvertGrpcErrorToResponse(err, "Failed to login")
+			utils.WriteError(c, statusCode, errorResp)
 			return
 		}
 
 		if !resp.Success {
-			utils.Error("Failed to login", map[string]interface{}{
+			utils.ErrorContext(c.Request.Context(), "Failed to login", map[string]interface{}{
 				"error": resp.Message,
 			})
 
 			if resp.Error != nil {
 				errorResp, statusCode := utils.ConvertProtoErrorToResponse(resp.Error)
-				c.JSON(statusCode, errorResp)
+				utils.WriteError(c, statusCode, errorResp)
 				return
 			}
 
 			// Fallback if error structure is not available
-			c.JSON(http.StatusBadRequest, utils.ErrorResponse{
+			utils.WriteError(c, http.StatusBadRequest, utils.ErrorResponse{
 				Type:    "UNKNOWN_ERROR",
 				Message: resp.Message,
 			})
@@ -177,12 +173,228 @@ func Login(authClient grpc.AuthClient) gin.HandlerFunc {
 
 		// Return the success response
 		c.JSON(http.StatusOK, gin.H{
-			"success":  true,
-			"message":  resp.Message,
-			"token":    resp.Token,
-			"user_id":  resp.UserId,
-			"username": resp.Username,
-			"role":     resp.Role,
+			"success":       true,
+			"message":       resp.Message,
+			"token":         resp.Token,
+			"refresh_token": resp.RefreshToken,
+			"expires_in":    resp.ExpiresIn,
+			"user_id":       resp.UserId,
+			"username":      resp.Username,
+			"role":          resp.Role,
+		})
+	}
+}
+
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// RefreshToken exchanges a refresh token for a new access token.
+// @Summary Refresh access token
+// @Description Exchange a refresh token for a new access token and refresh token
+// @Tags Authentication
+// @Accept json
+// @Produce json
+// @Param request body RefreshTokenRequest true "Refresh token"
+// @Success 200 {object} proto.RefreshResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/token/refresh [post]
+func RefreshToken(authClient grpc.AuthClient) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req RefreshTokenRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			utils.WriteError(c, http.StatusBadRequest, utils.ErrorResponse{
+				Type:    "VALIDATION_ERROR",
+				Message: "Invalid request format",
+				Details: map[string]string{"format": err.Error()},
+			})
+			return
+		}
+
+		resp, err := authClient.Refresh(c.Request.Context(), &proto.RefreshRequest{
+			RefreshToken: req.RefreshToken,
+		})
+
+		if err != nil {
+			utils.ErrorContext(c.Request.Context(), "Failed to refresh token", map[string]interface{}{
+				"error": err,
+			})
+			errorResp, statusCode := utils.ConvertGrpcErrorToResponse(err, "Failed to refresh token")
+			utils.WriteError(c, statusCode, errorResp)
+			return
+		}
+
+		if !resp.Success {
+			utils.ErrorContext(c.Request.Context(), "Failed to refresh token", map[string]interface{}{
+				"error": resp.Message,
+			})
+
+			if resp.Error != nil {
+				errorResp, statusCode := utils.ConvertProtoErrorToResponse(resp.Error)
+				utils.WriteError(c, statusCode, errorResp)
+				return
+			}
+
+			// Fallback if error structure is not available
+			utils.WriteError(c, http.StatusUnauthorized, utils.ErrorResponse{
+				Type:    "AUTH_ERROR",
+				Message: resp.Message,
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"success":       true,
+			"message":       resp.Message,
+			"token":         resp.Token,
+			"refresh_token": resp.RefreshToken,
+			"expires_in":    resp.ExpiresIn,
+		})
+	}
+}
+
+type LogoutRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// Logout revokes the current access token and its refresh token.
+// @Summary Logout
+// @Description Revoke the access token used for this request and, if provided, its refresh token
+// @Tags Authentication
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer token"
+// @Param request body LogoutRequest false "Refresh token to revoke"
+// @Success 200 {object} proto.LogoutResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/logout [post]
+func Logout(authClient grpc.AuthClient) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token, ok := c.Get("token")
+		if !ok {
+			utils.WriteError(c, http.StatusUnauthorized, utils.ErrorResponse{
+				Type:    "AUTH_ERROR",
+				Message: "Token not found in request",
+			})
+			return
+		}
+
+		// The refresh token is optional, so an empty body is allowed
+		var req LogoutRequest
+		if c.Request.ContentLength > 0 {
+			if err := c.ShouldBindJSON(&req); err != nil {
+				utils.WriteError(c, http.StatusBadRequest, utils.ErrorResponse{
+					Type:    "VALIDATION_ERROR",
+					Message: "Invalid request format",
+					Details: map[string]string{"format": err.Error()},
+				})
+				return
+			}
+		}
+
+		resp, err := authClient.Logout(c.Request.Context(), &proto.LogoutRequest{
+			Token:        token.(string),
+			RefreshToken: req.RefreshToken,
+		})
+
+		if err != nil {
+			utils.ErrorContext(c.Request.Context(), "Failed to logout", map[string]interface{}{
+				"error": err,
+			})
+			errorResp, statusCode := utils.ConvertGrpcErrorToResponse(err, "Failed to logout")
+			utils.WriteError(c, statusCode, errorResp)
+			return
+		}
+
+		if !resp.Success {
+			utils.ErrorContext(c.Request.Context(), "Failed to logout", map[string]interface{}{
+				"error": resp.Message,
+			})
+
+			if resp.Error != nil {
+				errorResp, statusCode := utils.ConvertProtoErrorToResponse(resp.Error)
+				utils.WriteError(c, statusCode, errorResp)
+				return
+			}
+
+			// Fallback if error structure is not available
+			utils.WriteError(c, http.StatusBadRequest, utils.ErrorResponse{
+				Type:    "UNKNOWN_ERROR",
+				Message: resp.Message,
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"message": resp.Message,
+		})
+	}
+}
+
+// RevokeAllSessions revokes every session of the current user.
+// @Summary Revoke all sessions
+// @Description Revoke every access and refresh token issued to the current user
+// @Tags Authentication
+// @Produce json
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer token"
+// @Success 200 {object} proto.RevokeAllSessionsResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/sessions [delete]
+func RevokeAllSessions(authClient grpc.AuthClient) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := c.Get("user_id")
+		if !ok {
+			utils.WriteError(c, http.StatusUnauthorized, utils.ErrorResponse{
+				Type:    "AUTH_ERROR",
+				Message: "User ID not found in token",
+			})
+			return
+		}
+
+		resp, err := authClient.RevokeAllSessions(c.Request.Context(), &proto.RevokeAllSessionsRequest{
+			UserId: userID.(string),
+		})
+
+		if err != nil {
+			utils.ErrorContext(c.Request.Context(), "Failed to revoke sessions", map[string]interface{}{
+				"error": err,
+			})
+			errorResp, statusCode := utils.ConvertGrpcErrorToResponse(err, "Failed to revoke sessions")
+			utils.WriteError(c, statusCode, errorResp)
+			return
+		}
+
+		if !resp.Success {
+			utils.ErrorContext(c.Request.Context(), "Failed to revoke sessions", map[string]interface{}{
+				"error": resp.Message,
+			})
+
+			if resp.Error != nil {
+				errorResp, statusCode := utils.ConvertProtoErrorToResponse(resp.Error)
+				utils.WriteError(c, statusCode, errorResp)
+				return
+			}
+
+			// Fallback if error structure is not available
+			utils.WriteError(c, http.StatusBadRequest, utils.ErrorResponse{
+				Type:    "UNKNOWN_ERROR",
+				Message: resp.Message,
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"success":          true,
+			"message":          resp.Message,
+			"revoked_sessions": resp.RevokedSessions,
 		})
 	}
 }
@@ -3,6 +3,7 @@ package handlers
 import (
 	"net/http"
 
+	"github.com/PharmaKart/gateway-svc/internal/grpc"
 	"github.com/gin-gonic/gin"
 )
 
@@ -23,3 +24,54 @@ type HealthResponse struct {
 func HealthCheck(c *gin.Context) {
 	c.JSON(http.StatusOK, HealthResponse{Status: "good"})
 }
+
+// ReadinessResponse represents the response for the readiness endpoint.
+// @Description Readiness response with the health of every downstream service
+type ReadinessResponse struct {
+	Status       string                  `json:"status" example:"ready"`
+	Dependencies []grpc.DependencyStatus `json:"dependencies"`
+}
+
+// Livez handles liveness probes.
+// @Summary Liveness probe
+// @Description Check if the gateway process is alive
+// @Tags Utility
+// @Produce json
+// @Success 200 {object} HealthResponse
+// @Router /livez [get]
+func Livez(c *gin.Context) {
+	c.JSON(http.StatusOK, HealthResponse{Status: "ok"})
+}
+
+// Readyz handles readiness probes.
+// @Summary Readiness probe
+// @Description Check if the gateway and its critical downstream services can serve traffic
+// @Tags Utility
+// @Produce json
+// @Success 200 {object} ReadinessResponse
+// @Failure 503 {object} ReadinessResponse
+// @Router /readyz [get]
+func Readyz(healthChecker *grpc.HealthChecker) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if healthChecker.Draining() {
+			c.JSON(http.StatusServiceUnavailable, ReadinessResponse{
+				Status: "draining",
+			})
+			return
+		}
+
+		ready, dependencies := healthChecker.Check(c.Request.Context())
+		if !ready {
+			c.JSON(http.StatusServiceUnavailable, ReadinessResponse{
+				Status:       "unready",
+				Dependencies: dependencies,
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, ReadinessResponse{
+			Status:       "ready",
+			Dependencies: dependencies,
+		})
+	}
+}
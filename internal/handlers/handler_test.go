@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/PharmaKart/gateway-svc/internal/grpc"
+	"github.com/PharmaKart/gateway-svc/pkg/config"
+	"github.com/PharmaKart/gateway-svc/pkg/utils"
+	"github.com/gin-gonic/gin"
+)
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	utils.InitLogger(&config.Config{LogLevel: "panic"})
+	os.Exit(m.Run())
+}
+
+// closedAddress returns a local address nothing listens on
+func closedAddress(t *testing.T) string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+	return addr
+}
+
+func TestReadyz(t *testing.T) {
+	tests := []struct {
+		name       string
+		setup      func(t *testing.T, checker *grpc.HealthChecker)
+		wantCode   int
+		wantStatus string
+	}{
+		{
+			name:       "ready",
+			setup:      func(t *testing.T, checker *grpc.HealthChecker) {},
+			wantCode:   http.StatusOK,
+			wantStatus: "ready",
+		},
+		{
+			name: "critical dependency down",
+			setup: func(t *testing.T, checker *grpc.HealthChecker) {
+				if err := checker.AddDependency("order-svc", closedAddress(t), true); err != nil {
+					t.Fatalf("AddDependency: %v", err)
+				}
+			},
+			wantCode:   http.StatusServiceUnavailable,
+			wantStatus: "unready",
+		},
+		{
+			name: "non-critical dependency down",
+			setup: func(t *testing.T, checker *grpc.HealthChecker) {
+				if err := checker.AddDependency("reminder-svc", closedAddress(t), false); err != nil {
+					t.Fatalf("AddDependency: %v", err)
+				}
+			},
+			wantCode:   http.StatusOK,
+			wantStatus: "ready",
+		},
+		{
+			name:       "draining",
+			setup:      func(t *testing.T, checker *grpc.HealthChecker) { checker.SetDraining() },
+			wantCode:   http.StatusServiceUnavailable,
+			wantStatus: "draining",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checker := grpc.NewHealthChecker(time.Second)
+			defer checker.Close()
+			tt.setup(t, checker)
+
+			r := gin.New()
+			r.GET("/readyz", Readyz(checker))
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+			if w.Code != tt.wantCode {
+				t.Fatalf("status code = %d, want %d", w.Code, tt.wantCode)
+			}
+			var resp ReadinessResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if resp.Status != tt.wantStatus {
+				t.Fatalf("status = %q, want %q", resp.Status, tt.wantStatus)
+			}
+		})
+	}
+}
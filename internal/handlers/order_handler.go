@@ -8,9 +8,11 @@ import (
 
 	"github.com/PharmaKart/gateway-svc/internal/grpc"
 	"github.com/PharmaKart/gateway-svc/internal/proto"
+	"github.com/PharmaKart/gateway-svc/internal/webhookqueue"
 	"github.com/PharmaKart/gateway-svc/pkg/config"
 	"github.com/PharmaKart/gateway-svc/pkg/utils"
 	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc/metadata"
 )
 
 type OrderItem struct {
@@ -50,6 +52,7 @@ type SwaggerOrderRequest struct {
 // @Produce json
 // @Security ApiKeyAuth
 // @Param Authorization header string true "Bearer token"
+// @Param Idempotency-Key header string false "Key that makes the order safe to retry"
 // @Param items formData string true "Order Items JSON"
 // @Param prescription formData file false "Prescription Image"
 // @Success 200 {object} proto.PlaceOrderResponse
@@ -62,7 +65,7 @@ func PlaceOrder(cfg *config.Config, orderClient grpc.OrderClient) gin.HandlerFun
 	return func(c *gin.Context) {
 		userRole, ok := c.Get("user_role")
 		if !ok {
-			c.JSON(http.StatusUnauthorized, utils.ErrorResponse{
+			utils.WriteError(c, http.StatusUnauthorized, utils.ErrorResponse{
 				Type:    "AUTH_ERROR",
 				Message: "User Role not found in token",
 			})
@@ -71,7 +74,7 @@ func PlaceOrder(cfg *config.Config, orderClient grpc.OrderClient) gin.HandlerFun
 
 		customerID, ok := c.Get("user_id")
 		if !ok {
-			c.JSON(http.StatusUnauthorized, utils.ErrorResponse{
+			utils.WriteError(c, http.StatusUnauthorized, utils.ErrorResponse{
 				Type:    "AUTH_ERROR",
 				Message: "User ID not found in token",
 			})
@@ -79,7 +82,7 @@ func PlaceOrder(cfg *config.Config, orderClient grpc.OrderClient) gin.HandlerFun
 		}
 
 		if userRole == "admin" {
-			c.JSON(http.StatusForbidden, utils.ErrorResponse{
+			utils.WriteError(c, http.StatusForbidden, utils.ErrorResponse{
 				Type:    "AUTH_ERROR",
 				Message: "Admins cannot place orders",
 			})
@@ -98,7 +101,7 @@ func PlaceOrder(cfg *config.Config, orderClient grpc.OrderClient) gin.HandlerFun
 
 		// Unmarshal the JSON string into the temporary struct
 		if err := json.Unmarshal([]byte(itemsStr), &tempRequest); err != nil {
-			c.JSON(http.StatusBadRequest, utils.ErrorResponse{
+			utils.WriteError(c, http.StatusBadRequest, utils.ErrorResponse{
 				Type:    "VALIDATION_ERROR",
 				Message: "Invalid request format",
 				Details: map[string]string{"format": err.Error()},
@@ -108,7 +111,7 @@ func PlaceOrder(cfg *config.Config, orderClient grpc.OrderClient) gin.HandlerFun
 
 		// Check if items are provided
 		if len(tempRequest.Items) == 0 {
-			c.JSON(http.StatusBadRequest, utils.ErrorResponse{
+			utils.WriteError(c, http.StatusBadRequest, utils.ErrorResponse{
 				Type:    "VALIDATION_ERROR",
 				Message: "Invalid request format",
 				Details: map[string]string{"items": "At least one item is required"},
@@ -131,7 +134,7 @@ func PlaceOrder(cfg *config.Config, orderClient grpc.OrderClient) gin.HandlerFun
 			allowedExtensions := map[string]bool{".jpg": true, ".jpeg": true, ".png": true, ".pdf": true}
 			ext := filepath.Ext(req.Prescription.Filename)
 			if !allowedExtensions[ext] {
-				c.JSON(http.StatusBadRequest, utils.ErrorResponse{
+				utils.WriteError(c, http.StatusBadRequest, utils.ErrorResponse{
 					Type:    "VALIDATION_ERROR",
 					Message: "Invalid file format",
 					Details: map[string]string{"format": "Only JPG, JPEG, PNG, and PDF files are allowed"},
@@ -142,7 +145,7 @@ func PlaceOrder(cfg *config.Config, orderClient grpc.OrderClient) gin.HandlerFun
 			// Upload prescription to S3
 			url, err := utils.UploadImageToS3(c, cfg, "prescriptions", req.Prescription)
 			if err != nil {
-				c.JSON(http.StatusInternalServerError, utils.ErrorResponse{
+				utils.WriteError(c, http.StatusInternalServerError, utils.ErrorResponse{
 					Type:    "INTERNAL_ERROR",
 					Message: "Failed to upload prescription",
 				})
@@ -162,37 +165,41 @@ func PlaceOrder(cfg *config.Config, orderClient grpc.OrderClient) gin.HandlerFun
 			}
 		}
 
+		// Forward the client's idempotency key so that the call can be retried safely
+		ctx := c.Request.Context()
+		if key := c.GetHeader("Idempotency-Key"); key != "" {
+			ctx = metadata.AppendToOutgoingContext(ctx, grpc.IdempotencyKeyHeader, key)
+		}
+
 		// Call the gRPC service
-		resp, err := orderClient.PlaceOrder(c.Request.Context(), &proto.PlaceOrderRequest{
+		resp, err := orderClient.PlaceOrder(ctx, &proto.PlaceOrderRequest{
 			CustomerId:      customerID.(string),
 			Items:           orderItems,
 			PrescriptionUrl: prescriptionURL,
 		})
 		if err != nil {
-			utils.Error("Failed to place order", map[string]interface{}{
+			utils.ErrorContext(c.Request.Context(), "Failed to place order", map[string]interface{}{
 				"error": err,
 			})
-			c.JSON(http.StatusInternalServerError, utils.ErrorResponse{
-				Type:    "INTERNAL_ERROR",
-				Message: "Failed to place order",
-			})
+			errorResp, statusCode := utils.ConvertGrpcErrorToResponse(err, "Failed to place order")
+			utils.WriteError(c, statusCode, errorResp)
 			return
 		}
 
 		// Check if the response indicates a failure
 		if !resp.Success {
-			utils.Error("Failed to place order", map[string]interface{}{
+			utils.ErrorContext(c.Request.Context(), "Failed to place order", map[string]interface{}{
 				"error": resp,
 			})
 
 			if resp.Error != nil {
 				errorResp, statusCode := utils.ConvertProtoErrorToResponse(resp.Error)
-				c.JSON(statusCode, errorResp)
+				utils.WriteError(c, statusCode, errorResp)
 				return
 			}
 
 			// Fallback if error structure is not available
-			c.JSON(http.StatusBadRequest, utils.ErrorResponse{
+			utils.WriteError(c, http.StatusBadRequest, utils.ErrorResponse{
 				Type:    "UNKNOWN_ERROR",
 				Message: "Failed to place order",
 			})
@@ -223,7 +230,7 @@ func GenerateNewPaymentUrl(orderClient grpc.OrderClient) gin.HandlerFunc {
 		userRole, ok := c.Get("user_role")
 		var customerID string
 		if !ok {
-			c.JSON(http.StatusUnauthorized, utils.ErrorResponse{
+			utils.WriteError(c, http.StatusUnauthorized, utils.ErrorResponse{
 				Type:    "AUTH_ERROR",
 				Message: "User Role not found in token",
 			})
@@ -232,7 +239,7 @@ func GenerateNewPaymentUrl(orderClient grpc.OrderClient) gin.HandlerFunc {
 
 		userId, ok := c.Get("user_id")
 		if !ok {
-			c.JSON(http.StatusUnauthorized, utils.ErrorResponse{
+			utils.WriteError(c, http.StatusUnauthorized, utils.ErrorResponse{
 				Type:    "AUTH_ERROR",
 				Message: "User ID not found in token",
 			})
@@ -241,7 +248,7 @@ func GenerateNewPaymentUrl(orderClient grpc.OrderClient) gin.HandlerFunc {
 
 		customerID = userId.(string)
 		if userRole == "admin" {
-			c.JSON(http.StatusForbidden, utils.ErrorResponse{
+			utils.WriteError(c, http.StatusForbidden, utils.ErrorResponse{
 				Type:    "AUTH_ERROR",
 				Message: "Admins cannot generate payment URLs for customers",
 			})
@@ -255,28 +262,26 @@ func GenerateNewPaymentUrl(orderClient grpc.OrderClient) gin.HandlerFunc {
 			CustomerId: customerID,
 		})
 		if err != nil {
-			utils.Error("Failed to generate payment URL", map[string]interface{}{
+			utils.ErrorContext(c.Request.Context(), "Failed to generate payment URL", map[string]interface{}{
 				"error": err,
 			})
-			c.JSON(http.StatusInternalServerError, utils.ErrorResponse{
-				Type:    "INTERNAL_ERROR",
-				Message: "Failed to generate payment URL",
-			})
+			errorResp, statusCode := utils.ConvertGrpcErrorToResponse(err, "Failed to generate payment URL")
+			utils.WriteError(c, statusCode, errorResp)
 			return
 		}
 
 		if !resp.Success {
-			utils.Error("Failed to generate payment URL", map[string]interface{}{
+			utils.ErrorContext(c.Request.Context(), "Failed to generate payment URL", map[string]interface{}{
 				"error": resp,
 			})
 
 			if resp.Error != nil {
 				errorResp, statusCode := utils.ConvertProtoErrorToResponse(resp.Error)
-				c.JSON(statusCode, errorResp)
+				utils.WriteError(c, statusCode, errorResp)
 				return
 			}
 
-			c.JSON(http.StatusBadRequest, utils.ErrorResponse{
+			utils.WriteError(c, http.StatusBadRequest, utils.ErrorResponse{
 				Type:    "UNKNOWN_ERROR",
 				Message: "Failed to generate payment URL",
 			})
@@ -307,7 +312,7 @@ func GetOrder(orderClient grpc.OrderClient, paymentClient grpc.PaymentClient) gi
 		userRole, ok := c.Get("user_role")
 		var customerID string
 		if !ok {
-			c.JSON(http.StatusUnauthorized, utils.ErrorResponse{
+			utils.WriteError(c, http.StatusUnauthorized, utils.ErrorResponse{
 				Type:    "AUTH_ERROR",
 				Message: "User Role not found in token",
 			})
@@ -316,7 +321,7 @@ func GetOrder(orderClient grpc.OrderClient, paymentClient grpc.PaymentClient) gi
 
 		userId, ok := c.Get("user_id")
 		if !ok {
-			c.JSON(http.StatusUnauthorized, utils.ErrorResponse{
+			utils.WriteError(c, http.StatusUnauthorized, utils.ErrorResponse{
 				Type:    "AUTH_ERROR",
 				Message: "User ID not found in token",
 			})
@@ -335,30 +340,28 @@ func GetOrder(orderClient grpc.OrderClient, paymentClient grpc.PaymentClient) gi
 			CustomerId: customerID,
 		})
 		if err != nil {
-			utils.Error("Failed to get order", map[string]interface{}{
+			utils.ErrorContext(c.Request.Context(), "Failed to get order", map[string]interface{}{
 				"error": err,
 			})
-			c.JSON(http.StatusInternalServerError, utils.ErrorResponse{
-				Type:    "INTERNAL_ERROR",
-				Message: "Failed to get order",
-			})
+			errorResp, statusCode := utils.ConvertGrpcErrorToResponse(err, "Failed to get order")
+			utils.WriteError(c, statusCode, errorResp)
 			return
 		}
 
 		// Check if the response indicates a failure
 		if !orderResp.Success {
-			utils.Error("Failed to get order", map[string]interface{}{
+			utils.ErrorContext(c.Request.Context(), "Failed to get order", map[string]interface{}{
 				"error": orderResp,
 			})
 
 			if orderResp.Error != nil {
 				errorResp, statusCode := utils.ConvertProtoErrorToResponse(orderResp.Error)
-				c.JSON(statusCode, errorResp)
+				utils.WriteError(c, statusCode, errorResp)
 				return
 			}
 
 			// Fallback if error structure is not available
-			c.JSON(http.StatusBadRequest, utils.ErrorResponse{
+			utils.WriteError(c, http.StatusBadRequest, utils.ErrorResponse{
 				Type:    "UNKNOWN_ERROR",
 				Message: "Failed to get order",
 			})
@@ -366,13 +369,19 @@ func GetOrder(orderClient grpc.OrderClient, paymentClient grpc.PaymentClient) gi
 		}
 		orderData, err := json.Marshal(orderResp)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to marshal order data"})
+			utils.WriteError(c, http.StatusInternalServerError, utils.ErrorResponse{
+				Type:    "INTERNAL_ERROR",
+				Message: "Failed to marshal order data",
+			})
 			return
 		}
 
 		var response map[string]interface{}
 		if err := json.Unmarshal(orderData, &response); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to unmarshal order data"})
+			utils.WriteError(c, http.StatusInternalServerError, utils.ErrorResponse{
+				Type:    "INTERNAL_ERROR",
+				Message: "Failed to unmarshal order data",
+			})
 			return
 		}
 
@@ -382,9 +391,18 @@ func GetOrder(orderClient grpc.OrderClient, paymentClient grpc.PaymentClient) gi
 			CustomerId: customerID,
 		})
 
-		if err == nil && paymentResp.Success {
+		// The order is still returned when payment-svc is down, just without its payment status
+		if err != nil {
+			utils.WarnContext(c.Request.Context(), "Failed to get payment status for order", map[string]interface{}{
+				"error":    err,
+				"order_id": orderID,
+			})
+		} else if paymentResp.Success {
 			response["payment_status"] = paymentResp.Status
 			response["transaction_id"] = paymentResp.TransactionId
+			if paymentResp.ReceiptUrl != "" {
+				response["receipt_url"] = paymentResp.ReceiptUrl
+			}
 		}
 
 		c.JSON(http.StatusOK, response)
@@ -415,7 +433,7 @@ func ListCustomersOrders(orderClient grpc.OrderClient) gin.HandlerFunc {
 	return func(c *gin.Context) { // Get customer ID from the token
 		customerID, ok := c.Get("user_id")
 		if !ok {
-			c.JSON(http.StatusUnauthorized, utils.ErrorResponse{
+			utils.WriteError(c, http.StatusUnauthorized, utils.ErrorResponse{
 				Type:    "AUTH_ERROR",
 				Message: "User ID not found in token",
 			})
@@ -449,30 +467,28 @@ func ListCustomersOrders(orderClient grpc.OrderClient) gin.HandlerFunc {
 			Limit:      int32(limit),
 		})
 		if err != nil {
-			utils.Error("Failed to list orders", map[string]interface{}{
+			utils.ErrorContext(c.Request.Context(), "Failed to list orders", map[string]interface{}{
 				"error": err,
 			})
-			c.JSON(http.StatusInternalServerError, utils.ErrorResponse{
-				Type:    "INTERNAL_ERROR",
-				Message: "Failed to list orders",
-			})
+			errorResp, statusCode := utils.ConvertGrpcErrorToResponse(err, "Failed to list orders")
+			utils.WriteError(c, statusCode, errorResp)
 			return
 		}
 
 		// Check if the response indicates a failure
 		if !resp.Success {
-			utils.Error("Failed to list orders", map[string]interface{}{
+			utils.ErrorContext(c.Request.Context(), "Failed to list orders", map[string]interface{}{
 				"error": resp,
 			})
 
 			if resp.Error != nil {
 				errorResp, statusCode := utils.ConvertProtoErrorToResponse(resp.Error)
-				c.JSON(statusCode, errorResp)
+				utils.WriteError(c, statusCode, errorResp)
 				return
 			}
 
 			// Fallback if error structure is not available
-			c.JSON(http.StatusBadRequest, utils.ErrorResponse{
+			utils.WriteError(c, http.StatusBadRequest, utils.ErrorResponse{
 				Type:    "UNKNOWN_ERROR",
 				Message: "Failed to list orders",
 			})
@@ -532,30 +548,28 @@ func ListAllOrders(orderClient grpc.OrderClient) gin.HandlerFunc {
 			Limit:     int32(limit),
 		})
 		if err != nil {
-			utils.Error("Failed to list orders", map[string]interface{}{
+			utils.ErrorContext(c.Request.Context(), "Failed to list orders", map[string]interface{}{
 				"error": err,
 			})
-			c.JSON(http.StatusInternalServerError, utils.ErrorResponse{
-				Type:    "INTERNAL_ERROR",
-				Message: "Failed to list orders",
-			})
+			errorResp, statusCode := utils.ConvertGrpcErrorToResponse(err, "Failed to list orders")
+			utils.WriteError(c, statusCode, errorResp)
 			return
 		}
 
 		// Check if the response indicates a failure
 		if !resp.Success {
-			utils.Error("Failed to list all orders", map[string]interface{}{
+			utils.ErrorContext(c.Request.Context(), "Failed to list all orders", map[string]interface{}{
 				"error": resp,
 			})
 
 			if resp.Error != nil {
 				errorResp, statusCode := utils.ConvertProtoErrorToResponse(resp.Error)
-				c.JSON(statusCode, errorResp)
+				utils.WriteError(c, statusCode, errorResp)
 				return
 			}
 
 			// Fallback if error structure is not available
-			c.JSON(http.StatusBadRequest, utils.ErrorResponse{
+			utils.WriteError(c, http.StatusBadRequest, utils.ErrorResponse{
 				Type:    "UNKNOWN_ERROR",
 				Message: "Failed to list orders",
 			})
@@ -592,7 +606,7 @@ func UpdateOrderStatus(orderClient grpc.OrderClient) gin.HandlerFunc {
 		userRole, ok := c.Get("user_role")
 		var customerID string
 		if !ok {
-			c.JSON(http.StatusUnauthorized, utils.ErrorResponse{
+			utils.WriteError(c, http.StatusUnauthorized, utils.ErrorResponse{
 				Type:    "AUTH_ERROR",
 				Message: "User Role not found in token",
 			})
@@ -601,7 +615,7 @@ func UpdateOrderStatus(orderClient grpc.OrderClient) gin.HandlerFunc {
 
 		userId, ok := c.Get("user_id")
 		if !ok {
-			c.JSON(http.StatusUnauthorized, utils.ErrorResponse{
+			utils.WriteError(c, http.StatusUnauthorized, utils.ErrorResponse{
 				Type:    "AUTH_ERROR",
 				Message: "User ID not found in token",
 			})
@@ -617,7 +631,7 @@ func UpdateOrderStatus(orderClient grpc.OrderClient) gin.HandlerFunc {
 
 		var req OrderStatusRequest
 		if err := c.ShouldBindJSON(&req); err != nil {
-			c.JSON(http.StatusBadRequest, utils.ErrorResponse{
+			utils.WriteError(c, http.StatusBadRequest, utils.ErrorResponse{
 				Type:    "VALIDATION_ERROR",
 				Message: "Invalid request format",
 				Details: map[string]string{"format": err.Error()},
@@ -631,30 +645,28 @@ func UpdateOrderStatus(orderClient grpc.OrderClient) gin.HandlerFunc {
 			Status:     req.Status,
 		})
 		if err != nil {
-			utils.Error("Failed to update order status", map[string]interface{}{
+			utils.ErrorContext(c.Request.Context(), "Failed to update order status", map[string]interface{}{
 				"error": err,
 			})
-			c.JSON(http.StatusInternalServerError, utils.ErrorResponse{
-				Type:    "INTERNAL_ERROR",
-				Message: "Failed to update order",
-			})
+			errorResp, statusCode := utils.ConvertGrpcErrorToResponse(err, "Failed to update order")
+			utils.WriteError(c, statusCode, errorResp)
 			return
 		}
 
 		// Check if the response indicates a failure
 		if !resp.Success {
-			utils.Error("Failed to update order status", map[string]interface{}{
+			utils.ErrorContext(c.Request.Context(), "Failed to update order status", map[string]interface{}{
 				"error": resp.Message,
 			})
 
 			if resp.Error != nil {
 				errorResp, statusCode := utils.ConvertProtoErrorToResponse(resp.Error)
-				c.JSON(statusCode, errorResp)
+				utils.WriteError(c, statusCode, errorResp)
 				return
 			}
 
 			// Fallback if error structure is not available
-			c.JSON(http.StatusBadRequest, utils.ErrorResponse{
+			utils.WriteError(c, http.StatusBadRequest, utils.ErrorResponse{
 				Type:    "UNKNOWN_ERROR",
 				Message: resp.Message,
 			})
@@ -664,3 +676,224 @@ func UpdateOrderStatus(orderClient grpc.OrderClient) gin.HandlerFunc {
 		c.JSON(http.StatusOK, resp)
 	}
 }
+
+type CancelOrderRequest struct {
+	Reason string `json:"reason" example:"Ordered by mistake"`
+}
+
+// CancelOrderResponse describes a cancelled order and the refund issued for it, if it was paid. When
+// the refund could not be issued right away, it is queued and RefundPending is set.
+type CancelOrderResponse struct {
+	OrderID       string          `json:"order_id"`
+	OrderStatus   string          `json:"order_status"`
+	Refund        *RefundResponse `json:"refund,omitempty"`
+	RefundPending bool            `json:"refund_pending,omitempty"`
+	Message       string          `json:"message"`
+}
+
+// refundableStatuses are the payment statuses for which money has been collected
+var refundableStatuses = map[string]bool{
+	"complete":           true,
+	"completed":          true,
+	"paid":               true,
+	"succeeded":          true,
+	"partially_refunded": true,
+}
+
+// CancelOrder cancels an order and requests a refund of its payment
+// @Summary Cancel an order
+// @Description Cancels an order and, when it has been paid, refunds the remaining balance of its payment.
+// @Description A refund that cannot be issued right away is queued and retried. Cancelling an order that
+// @Description is already cancelled issues its refund if it is still missing.
+// @Tags Orders
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer token"
+// @Param id path string true "Order ID"
+// @Param request body CancelOrderRequest false "Cancellation reason"
+// @Success 200 {object} CancelOrderResponse
+// @Success 202 {object} CancelOrderResponse "Order cancelled, refund queued"
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 502 {object} ErrorResponse
+// @Router /api/v1/orders/{id}/cancel [post]
+func CancelOrder(orderClient grpc.OrderClient, paymentClient grpc.PaymentClient, refundQueue *webhookqueue.Queue) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, ok := c.Get("user_id")
+		if !ok {
+			utils.WriteError(c, http.StatusUnauthorized, utils.ErrorResponse{
+				Type:    "AUTH_ERROR",
+				Message: "User ID not found in token",
+			})
+			return
+		}
+
+		customerID := userId.(string)
+		orderID := c.Param("id")
+
+		// The reason is optional, so an empty body is allowed
+		var req CancelOrderRequest
+		if c.Request.ContentLength > 0 {
+			if err := c.ShouldBindJSON(&req); err != nil {
+				utils.WriteError(c, http.StatusBadRequest, utils.ErrorResponse{
+					Type:    "VALIDATION_ERROR",
+					Message: "Invalid request format",
+					Details: map[string]string{"format": err.Error()},
+				})
+				return
+			}
+		}
+
+		order, err := orderClient.GetOrder(c.Request.Context(), &proto.GetOrderRequest{
+			OrderId:    orderID,
+			CustomerId: customerID,
+		})
+		if err != nil {
+			utils.ErrorContext(c.Request.Context(), "Failed to get order to cancel", map[string]interface{}{
+				"error":    err,
+				"order_id": orderID,
+			})
+			errorResp, statusCode := utils.ConvertGrpcErrorToResponse(err, "Failed to cancel order")
+			utils.WriteError(c, statusCode, errorResp)
+			return
+		}
+
+		if !order.Success {
+			if order.Error != nil {
+				errorResp, statusCode := utils.ConvertProtoErrorToResponse(order.Error)
+				utils.WriteError(c, statusCode, errorResp)
+				return
+			}
+
+			utils.WriteError(c, http.StatusBadRequest, utils.ErrorResponse{
+				Type:    "UNKNOWN_ERROR",
+				Message: "Failed to cancel order",
+			})
+			return
+		}
+
+		// An order cancelled by an earlier attempt is not cancelled again, so that retrying completes its refund
+		if order.Status != "cancelled" {
+			// Cancel first, so that order-svc can refuse orders that have already shipped before any money moves
+			resp, err := orderClient.UpdateOrderStatus(c.Request.Context(), &proto.UpdateOrderStatusRequest{
+				OrderId:    orderID,
+				CustomerId: customerID,
+				Status:     "cancelled",
+			})
+			if err != nil {
+				utils.ErrorContext(c.Request.Context(), "Failed to cancel order", map[string]interface{}{
+					"error":    err,
+					"order_id": orderID,
+				})
+				errorResp, statusCode := utils.ConvertGrpcErrorToResponse(err, "Failed to cancel order")
+				utils.WriteError(c, statusCode, errorResp)
+				return
+			}
+
+			if !resp.Success {
+				utils.ErrorContext(c.Request.Context(), "Failed to cancel order", map[string]interface{}{
+					"error":    resp.Message,
+					"order_id": orderID,
+				})
+
+				if resp.Error != nil {
+					errorResp, statusCode := utils.ConvertProtoErrorToResponse(resp.Error)
+					utils.WriteError(c, statusCode, errorResp)
+					return
+				}
+
+				utils.WriteError(c, http.StatusBadRequest, utils.ErrorResponse{
+					Type:    "UNKNOWN_ERROR",
+					Message: resp.Message,
+				})
+				return
+			}
+		}
+
+		result := CancelOrderResponse{
+			OrderID:     orderID,
+			OrderStatus: "cancelled",
+			Message:     "Order cancelled",
+		}
+
+		refundFailed := func(err error) {
+			utils.ErrorContext(c.Request.Context(), "Failed to refund cancelled order", map[string]interface{}{
+				"error":    err,
+				"order_id": orderID,
+			})
+			utils.WriteError(c, http.StatusBadGateway, utils.ErrorResponse{
+				Type:    "REFUND_FAILED",
+				Message: "Order was cancelled, but the refund could not be issued. Please try again or contact support.",
+				Details: map[string]string{"order_id": orderID},
+			})
+		}
+
+		payment, err := paymentClient.GetPaymentByOrderID(c.Request.Context(), &proto.GetPaymentByOrderIDRequest{
+			OrderId:    orderID,
+			CustomerId: customerID,
+		})
+		if err != nil {
+			refundFailed(err)
+			return
+		}
+
+		// Orders that were never paid, or were refunded already, have nothing to refund
+		if !payment.Success || !refundableStatuses[payment.Status] {
+			c.JSON(http.StatusOK, result)
+			return
+		}
+
+		reason := "Cancelled by customer"
+		if req.Reason != "" {
+			reason += ": " + req.Reason
+		}
+
+		refund := cancellationRefund{
+			OrderID:       orderID,
+			CustomerID:    customerID,
+			PaymentID:     payment.PaymentId,
+			TransactionID: payment.TransactionId,
+			Reason:        reason,
+		}
+
+		resp, err := refund.issue(c.Request.Context(), paymentClient)
+		if err != nil {
+			utils.WarnContext(c.Request.Context(), "Failed to refund cancelled order, queueing the refund", map[string]interface{}{
+				"error":    err,
+				"order_id": orderID,
+			})
+
+			// The order stays cancelled, so the refund is persisted and retried until it is issued
+			if err := refund.enqueue(c.Request.Context(), refundQueue); err != nil {
+				refundFailed(err)
+				return
+			}
+
+			result.Refund = &RefundResponse{
+				PaymentID:     payment.PaymentId,
+				OrderID:       orderID,
+				PaymentStatus: payment.Status,
+			}
+			result.RefundPending = true
+			result.Message = "Order cancelled, the refund will be issued shortly"
+			c.JSON(http.StatusAccepted, result)
+			return
+		}
+
+		result.Refund = &RefundResponse{
+			PaymentID:      payment.PaymentId,
+			RefundID:       resp.RefundId,
+			OrderID:        orderID,
+			RefundedAmount: resp.RefundedAmount,
+			PaymentStatus:  resp.Status,
+			Message:        resp.Message,
+		}
+		result.Message = "Order cancelled and refund issued"
+
+		c.JSON(http.StatusOK, result)
+	}
+}
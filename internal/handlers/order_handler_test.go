@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/PharmaKart/gateway-svc/internal/proto"
+	"github.com/PharmaKart/gateway-svc/internal/webhookqueue"
+	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// newRefundQueue opens a queue in a temporary directory that issues queued refunds through payments
+func newRefundQueue(t *testing.T, payments *fakePaymentClient) *webhookqueue.Queue {
+	t.Helper()
+
+	queue, err := webhookqueue.New(t.TempDir(), ProcessQueuedEvent(nil, payments, nil, nil, nil), webhookqueue.Options{
+		Workers:        1,
+		MaxAttempts:    5,
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     10 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("open queue: %v", err)
+	}
+	t.Cleanup(func() { queue.Close(context.Background()) })
+	return queue
+}
+
+func cancelOrder(t *testing.T, orders *fakeOrderClient, payments *fakePaymentClient, queue *webhookqueue.Queue) (int, CancelOrderResponse) {
+	t.Helper()
+
+	r := gin.New()
+	r.POST("/orders/:id/cancel", func(c *gin.Context) {
+		c.Set("user_id", "customer-1")
+	}, CancelOrder(orders, payments, queue))
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/orders/order-1/cancel", nil))
+
+	var resp CancelOrderResponse
+	if w.Code < http.StatusBadRequest {
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("decode response: %v", err)
+		}
+	}
+	return w.Code, resp
+}
+
+func paidPayment() *proto.GetPaymentResponse {
+	return &proto.GetPaymentResponse{Success: true, PaymentId: "pay-1", TransactionId: "pi_1", OrderId: "order-1", Amount: 100, Status: "completed"}
+}
+
+func TestCancelOrderRefundsPaidOrder(t *testing.T) {
+	orders := &fakeOrderClient{status: "paid"}
+	payments := &fakePaymentClient{payment: paidPayment()}
+
+	code, resp := cancelOrder(t, orders, payments, nil)
+	if code != http.StatusOK || resp.Refund == nil || resp.RefundPending {
+		t.Fatalf("status = %d, response = %+v, want a cancelled and refunded order", code, resp)
+	}
+
+	if updates := orders.statusUpdates(); len(updates) != 1 || updates[0] != "cancelled" {
+		t.Fatalf("order updates = %v, want [cancelled]", updates)
+	}
+	refunds, keys := payments.refundCalls()
+	if len(refunds) != 1 || refunds[0].Amount != 0 || keys[0] != "cancel-order-1" {
+		t.Fatalf("refunds = %v with keys %q, want one refund of the balance keyed by order", refunds, keys)
+	}
+}
+
+func TestCancelOrderWithoutPayment(t *testing.T) {
+	orders := &fakeOrderClient{status: "pending"}
+	payments := &fakePaymentClient{payment: &proto.GetPaymentResponse{Success: true, PaymentId: "pay-1", Status: "pending"}}
+
+	code, resp := cancelOrder(t, orders, payments, nil)
+	if code != http.StatusOK || resp.Refund != nil {
+		t.Fatalf("status = %d, response = %+v, want a cancelled order without refund", code, resp)
+	}
+	if refunds, _ := payments.refundCalls(); len(refunds) != 0 {
+		t.Fatalf("issued %d refunds, want none", len(refunds))
+	}
+}
+
+func TestCancelOrderQueuesFailedRefund(t *testing.T) {
+	orders := &fakeOrderClient{status: "paid"}
+	payments := &fakePaymentClient{payment: paidPayment()}
+
+	// payment-svc is down for the request and the first retry
+	failures := 2
+	payments.refund = func(req *proto.RefundPaymentRequest) (*proto.RefundPaymentResponse, error) {
+		if failures > 0 {
+			failures--
+			return nil, status.Error(codes.Unavailable, "payment-svc is down")
+		}
+		return &proto.RefundPaymentResponse{Success: true, RefundId: "re_1", RefundedAmount: 100, Status: "refunded"}, nil
+	}
+	queue := newRefundQueue(t, payments)
+
+	code, resp := cancelOrder(t, orders, payments, queue)
+	if code != http.StatusAccepted || !resp.RefundPending || resp.OrderStatus != "cancelled" {
+		t.Fatalf("status = %d, response = %+v, want a cancelled order with a pending refund", code, resp)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for queue.Stats().Pending > 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if stats := queue.Stats(); stats.Pending != 0 || stats.DeadLetters != 0 {
+		t.Fatalf("queue stats = %+v, want the refund processed", stats)
+	}
+
+	refunds, keys := payments.refundCalls()
+	if len(refunds) != 3 {
+		t.Fatalf("refund attempts = %d, want 3", len(refunds))
+	}
+	for _, key := range keys {
+		if key != "cancel-order-1" {
+			t.Fatalf("idempotency keys = %q, want every attempt keyed by order", keys)
+		}
+	}
+}
+
+func TestCancelOrderDeadLettersRejectedRefund(t *testing.T) {
+	orders := &fakeOrderClient{status: "paid"}
+	payments := &fakePaymentClient{payment: paidPayment()}
+	payments.refund = func(req *proto.RefundPaymentRequest) (*proto.RefundPaymentResponse, error) {
+		return &proto.RefundPaymentResponse{Success: false, Message: "charge disputed"}, nil
+	}
+	queue := newRefundQueue(t, payments)
+
+	if code, _ := cancelOrder(t, orders, payments, queue); code != http.StatusAccepted {
+		t.Fatalf("status = %d, want %d", code, http.StatusAccepted)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for queue.Stats().DeadLetters == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	dead := queue.DeadLetters()
+	if len(dead) != 1 || dead[0].Attempts != 1 || dead[0].ID != "order-1" {
+		t.Fatalf("dead letters = %+v, want the refund after a single attempt", dead)
+	}
+}
+
+func TestCancelOrderIsReentrant(t *testing.T) {
+	// An earlier attempt cancelled the order, but could neither refund nor queue the refund
+	orders := &fakeOrderClient{status: "paid"}
+	payments := &fakePaymentClient{payment: paidPayment()}
+	payments.refund = func(req *proto.RefundPaymentRequest) (*proto.RefundPaymentResponse, error) {
+		return nil, status.Error(codes.Unavailable, "payment-svc is down")
+	}
+
+	if code, _ := cancelOrder(t, orders, payments, nil); code != http.StatusBadGateway {
+		t.Fatalf("first attempt: status = %d, want %d", code, http.StatusBadGateway)
+	}
+
+	payments.mu.Lock()
+	payments.refund = nil
+	payments.mu.Unlock()
+
+	code, resp := cancelOrder(t, orders, payments, nil)
+	if code != http.StatusOK || resp.Refund == nil {
+		t.Fatalf("second attempt: status = %d, response = %+v, want the refund issued", code, resp)
+	}
+	if updates := orders.statusUpdates(); len(updates) != 1 {
+		t.Fatalf("order updates = %v, want the order cancelled once", updates)
+	}
+	if refunds, _ := payments.refundCalls(); len(refunds) != 2 {
+		t.Fatalf("refund attempts = %d, want 2", len(refunds))
+	}
+}
@@ -3,32 +3,63 @@ package handlers
 import (
 	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"net/http"
+	"strconv"
+	"time"
 
+	"github.com/PharmaKart/gateway-svc/internal/eventstore"
 	"github.com/PharmaKart/gateway-svc/internal/grpc"
+	"github.com/PharmaKart/gateway-svc/internal/payments"
 	"github.com/PharmaKart/gateway-svc/internal/proto"
-	"github.com/PharmaKart/gateway-svc/pkg/config"
+	"github.com/PharmaKart/gateway-svc/internal/webhookqueue"
+	"github.com/PharmaKart/gateway-svc/pkg/metrics"
 	"github.com/PharmaKart/gateway-svc/pkg/utils"
 	"github.com/gin-gonic/gin"
-	"github.com/stripe/stripe-go"
-	"github.com/stripe/stripe-go/webhook"
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc/metadata"
 )
 
-// HandleWebhook processes Stripe webhook events
-// @Summary Process Stripe webhook
-// @Description Processes incoming Stripe webhook events
+var paymentWebhookEvents = metrics.Factory.NewCounterVec(prometheus.CounterOpts{
+	Name: "gateway_payment_webhook_events_total",
+	Help: "Verified payment webhook events processed, by provider and event type.",
+}, []string{"provider", "type", "handled"})
+
+// HandleWebhook verifies payment provider webhook events and queues them for processing
+// @Summary Process payment provider webhook
+// @Description Verifies incoming webhook events of a payment provider, such as stripe or paypal, and queues them for processing
 // @Tags Payments
 // @Accept json
 // @Produce json
+// @Param provider path string true "Payment provider"
 // @Success 200 {object} nil "OK"
 // @Failure 400 {object} utils.ErrorResponse "Bad Request"
+// @Failure 404 {object} utils.ErrorResponse "Not Found"
 // @Failure 503 {object} utils.ErrorResponse "Service Unavailable"
-// @Router /api/v1/webhook [post]
-func HandleWebhook(cfg *config.Config, paymentClient grpc.PaymentClient) gin.HandlerFunc {
+// @Router /api/v1/payment/webhook/{provider} [post]
+func HandleWebhook(providers payments.Providers, queue *webhookqueue.Queue) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		const MaxBodyBytes = int64(65536)
 
+		// The webhook URL without a provider predates PayPal and receives Stripe events
+		providerName := c.Param("provider")
+		if providerName == "" {
+			providerName = "stripe"
+		}
+
+		provider, ok := providers[providerName]
+		if !ok {
+			utils.WriteError(c, http.StatusNotFound, utils.ErrorResponse{
+				Type:    "NOT_FOUND_ERROR",
+				Message: "Unknown payment provider",
+				Details: map[string]string{"provider": providerName},
+			})
+			return
+		}
+
 		// Read the body into a buffer
 		var buf bytes.Buffer
 		reader := io.TeeReader(c.Request.Body, &buf)
@@ -36,10 +67,10 @@ func HandleWebhook(cfg *config.Config, paymentClient grpc.PaymentClient) gin.Han
 		// Read the body with max bytes limit
 		payload, err := io.ReadAll(io.LimitReader(reader, MaxBodyBytes))
 		if err != nil {
-			utils.Error("Error reading request body", map[string]interface{}{
+			utils.ErrorContext(c.Request.Context(), "Error reading request body", map[string]interface{}{
 				"error": err,
 			})
-			c.JSON(http.StatusServiceUnavailable, utils.ErrorResponse{
+			utils.WriteError(c, http.StatusServiceUnavailable, utils.ErrorResponse{
 				Type:    "SERVICE_UNAVAILABLE",
 				Message: "Error reading request body",
 				Details: map[string]string{"error": err.Error()},
@@ -47,17 +78,23 @@ func HandleWebhook(cfg *config.Config, paymentClient grpc.PaymentClient) gin.Han
 			return
 		}
 
-		// This is your Stripe CLI webhook secret for testing your endpoint locally.
-		endpointSecret := cfg.StripeWebhookSecret
-
-		// Pass the request body and Stripe-Signature header to ConstructEvent, along
-		// with the webhook signing key.
-		event, err := webhook.ConstructEvent(payload, c.GetHeader("Stripe-Signature"), endpointSecret)
+		delivery, err := provider.VerifyWebhook(c.Request.Context(), payload, c.Request.Header)
 		if err != nil {
-			utils.Error("Error verifying webhook signature", map[string]interface{}{
-				"error": err,
+			utils.ErrorContext(c.Request.Context(), "Error verifying webhook signature", map[string]interface{}{
+				"error":    err,
+				"provider": providerName,
 			})
-			c.JSON(http.StatusBadRequest, utils.ErrorResponse{
+
+			// The provider retries when the signature could not be checked, e.g. while its certificates are unreachable
+			if !errors.Is(err, payments.ErrInvalidSignature) {
+				utils.WriteError(c, http.StatusServiceUnavailable, utils.ErrorResponse{
+					Type:    "SERVICE_UNAVAILABLE",
+					Message: "Error verifying webhook signature",
+				})
+				return
+			}
+
+			utils.WriteError(c, http.StatusBadRequest, utils.ErrorResponse{
 				Type:    "VALIDATION_ERROR",
 				Message: "Error verifying webhook signature",
 				Details: map[string]string{"error": err.Error()},
@@ -65,156 +102,442 @@ func HandleWebhook(cfg *config.Config, paymentClient grpc.PaymentClient) gin.Han
 			return
 		}
 
-		// Unmarshal the event data into an appropriate struct depending on its Type
-		switch event.Type {
-		case "checkout.session.async_payment_failed":
-			handleAsyncPaymentFailed(event, paymentClient)
-		case "charge.succeeded":
-			handleAsyncPaymentSucceeded(event)
-		case "checkout.session.completed":
-			handleCheckoutSessionCompleted(event, paymentClient)
-		case "checkout.session.expired":
-			handleCheckoutSessionExpired(event, paymentClient)
-		default:
-			utils.Warn("Unhandled event type", map[string]interface{}{
-				"event": event.Type,
+		// Persist the event before acknowledging it, so that it survives a payment-svc outage or a restart
+		err = queue.Enqueue(webhookqueue.Event{
+			ID:        delivery.ID,
+			Source:    providerName,
+			Type:      delivery.Type,
+			Payload:   payload,
+			RequestID: utils.RequestIDFromContext(c.Request.Context()),
+		})
+		if err != nil {
+			utils.ErrorContext(c.Request.Context(), "Failed to queue webhook event", map[string]interface{}{
+				"error":    err,
+				"provider": providerName,
+				"event":    delivery.ID,
+				"type":     delivery.Type,
+			})
+			utils.WriteError(c, http.StatusServiceUnavailable, utils.ErrorResponse{
+				Type:    "SERVICE_UNAVAILABLE",
+				Message: "Failed to queue webhook event",
 			})
+			return
 		}
 
 		c.JSON(http.StatusOK, gin.H{
 			"success": true,
-			"message": "Webhook processed successfully",
+			"message": "Webhook received",
 		})
 	}
 }
 
-// Handler functions for different event types
-func handleAsyncPaymentFailed(event stripe.Event, paymentClient grpc.PaymentClient) {
-	// Handle async payment failed
-	utils.Warn("Handling async payment failed event", map[string]interface{}{
-		"event": event.ID,
-	})
+// ProcessPaymentEvent returns the queue handler that applies payment provider events. Providers
+// redeliver events, so only the first delivery of each event is processed. The events of a payment
+// are applied one at a time, so that each one sees the payment as the previous one left it.
+func ProcessPaymentEvent(providers payments.Providers, paymentClient grpc.PaymentClient, orderClient grpc.OrderClient, deduplicator *eventstore.Deduplicator, locker *eventstore.Locker) webhookqueue.Handler {
+	return func(ctx context.Context, queued webhookqueue.Event) error {
+		provider, ok := providers[queued.Source]
+		if !ok {
+			return webhookqueue.Permanent(fmt.Errorf("payment provider %q is not enabled", queued.Source))
+		}
 
-	_, err := paymentClient.StorePayment(context.Background(), &proto.StorePaymentRequest{
-		TransactionId: event.ID,
-		OrderId:       event.Data.Object["client_reference_id"].(string),
-		CustomerId:    event.Data.Object["customer"].(string),
-		Amount:        event.Data.Object["amount_total"].(float64),
-		Status:        "failed",
-	})
+		// Events with missing or malformed data are dead-lettered for inspection instead of retried
+		event, err := provider.ParseEvent(queued.Payload)
+		if err != nil {
+			return webhookqueue.Permanent(err)
+		}
 
-	if err != nil {
-		utils.Error("Failed to store failed payment", map[string]interface{}{
-			"error": err,
-			"event": event.ID,
+		duplicate, err := deduplicator.Process(ctx, event.Provider, event.ID, func(ctx context.Context) error {
+			return locker.Do(ctx, paymentLockKey(event), func(ctx context.Context) error {
+				return applyPaymentEvent(ctx, event, paymentClient, orderClient)
+			})
 		})
+		if duplicate {
+			utils.InfoContext(ctx, "Ignoring already processed webhook event", map[string]interface{}{
+				"provider": event.Provider,
+				"event":    event.ID,
+				"type":     event.ProviderType,
+			})
+		}
+		return err
 	}
 }
 
-func handleAsyncPaymentSucceeded(event stripe.Event) *string {
-	// Handle async payment succeeded
+// paymentLockKey identifies the payment of an event. Every event that moves money carries the provider's
+// payment reference, with or without the order, so events are locked by it and by their order otherwise.
+func paymentLockKey(event *payments.Event) string {
+	if event.PaymentReference != "" {
+		return event.Provider + ":" + event.PaymentReference
+	}
+	return event.Provider + ":order:" + event.OrderID
+}
 
-	var receiptUrl *string
+// cancellationRefundSource is the queue source of the refunds of cancelled orders
+const cancellationRefundSource = "order_cancellation"
 
-	receiptURL, ok := event.Data.Object["receipt_url"].(string)
-	if ok {
-		receiptUrl = &receiptURL
-	} else {
-		utils.Warn("Receipt URL not found in event data", map[string]interface{}{
-			"event": event.ID,
-		})
+// ProcessQueuedEvent returns the queue handler, which issues the queued refunds of cancelled orders and
+// applies payment provider events
+func ProcessQueuedEvent(providers payments.Providers, paymentClient grpc.PaymentClient, orderClient grpc.OrderClient, deduplicator *eventstore.Deduplicator, locker *eventstore.Locker) webhookqueue.Handler {
+	processPaymentEvent := ProcessPaymentEvent(providers, paymentClient, orderClient, deduplicator, locker)
+
+	return func(ctx context.Context, queued webhookqueue.Event) error {
+		if queued.Source == cancellationRefundSource {
+			return processCancellationRefund(ctx, queued, paymentClient)
+		}
+		return processPaymentEvent(ctx, queued)
 	}
+}
+
+// cancellationRefund is the refund of the remaining balance of a cancelled order
+type cancellationRefund struct {
+	OrderID       string `json:"order_id"`
+	CustomerID    string `json:"customer_id"`
+	PaymentID     string `json:"payment_id"`
+	TransactionID string `json:"transaction_id"`
+	Reason        string `json:"reason"`
+}
+
+// issue refunds the payment. The refund is keyed by order, so that retries never refund twice.
+func (r *cancellationRefund) issue(ctx context.Context, paymentClient grpc.PaymentClient) (*proto.RefundPaymentResponse, error) {
+	ctx = metadata.AppendToOutgoingContext(ctx, grpc.IdempotencyKeyHeader, "cancel-"+r.OrderID)
 
-	utils.Info("Handling async payment succeeded event", map[string]interface{}{
-		"event":       event.ID,
-		"receipt_url": receiptUrl,
+	resp, err := paymentClient.RefundPayment(ctx, &proto.RefundPaymentRequest{
+		TransactionId: r.TransactionID,
+		PaymentId:     r.PaymentID,
+		Reason:        r.Reason,
+		CustomerId:    r.CustomerID,
 	})
+	if err != nil {
+		return nil, err
+	}
+	if !resp.Success {
+		return nil, &refundRejectedError{resp: resp}
+	}
+	return resp, nil
+}
+
+// enqueue persists the refund so that it is retried in the background
+func (r *cancellationRefund) enqueue(ctx context.Context, queue *webhookqueue.Queue) error {
+	if queue == nil {
+		return errors.New("refund queue is not configured")
+	}
+
+	payload, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	return queue.Enqueue(webhookqueue.Event{
+		ID:        r.OrderID,
+		Source:    cancellationRefundSource,
+		Type:      "refund",
+		Payload:   payload,
+		RequestID: utils.RequestIDFromContext(ctx),
+	})
+}
 
-	return receiptUrl
+// refundRejectedError means payment-svc refused the refund, e.g. because the payment was refunded meanwhile
+type refundRejectedError struct {
+	resp *proto.RefundPaymentResponse
 }
 
-func handleCheckoutSessionCompleted(event stripe.Event, paymentClient grpc.PaymentClient) {
-	utils.Info("Handling checkout session completed event", map[string]interface{}{
-		"event": event.ID,
+func (e *refundRejectedError) Error() string {
+	return "refund rejected: " + e.resp.Message
+}
+
+// processCancellationRefund issues a queued refund. A refund that payment-svc rejects is dead-lettered
+// for support staff rather than retried.
+func processCancellationRefund(ctx context.Context, queued webhookqueue.Event, paymentClient grpc.PaymentClient) error {
+	var refund cancellationRefund
+	if err := json.Unmarshal(queued.Payload, &refund); err != nil {
+		return webhookqueue.Permanent(fmt.Errorf("malformed refund: %w", err))
+	}
+
+	resp, err := refund.issue(ctx, paymentClient)
+	if err != nil {
+		if errors.As(err, new(*refundRejectedError)) {
+			return webhookqueue.Permanent(err)
+		}
+		return err
+	}
+
+	utils.InfoContext(ctx, "Refunded cancelled order", map[string]interface{}{
+		"order_id":        refund.OrderID,
+		"payment_id":      refund.PaymentID,
+		"refunded_amount": resp.RefundedAmount,
+		"attempts":        queued.Attempts + 1,
 	})
-	metadata := event.Data.Object["metadata"].(map[string]interface{})
+	return nil
+}
 
-	// Extract individual fields safely
-	orderID, ok := metadata["order_id"].(string)
-	if !ok {
-		// Handle error or log missing metadata
-		utils.Warn("Order ID not found in metadata", map[string]interface{}{
-			"event": event.ID,
-		})
-		return
+// paymentTransition is the payment status recorded for an event, and the status its order moves to
+type paymentTransition struct {
+	paymentStatus string
+	orderStatus   string
+}
+
+var paymentTransitions = map[payments.EventType]paymentTransition{
+	payments.PaymentCompleted:         {paymentStatus: "completed", orderStatus: "paid"},
+	payments.PaymentPending:           {paymentStatus: "pending"},
+	payments.PaymentCaptured:          {paymentStatus: "succeeded"},
+	payments.PaymentFailed:            {paymentStatus: "failed", orderStatus: "payment_failed"},
+	payments.PaymentExpired:           {paymentStatus: "expired"},
+	payments.PaymentRefunded:          {paymentStatus: "refunded", orderStatus: "refunded"},
+	payments.PaymentPartiallyRefunded: {paymentStatus: "partially_refunded", orderStatus: "partially_refunded"},
+	payments.PaymentDisputed:          {paymentStatus: "disputed", orderStatus: "disputed"},
+}
+
+// paymentStatusAllowed reports whether a payment event may move a payment from its current status to next.
+// Providers deliver events out of order, so a late event must not undo a later one, e.g. a failed attempt
+// arriving after the payment succeeded or was refunded. The check only holds because the events of a
+// payment are applied one at a time, see ProcessPaymentEvent.
+func paymentStatusAllowed(current, next string) bool {
+	if current == "" || current == next {
+		return true
+	}
+
+	switch next {
+	case "pending", "failed", "expired":
+		// A customer can retry a failed attempt, but nothing replaces a payment once money has been collected
+		return current == "pending" || current == "failed"
+	case "completed", "succeeded":
+		return current == "pending" || current == "failed" || current == "completed" || current == "succeeded"
+	case "partially_refunded", "disputed":
+		return current != "refunded" && current != "expired"
+	case "refunded":
+		return current != "expired"
+	}
+	return true
+}
+
+// orderStatusAllowed reports whether a payment event may move an order from its current status to next
+func orderStatusAllowed(current, next string) bool {
+	if current == "" || current == next {
+		return true
 	}
 
-	customerID, ok := metadata["customer_id"].(string)
+	switch next {
+	case "paid", "payment_failed":
+		return current == "pending" || current == "payment_failed"
+	case "partially_refunded", "disputed":
+		// Cancelled orders are refunded by the cancellation and stay cancelled
+		return current != "cancelled" && current != "refunded"
+	case "refunded":
+		return current != "cancelled"
+	}
+	return true
+}
+
+// applyPaymentEvent records the payment state carried by an event, then moves its order to the matching status.
+// Events that would move the payment or the order back to an earlier status are acknowledged without effect.
+func applyPaymentEvent(ctx context.Context, event *payments.Event, paymentClient grpc.PaymentClient, orderClient grpc.OrderClient) error {
+	transition, ok := paymentTransitions[event.Type]
+	paymentWebhookEvents.WithLabelValues(event.Provider, event.ProviderType, strconv.FormatBool(ok)).Inc()
 	if !ok {
-		// Handle error or log missing metadata
-		utils.Warn("Customer ID not found in metadata", map[string]interface{}{
-			"event": event.ID,
+		utils.WarnContext(ctx, "Unhandled event type", map[string]interface{}{
+			"provider": event.Provider,
+			"event":    event.ProviderType,
 		})
-		return
+		return nil
 	}
 
-	amount, ok := event.Data.Object["amount_total"].(float64)
-	if !ok {
-		// Handle error or log missing amount
-		utils.Warn("Amount not found in event data", map[string]interface{}{
-			"event": event.ID,
+	fields := map[string]interface{}{
+		"provider":       event.Provider,
+		"event":          event.ID,
+		"type":           event.ProviderType,
+		"payment_status": transition.paymentStatus,
+		"amount":         event.Amount,
+		"currency":       event.Currency,
+	}
+	if event.RefundedAmount > 0 {
+		fields["refunded_amount"] = event.RefundedAmount
+	}
+	if event.Reason != "" {
+		fields["reason"] = event.Reason
+	}
+	if event.ReceiptURL != "" {
+		fields["receipt_url"] = event.ReceiptURL
+	}
+	utils.InfoContext(ctx, "Handling payment event", fields)
+
+	current, err := currentPayment(ctx, event, paymentClient)
+	if err != nil {
+		utils.ErrorContext(ctx, "Failed to get payment for payment event", map[string]interface{}{
+			"error":    err,
+			"provider": event.Provider,
+			"event":    event.ID,
+			"order_id": event.OrderID,
 		})
-		return
+		return err
 	}
 
-	status, ok := event.Data.Object["status"].(string)
-	if !ok {
-		// Handle error or log missing status
-		utils.Warn("Status not found in event data", map[string]interface{}{
-			"event": event.ID,
+	// Refunds that do not carry the amount of the payment keep the recorded one, and are full once their
+	// total covers it. The payment is usually recorded by then, so a retry waits for a late capture event.
+	amount := event.Amount
+	if amount == 0 && event.RefundedAmount > 0 {
+		if !current.Success {
+			return fmt.Errorf("payment refunded by event %s is not recorded yet", event.ID)
+		}
+
+		amount = current.Amount
+		if event.RefundedAmount >= current.Amount-amountTolerance {
+			transition = paymentTransitions[payments.PaymentRefunded]
+		}
+	}
+
+	// A late partial refund must not lower the total recorded from a later one either
+	regressed := current.Success && !paymentStatusAllowed(current.Status, transition.paymentStatus)
+	if current.Success && event.RefundedAmount > 0 && event.RefundedAmount < current.RefundedAmount-amountTolerance {
+		regressed = true
+	}
+	if regressed {
+		utils.WarnContext(ctx, "Ignoring out-of-order payment event", map[string]interface{}{
+			"provider":       event.Provider,
+			"event":          event.ID,
+			"type":           event.ProviderType,
+			"payment_id":     current.PaymentId,
+			"payment_status": current.Status,
+			"status":         transition.paymentStatus,
 		})
-		status = "completed" // Default status
+		return nil
 	}
 
-	// Handle checkout session completed
-	_, err := paymentClient.StorePayment(context.Background(), &proto.StorePaymentRequest{
-		TransactionId: event.ID,
-		OrderId:       orderID,
-		CustomerId:    customerID,
-		Amount:        amount / 100,
-		Status:        status,
-	})
+	// The transaction ID recorded at checkout is left unchanged, so that support staff can still look the
+	// payment up by it. The event ID only keys the call.
+	req := &proto.StorePaymentRequest{
+		OrderId:         event.OrderID,
+		CustomerId:      event.CustomerID,
+		Amount:          amount,
+		Currency:        event.Currency,
+		Status:          transition.paymentStatus,
+		PaymentIntentId: event.PaymentReference,
+	}
+	if event.ReceiptURL != "" {
+		req.ReceiptUrl = &event.ReceiptURL
+	}
+	if event.RefundedAmount > 0 {
+		req.RefundedAmount = &event.RefundedAmount
+	}
 
+	resp, err := paymentClient.StorePayment(withEventIdempotencyKey(ctx, event), req)
 	if err != nil {
-		utils.Error("Failed to store completed payment", map[string]interface{}{
-			"error": err,
-			"event": event.ID,
+		utils.ErrorContext(ctx, "Failed to store payment", map[string]interface{}{
+			"error":    err,
+			"provider": event.Provider,
+			"event":    event.ID,
+			"status":   req.Status,
+		})
+		return err
+	}
+
+	if !resp.Success {
+		utils.ErrorContext(ctx, "Failed to store payment", map[string]interface{}{
+			"error":    resp,
+			"provider": event.Provider,
+			"event":    event.ID,
+			"status":   req.Status,
 		})
-		return
+		return fmt.Errorf("failed to store payment: %s", resp.Message)
 	}
+
+	if transition.orderStatus == "" {
+		return nil
+	}
+
+	// payment-svc resolves the order from the payment reference when the event does not carry it
+	orderID := event.OrderID
+	if orderID == "" {
+		orderID = resp.OrderId
+	}
+	return transitionOrder(ctx, event, orderClient, orderID, transition.orderStatus)
 }
 
-func handleCheckoutSessionExpired(event stripe.Event, paymentClient grpc.PaymentClient) {
-	// Handle checkout session expired
-	_, err := paymentClient.StorePayment(context.Background(), &proto.StorePaymentRequest{
-		TransactionId: event.ID,
-		OrderId:       event.Data.Object["client_reference_id"].(string),
-		CustomerId:    event.Data.Object["customer"].(string),
-		Amount:        event.Data.Object["amount_total"].(float64),
-		Status:        "expired",
+// currentPayment looks up the payment that an event updates, by its order or else by the provider's payment
+// reference. The response is unsuccessful for payments that payment-svc has not recorded yet.
+func currentPayment(ctx context.Context, event *payments.Event, paymentClient grpc.PaymentClient) (*proto.GetPaymentResponse, error) {
+	if event.OrderID != "" {
+		return paymentClient.GetPaymentByOrderID(ctx, &proto.GetPaymentByOrderIDRequest{
+			OrderId:    event.OrderID,
+			CustomerId: "admin",
+		})
+	}
+	return paymentClient.GetPaymentByPaymentIntentID(ctx, &proto.GetPaymentByPaymentIntentIDRequest{
+		PaymentIntentId: event.PaymentReference,
+		CustomerId:      "admin",
 	})
+}
+
+// withEventIdempotencyKey marks calls made for an event as retryable, keyed by the event ID
+func withEventIdempotencyKey(ctx context.Context, event *payments.Event) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, grpc.IdempotencyKeyHeader, event.Provider+":"+event.ID)
+}
+
+// transitionOrder moves the order of a payment to status, unless the order has already moved past it
+func transitionOrder(ctx context.Context, event *payments.Event, orderClient grpc.OrderClient, orderID, status string) error {
+	if orderID == "" {
+		utils.WarnContext(ctx, "Order not found for payment event, order status not updated", map[string]interface{}{
+			"provider": event.Provider,
+			"event":    event.ID,
+			"type":     event.ProviderType,
+			"status":   status,
+		})
+		return nil
+	}
 
+	order, err := orderClient.GetOrder(ctx, &proto.GetOrderRequest{
+		OrderId:    orderID,
+		CustomerId: "admin",
+	})
 	if err != nil {
-		utils.Error("Failed to store expired payment", map[string]interface{}{
-			"error": err,
-			"event": event.ID,
+		utils.ErrorContext(ctx, "Failed to get order for payment event", map[string]interface{}{
+			"error":    err,
+			"provider": event.Provider,
+			"event":    event.ID,
+			"order_id": orderID,
+		})
+		return err
+	}
+
+	if order.Success && !orderStatusAllowed(order.Status, status) {
+		utils.WarnContext(ctx, "Order status not updated for out-of-order payment event", map[string]interface{}{
+			"provider":     event.Provider,
+			"event":        event.ID,
+			"type":         event.ProviderType,
+			"order_id":     orderID,
+			"order_status": order.Status,
+			"status":       status,
 		})
-		return
+		return nil
 	}
 
-	utils.Warn("Handling checkout session expired event", map[string]interface{}{
-		"event": event.ID,
+	resp, err := orderClient.UpdateOrderStatus(withEventIdempotencyKey(ctx, event), &proto.UpdateOrderStatusRequest{
+		OrderId:    orderID,
+		CustomerId: "admin",
+		Status:     status,
 	})
+	if err != nil {
+		utils.ErrorContext(ctx, "Failed to update order status for payment event", map[string]interface{}{
+			"error":    err,
+			"provider": event.Provider,
+			"event":    event.ID,
+			"order_id": orderID,
+			"status":   status,
+		})
+		return err
+	}
+
+	// The order may have moved on, e.g. been cancelled, which retrying the event would not change
+	if !resp.Success {
+		utils.WarnContext(ctx, "Order status not updated for payment event", map[string]interface{}{
+			"error":    resp,
+			"provider": event.Provider,
+			"event":    event.ID,
+			"order_id": orderID,
+			"status":   status,
+		})
+	}
+
+	return nil
 }
 
 // GetPayment returns a payment by ID
@@ -238,7 +561,7 @@ func GetPayment(paymentClient grpc.PaymentClient) gin.HandlerFunc {
 		userRole, ok := c.Get("user_role")
 		var customerID string
 		if !ok {
-			c.JSON(http.StatusUnauthorized, utils.ErrorResponse{
+			utils.WriteError(c, http.StatusUnauthorized, utils.ErrorResponse{
 				Type:    "AUTH_ERROR",
 				Message: "User Role not found in token",
 			})
@@ -247,7 +570,7 @@ func GetPayment(paymentClient grpc.PaymentClient) gin.HandlerFunc {
 
 		userId, ok := c.Get("user_id")
 		if !ok {
-			c.JSON(http.StatusUnauthorized, utils.ErrorResponse{
+			utils.WriteError(c, http.StatusUnauthorized, utils.ErrorResponse{
 				Type:    "AUTH_ERROR",
 				Message: "User ID not found in token",
 			})
@@ -266,32 +589,29 @@ func GetPayment(paymentClient grpc.PaymentClient) gin.HandlerFunc {
 			CustomerId: customerID,
 		})
 		if err != nil {
-			utils.Error("Failed to get payment", map[string]interface{}{
+			utils.ErrorContext(c.Request.Context(), "Failed to get payment", map[string]interface{}{
 				"error":      err,
 				"payment_id": paymentID,
 			})
-			c.JSON(http.StatusInternalServerError, utils.ErrorResponse{
-				Type:    "INTERNAL_ERROR",
-				Message: "Failed to get payment",
-				Details: map[string]string{"error": err.Error()},
-			})
+			errorResp, statusCode := utils.ConvertGrpcErrorToResponse(err, "Failed to get payment")
+			utils.WriteError(c, statusCode, errorResp)
 			return
 		}
 
 		if !resp.Success {
-			utils.Error("Failed to get payment", map[string]interface{}{
+			utils.ErrorContext(c.Request.Context(), "Failed to get payment", map[string]interface{}{
 				"error":      resp,
 				"payment_id": paymentID,
 			})
 
 			if resp.Error != nil {
 				errorResp, statusCode := utils.ConvertProtoErrorToResponse(resp.Error)
-				c.JSON(statusCode, errorResp)
+				utils.WriteError(c, statusCode, errorResp)
 				return
 			}
 
 			// Fallback if error structure is not available
-			c.JSON(http.StatusBadRequest, utils.ErrorResponse{
+			utils.WriteError(c, http.StatusBadRequest, utils.ErrorResponse{
 				Type:    "UNKNOWN_ERROR",
 				Message: "Failed to get payment",
 			})
@@ -323,7 +643,7 @@ func GetPaymentByOrderID(paymentClient grpc.PaymentClient) gin.HandlerFunc {
 		userRole, ok := c.Get("user_role")
 		var customerID string
 		if !ok {
-			c.JSON(http.StatusUnauthorized, utils.ErrorResponse{
+			utils.WriteError(c, http.StatusUnauthorized, utils.ErrorResponse{
 				Type:    "AUTH_ERROR",
 				Message: "User Role not found in token",
 			})
@@ -332,7 +652,7 @@ func GetPaymentByOrderID(paymentClient grpc.PaymentClient) gin.HandlerFunc {
 
 		userId, ok := c.Get("user_id")
 		if !ok {
-			c.JSON(http.StatusUnauthorized, utils.ErrorResponse{
+			utils.WriteError(c, http.StatusUnauthorized, utils.ErrorResponse{
 				Type:    "AUTH_ERROR",
 				Message: "User ID not found in token",
 			})
@@ -351,32 +671,29 @@ func GetPaymentByOrderID(paymentClient grpc.PaymentClient) gin.HandlerFunc {
 			CustomerId: customerID,
 		})
 		if err != nil {
-			utils.Error("Failed to get payment by order ID", map[string]interface{}{
+			utils.ErrorContext(c.Request.Context(), "Failed to get payment by order ID", map[string]interface{}{
 				"error":    err,
 				"order_id": orderID,
 			})
-			c.JSON(http.StatusInternalServerError, utils.ErrorResponse{
-				Type:    "INTERNAL_ERROR",
-				Message: "Failed to get payment by order ID",
-				Details: map[string]string{"error": err.Error()},
-			})
+			errorResp, statusCode := utils.ConvertGrpcErrorToResponse(err, "Failed to get payment by order ID")
+			utils.WriteError(c, statusCode, errorResp)
 			return
 		}
 
 		if !resp.Success {
-			utils.Error("Failed to get payment by order ID", map[string]interface{}{
+			utils.ErrorContext(c.Request.Context(), "Failed to get payment by order ID", map[string]interface{}{
 				"error":    resp,
 				"order_id": orderID,
 			})
 
 			if resp.Error != nil {
 				errorResp, statusCode := utils.ConvertProtoErrorToResponse(resp.Error)
-				c.JSON(statusCode, errorResp)
+				utils.WriteError(c, statusCode, errorResp)
 				return
 			}
 
 			// Fallback if error structure is not available
-			c.JSON(http.StatusBadRequest, utils.ErrorResponse{
+			utils.WriteError(c, http.StatusBadRequest, utils.ErrorResponse{
 				Type:    "UNKNOWN_ERROR",
 				Message: "Failed to get payment by order ID",
 			})
@@ -386,3 +703,380 @@ func GetPaymentByOrderID(paymentClient grpc.PaymentClient) gin.HandlerFunc {
 		c.JSON(http.StatusOK, resp)
 	}
 }
+
+type RefundRequest struct {
+	Amount float64 `json:"amount" example:"12.50"`
+	Reason string  `json:"reason" binding:"required" example:"Damaged item"`
+}
+
+// RefundResponse describes an issued refund and the resulting payment and order status
+type RefundResponse struct {
+	PaymentID      string  `json:"payment_id"`
+	RefundID       string  `json:"refund_id,omitempty"`
+	OrderID        string  `json:"order_id"`
+	RefundedAmount float64 `json:"refunded_amount"`
+	PaymentStatus  string  `json:"payment_status"`
+	OrderStatus    string  `json:"order_status,omitempty"`
+	Message        string  `json:"message,omitempty"`
+}
+
+// RefundPayment refunds a payment in full or in part
+// @Summary Refund a payment
+// @Description Refunds a payment in full, or in part when an amount is given, and updates the status of its order
+// @Tags Payments
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer token"
+// @Param Idempotency-Key header string false "Key that makes the refund safe to retry"
+// @Param id path string true "Payment ID"
+// @Param request body RefundRequest true "Refund amount (omit for a full refund) and reason"
+// @Success 200 {object} RefundResponse
+// @Failure 400 {object} utils.ErrorResponse "Bad Request"
+// @Failure 401 {object} utils.ErrorResponse "Unauthorized"
+// @Failure 403 {object} utils.ErrorResponse "Forbidden"
+// @Failure 404 {object} utils.ErrorResponse "Not Found"
+// @Failure 409 {object} utils.ErrorResponse "Already refunded in full"
+// @Failure 500 {object} utils.ErrorResponse "Internal Server Error"
+// @Router /api/v1/admin/payments/{id}/refund [post]
+func RefundPayment(paymentClient grpc.PaymentClient, orderClient grpc.OrderClient) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		paymentID := c.Param("id")
+
+		var req RefundRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			utils.WriteError(c, http.StatusBadRequest, utils.ErrorResponse{
+				Type:    "VALIDATION_ERROR",
+				Message: "Invalid request format",
+				Details: map[string]string{"format": err.Error()},
+			})
+			return
+		}
+
+		if req.Amount < 0 {
+			utils.WriteError(c, http.StatusBadRequest, utils.ErrorResponse{
+				Type:    "VALIDATION_ERROR",
+				Message: "Refund amount cannot be negative",
+				Details: map[string]string{"amount": "must be positive, or omitted for a full refund"},
+			})
+			return
+		}
+
+		payment, err := paymentClient.GetPayment(c.Request.Context(), &proto.GetPaymentRequest{
+			PaymentId:  paymentID,
+			CustomerId: "admin",
+		})
+		if err != nil {
+			utils.ErrorContext(c.Request.Context(), "Failed to get payment for refund", map[string]interface{}{
+				"error":      err,
+				"payment_id": paymentID,
+			})
+			errorResp, statusCode := utils.ConvertGrpcErrorToResponse(err, "Failed to get payment")
+			utils.WriteError(c, statusCode, errorResp)
+			return
+		}
+
+		if !payment.Success {
+			if payment.Error != nil {
+				errorResp, statusCode := utils.ConvertProtoErrorToResponse(payment.Error)
+				utils.WriteError(c, statusCode, errorResp)
+				return
+			}
+
+			utils.WriteError(c, http.StatusBadRequest, utils.ErrorResponse{
+				Type:    "UNKNOWN_ERROR",
+				Message: "Failed to get payment",
+			})
+			return
+		}
+
+		// Earlier partial refunds reduce what can still be refunded
+		balance := refundableBalance(payment)
+		if balance <= 0 {
+			utils.WriteError(c, http.StatusConflict, utils.ErrorResponse{
+				Type:    "CONFLICT_ERROR",
+				Message: "Payment has already been refunded in full",
+				Details: map[string]string{"refunded_amount": strconv.FormatFloat(payment.RefundedAmount, 'f', -1, 64)},
+			})
+			return
+		}
+
+		if req.Amount > balance+amountTolerance {
+			utils.WriteError(c, http.StatusBadRequest, utils.ErrorResponse{
+				Type:    "VALIDATION_ERROR",
+				Message: "Refund amount exceeds the refundable balance",
+				Details: map[string]string{"amount": "must not exceed " + strconv.FormatFloat(balance, 'f', -1, 64)},
+			})
+			return
+		}
+
+		// Forward the client's idempotency key so that the refund can be retried safely
+		ctx := c.Request.Context()
+		if key := c.GetHeader("Idempotency-Key"); key != "" {
+			ctx = metadata.AppendToOutgoingContext(ctx, grpc.IdempotencyKeyHeader, key)
+		}
+
+		resp, err := paymentClient.RefundPayment(ctx, &proto.RefundPaymentRequest{
+			TransactionId: payment.TransactionId,
+			PaymentId:     paymentID,
+			Amount:        req.Amount,
+			Reason:        req.Reason,
+			CustomerId:    "admin",
+		})
+		if err != nil {
+			utils.ErrorContext(c.Request.Context(), "Failed to refund payment", map[string]interface{}{
+				"error":      err,
+				"payment_id": paymentID,
+			})
+			errorResp, statusCode := utils.ConvertGrpcErrorToResponse(err, "Failed to refund payment")
+			utils.WriteError(c, statusCode, errorResp)
+			return
+		}
+
+		if !resp.Success {
+			utils.ErrorContext(c.Request.Context(), "Failed to refund payment", map[string]interface{}{
+				"error":      resp,
+				"payment_id": paymentID,
+			})
+
+			if resp.Error != nil {
+				errorResp, statusCode := utils.ConvertProtoErrorToResponse(resp.Error)
+				utils.WriteError(c, statusCode, errorResp)
+				return
+			}
+
+			utils.WriteError(c, http.StatusBadRequest, utils.ErrorResponse{
+				Type:    "UNKNOWN_ERROR",
+				Message: "Failed to refund payment",
+			})
+			return
+		}
+
+		utils.InfoContext(c.Request.Context(), "Refunded payment", map[string]interface{}{
+			"payment_id":      paymentID,
+			"order_id":        payment.OrderId,
+			"refunded_amount": resp.RefundedAmount,
+			"status":          resp.Status,
+		})
+
+		result := RefundResponse{
+			PaymentID:      paymentID,
+			RefundID:       resp.RefundId,
+			OrderID:        payment.OrderId,
+			RefundedAmount: resp.RefundedAmount,
+			PaymentStatus:  resp.Status,
+			Message:        resp.Message,
+		}
+
+		// The money has already been returned, so a failed order update is reported rather than failing the request
+		orderStatus := "partially_refunded"
+		if resp.Status == "refunded" {
+			orderStatus = "refunded"
+		}
+
+		orderResp, err := orderClient.UpdateOrderStatus(c.Request.Context(), &proto.UpdateOrderStatusRequest{
+			OrderId:    payment.OrderId,
+			CustomerId: "admin",
+			Status:     orderStatus,
+		})
+		if err != nil || !orderResp.Success {
+			utils.ErrorContext(c.Request.Context(), "Failed to update order status after refund", map[string]interface{}{
+				"error":      err,
+				"response":   orderResp,
+				"order_id":   payment.OrderId,
+				"payment_id": paymentID,
+			})
+			result.Message = "Payment refunded, but the order status could not be updated"
+		} else {
+			result.OrderStatus = orderStatus
+		}
+
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+// amountTolerance absorbs the float rounding of amounts, which is far below the smallest currency unit
+const amountTolerance = 1e-9
+
+// refundableBalance returns the part of a payment that has not been refunded yet
+func refundableBalance(payment *proto.GetPaymentResponse) float64 {
+	balance := payment.Amount - payment.RefundedAmount
+	if balance < amountTolerance {
+		return 0
+	}
+	return balance
+}
+
+// GetPaymentByTransactionID returns a payment by its Stripe transaction ID
+// @Summary Get a payment by transaction ID
+// @Description Retrieves a payment by the Stripe transaction ID shown in the Stripe dashboard
+// @Tags Payments
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer token"
+// @Param id path string true "Stripe transaction ID"
+// @Success 200 {object} proto.GetPaymentResponse
+// @Failure 400 {object} utils.ErrorResponse "Bad Request"
+// @Failure 401 {object} utils.ErrorResponse "Unauthorized"
+// @Failure 403 {object} utils.ErrorResponse "Forbidden"
+// @Failure 404 {object} utils.ErrorResponse "Not Found"
+// @Failure 500 {object} utils.ErrorResponse "Internal Server Error"
+// @Router /api/v1/admin/payments/transaction/{id} [get]
+func GetPaymentByTransactionID(paymentClient grpc.PaymentClient) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		transactionID := c.Param("id")
+
+		resp, err := paymentClient.GetPaymentByTransactionID(c.Request.Context(), &proto.GetPaymentByTransactionIDRequest{
+			TransactionId: transactionID,
+			CustomerId:    "admin",
+		})
+		if err != nil {
+			utils.ErrorContext(c.Request.Context(), "Failed to get payment by transaction ID", map[string]interface{}{
+				"error":          err,
+				"transaction_id": transactionID,
+			})
+			errorResp, statusCode := utils.ConvertGrpcErrorToResponse(err, "Failed to get payment")
+			utils.WriteError(c, statusCode, errorResp)
+			return
+		}
+
+		if !resp.Success {
+			utils.ErrorContext(c.Request.Context(), "Failed to get payment by transaction ID", map[string]interface{}{
+				"error":          resp,
+				"transaction_id": transactionID,
+			})
+
+			if resp.Error != nil {
+				errorResp, statusCode := utils.ConvertProtoErrorToResponse(resp.Error)
+				utils.WriteError(c, statusCode, errorResp)
+				return
+			}
+
+			// Fallback if error structure is not available
+			utils.WriteError(c, http.StatusBadRequest, utils.ErrorResponse{
+				Type:    "UNKNOWN_ERROR",
+				Message: "Failed to get payment",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, resp)
+	}
+}
+
+// ListPayments searches payments
+// @Summary Search payments
+// @Description Searches payments by status, customer and creation date range
+// @Tags Payments
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer token"
+// @Param status query string false "Payment status"
+// @Param customer_id query string false "Customer ID"
+// @Param from query string false "Created on or after (RFC 3339 or YYYY-MM-DD)"
+// @Param to query string false "Created before, or on a YYYY-MM-DD day (RFC 3339 or YYYY-MM-DD)"
+// @Param page query int false "Page number"
+// @Param limit query int false "Page limit"
+// @Param sort_by query string false "Sort by field"
+// @Param sort_order query string false "Sort order (asc/desc)"
+// @Success 200 {object} proto.ListPaymentsResponse
+// @Failure 400 {object} utils.ErrorResponse "Bad Request"
+// @Failure 401 {object} utils.ErrorResponse "Unauthorized"
+// @Failure 403 {object} utils.ErrorResponse "Forbidden"
+// @Failure 500 {object} utils.ErrorResponse "Internal Server Error"
+// @Router /api/v1/admin/payments [get]
+func ListPayments(paymentClient grpc.PaymentClient) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		from, err := parseDateParam(c.Query("from"), false)
+		if err != nil {
+			utils.WriteError(c, http.StatusBadRequest, utils.ErrorResponse{
+				Type:    "VALIDATION_ERROR",
+				Message: "Invalid from date",
+				Details: map[string]string{"from": "must be an RFC 3339 timestamp or a YYYY-MM-DD date"},
+			})
+			return
+		}
+
+		to, err := parseDateParam(c.Query("to"), true)
+		if err != nil {
+			utils.WriteError(c, http.StatusBadRequest, utils.ErrorResponse{
+				Type:    "VALIDATION_ERROR",
+				Message: "Invalid to date",
+				Details: map[string]string{"to": "must be an RFC 3339 timestamp or a YYYY-MM-DD date"},
+			})
+			return
+		}
+
+		if from != 0 && to != 0 && from >= to {
+			utils.WriteError(c, http.StatusBadRequest, utils.ErrorResponse{
+				Type:    "VALIDATION_ERROR",
+				Message: "Invalid date range",
+				Details: map[string]string{"from": "must be before to"},
+			})
+			return
+		}
+
+		resp, err := paymentClient.ListPayments(c.Request.Context(), &proto.ListPaymentsRequest{
+			Status:      c.Query("status"),
+			CustomerId:  c.Query("customer_id"),
+			CreatedFrom: from,
+			CreatedTo:   to,
+			SortBy:      c.Query("sort_by"),
+			SortOrder:   c.Query("sort_order"),
+			Page:        int32(utils.GetIntQueryParam(c, "page", 1)),
+			Limit:       int32(utils.GetIntQueryParam(c, "limit", 0)),
+		})
+		if err != nil {
+			utils.ErrorContext(c.Request.Context(), "Failed to list payments", map[string]interface{}{
+				"error": err,
+			})
+			errorResp, statusCode := utils.ConvertGrpcErrorToResponse(err, "Failed to list payments")
+			utils.WriteError(c, statusCode, errorResp)
+			return
+		}
+
+		if !resp.Success {
+			utils.ErrorContext(c.Request.Context(), "Failed to list payments", map[string]interface{}{
+				"error": resp,
+			})
+
+			if resp.Error != nil {
+				errorResp, statusCode := utils.ConvertProtoErrorToResponse(resp.Error)
+				utils.WriteError(c, statusCode, errorResp)
+				return
+			}
+
+			// Fallback if error structure is not available
+			utils.WriteError(c, http.StatusBadRequest, utils.ErrorResponse{
+				Type:    "UNKNOWN_ERROR",
+				Message: "Failed to list payments",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, resp)
+	}
+}
+
+// parseDateParam converts an RFC 3339 timestamp or a YYYY-MM-DD date to unix seconds. A date used as
+// an upper bound covers the whole day. An empty value means no bound.
+func parseDateParam(value string, upperBound bool) (int64, error) {
+	if value == "" {
+		return 0, nil
+	}
+
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.Unix(), nil
+	}
+
+	day, err := time.Parse(time.DateOnly, value)
+	if err != nil {
+		return 0, err
+	}
+	if upperBound {
+		day = day.AddDate(0, 0, 1)
+	}
+	return day.Unix(), nil
+}
@@ -0,0 +1,746 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/PharmaKart/gateway-svc/internal/eventstore"
+	"github.com/PharmaKart/gateway-svc/internal/grpc"
+	"github.com/PharmaKart/gateway-svc/internal/payments"
+	"github.com/PharmaKart/gateway-svc/internal/proto"
+	"github.com/PharmaKart/gateway-svc/internal/webhookqueue"
+	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc/metadata"
+)
+
+// fakePaymentClient serves payments from memory and records the refunds requested
+type fakePaymentClient struct {
+	grpc.PaymentClient
+
+	mu      sync.Mutex
+	payment *proto.GetPaymentResponse
+	// intentID is the provider's payment reference of payment
+	intentID string
+	// lookupDelay slows payment lookups down, so that concurrent events overlap unless they are serialized
+	lookupDelay time.Duration
+	// applying counts the events between their payment lookup and the update, and overlaps the events
+	// that started while another one was applied
+	applying int
+	overlaps int
+	refund   func(req *proto.RefundPaymentRequest) (*proto.RefundPaymentResponse, error)
+	refunds  []*proto.RefundPaymentRequest
+	keys     []string
+	lookups  []string
+	search   *proto.ListPaymentsRequest
+	stored   []string
+}
+
+func (f *fakePaymentClient) GetPayment(ctx context.Context, req *proto.GetPaymentRequest) (*proto.GetPaymentResponse, error) {
+	return f.payment, nil
+}
+
+func (f *fakePaymentClient) GetPaymentByOrderID(ctx context.Context, req *proto.GetPaymentByOrderIDRequest) (*proto.GetPaymentResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.payment == nil {
+		return &proto.GetPaymentResponse{Success: false}, nil
+	}
+	return f.payment, nil
+}
+
+// StorePayment records the status of the payment of order-1, which payment-svc resolves for every event
+func (f *fakePaymentClient) StorePayment(ctx context.Context, req *proto.StorePaymentRequest) (*proto.StorePaymentResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.applying > 0 {
+		f.applying--
+	}
+
+	payment := &proto.GetPaymentResponse{Success: true, PaymentId: "pay-1", OrderId: "order-1", TransactionId: req.TransactionId, Amount: req.Amount, Status: req.Status}
+	if req.TransactionId == "" && f.payment != nil {
+		payment.TransactionId = f.payment.TransactionId
+	}
+	if req.RefundedAmount != nil {
+		payment.RefundedAmount = *req.RefundedAmount
+	} else if f.payment != nil {
+		payment.RefundedAmount = f.payment.RefundedAmount
+	}
+	f.payment = payment
+	if req.PaymentIntentId != "" {
+		f.intentID = req.PaymentIntentId
+	}
+	f.stored = append(f.stored, req.Status)
+	return &proto.StorePaymentResponse{Success: true, OrderId: "order-1"}, nil
+}
+
+func (f *fakePaymentClient) paymentStatus() string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.payment == nil {
+		return ""
+	}
+	return f.payment.Status
+}
+
+func (f *fakePaymentClient) GetPaymentByTransactionID(ctx context.Context, req *proto.GetPaymentByTransactionIDRequest) (*proto.GetPaymentResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.lookups = append(f.lookups, req.TransactionId+"/"+req.CustomerId)
+	if f.payment == nil {
+		return &proto.GetPaymentResponse{Success: false}, nil
+	}
+	return f.payment, nil
+}
+
+// GetPaymentByPaymentIntentID returns the payment if it was recorded with the requested payment reference
+func (f *fakePaymentClient) GetPaymentByPaymentIntentID(ctx context.Context, req *proto.GetPaymentByPaymentIntentIDRequest) (*proto.GetPaymentResponse, error) {
+	f.mu.Lock()
+	if f.applying > 0 {
+		f.overlaps++
+	}
+	f.applying++
+	f.lookups = append(f.lookups, req.PaymentIntentId+"/"+req.CustomerId)
+	payment, intentID, delay := f.payment, f.intentID, f.lookupDelay
+	f.mu.Unlock()
+
+	time.Sleep(delay)
+	if payment == nil || intentID != req.PaymentIntentId {
+		return &proto.GetPaymentResponse{Success: false}, nil
+	}
+	return payment, nil
+}
+
+func (f *fakePaymentClient) ListPayments(ctx context.Context, req *proto.ListPaymentsRequest) (*proto.ListPaymentsResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.search = req
+	return &proto.ListPaymentsResponse{Success: true}, nil
+}
+
+func (f *fakePaymentClient) RefundPayment(ctx context.Context, req *proto.RefundPaymentRequest) (*proto.RefundPaymentResponse, error) {
+	md, _ := metadata.FromOutgoingContext(ctx)
+
+	f.mu.Lock()
+	f.refunds = append(f.refunds, req)
+	f.keys = append(f.keys, strings.Join(md.Get(grpc.IdempotencyKeyHeader), ","))
+	refund := f.refund
+	f.mu.Unlock()
+
+	if refund != nil {
+		return refund(req)
+	}
+	return &proto.RefundPaymentResponse{Success: true, RefundId: "re_1", RefundedAmount: req.Amount, Status: "refunded"}, nil
+}
+
+func (f *fakePaymentClient) refundCalls() ([]*proto.RefundPaymentRequest, []string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return append([]*proto.RefundPaymentRequest(nil), f.refunds...), append([]string(nil), f.keys...)
+}
+
+// fakeOrderClient serves one order from memory and records the status updates
+type fakeOrderClient struct {
+	grpc.OrderClient
+
+	mu      sync.Mutex
+	status  string
+	updates []string
+}
+
+func (f *fakeOrderClient) GetOrder(ctx context.Context, req *proto.GetOrderRequest) (*proto.GetOrderResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return &proto.GetOrderResponse{Success: true, OrderId: req.OrderId, CustomerId: req.CustomerId, Status: f.status}, nil
+}
+
+func (f *fakeOrderClient) UpdateOrderStatus(ctx context.Context, req *proto.UpdateOrderStatusRequest) (*proto.UpdateOrderStatusResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.status = req.Status
+	f.updates = append(f.updates, req.Status)
+	return &proto.UpdateOrderStatusResponse{Success: true}, nil
+}
+
+func (f *fakeOrderClient) statusUpdates() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return append([]string(nil), f.updates...)
+}
+
+func TestRefundPaymentChecksRefundableBalance(t *testing.T) {
+	tests := []struct {
+		name       string
+		amount     float64
+		refunded   float64
+		request    string
+		wantStatus int
+		wantAmount float64
+	}{
+		{"partial refund", 100, 0, `{"amount": 40, "reason": "damaged"}`, http.StatusOK, 40},
+		{"remaining balance", 100, 60, `{"amount": 40, "reason": "damaged"}`, http.StatusOK, 40},
+		{"balance left by float rounding", 0.3, 0.1, `{"amount": 0.2, "reason": "damaged"}`, http.StatusOK, 0.2},
+		{"full refund of the balance", 100, 60, `{"reason": "damaged"}`, http.StatusOK, 0},
+		{"more than the balance", 100, 60, `{"amount": 50, "reason": "damaged"}`, http.StatusBadRequest, -1},
+		{"more than the payment", 100, 0, `{"amount": 150, "reason": "damaged"}`, http.StatusBadRequest, -1},
+		{"already refunded in full", 100, 100, `{"reason": "damaged"}`, http.StatusConflict, -1},
+		{"negative amount", 100, 0, `{"amount": -1, "reason": "damaged"}`, http.StatusBadRequest, -1},
+		{"without reason", 100, 0, `{"amount": 10}`, http.StatusBadRequest, -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payments := &fakePaymentClient{payment: &proto.GetPaymentResponse{
+				Success:        true,
+				PaymentId:      "pay-1",
+				TransactionId:  "pi_1",
+				OrderId:        "order-1",
+				Amount:         tt.amount,
+				RefundedAmount: tt.refunded,
+				Status:         "partially_refunded",
+			}}
+			orders := &fakeOrderClient{status: "paid"}
+
+			r := gin.New()
+			r.POST("/payments/:id/refund", RefundPayment(payments, orders))
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/payments/pay-1/refund", strings.NewReader(tt.request)))
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+
+			refunds, _ := payments.refundCalls()
+			if tt.wantAmount < 0 {
+				if len(refunds) != 0 {
+					t.Fatalf("issued %d refunds, want none", len(refunds))
+				}
+				return
+			}
+			if len(refunds) != 1 || refunds[0].Amount != tt.wantAmount {
+				t.Fatalf("refunds = %v, want one of %v", refunds, tt.wantAmount)
+			}
+		})
+	}
+}
+
+func TestRefundPaymentReportsOrderStatus(t *testing.T) {
+	payments := &fakePaymentClient{payment: &proto.GetPaymentResponse{Success: true, PaymentId: "pay-1", OrderId: "order-1", Amount: 100, Status: "completed"}}
+	payments.refund = func(req *proto.RefundPaymentRequest) (*proto.RefundPaymentResponse, error) {
+		return &proto.RefundPaymentResponse{Success: true, RefundId: "re_1", RefundedAmount: 40, Status: "partially_refunded"}, nil
+	}
+	orders := &fakeOrderClient{status: "paid"}
+
+	r := gin.New()
+	r.POST("/payments/:id/refund", RefundPayment(payments, orders))
+
+	req := httptest.NewRequest(http.MethodPost, "/payments/pay-1/refund", strings.NewReader(`{"amount": 40, "reason": "damaged"}`))
+	req.Header.Set("Idempotency-Key", "refund-key")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	var resp RefundResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if resp.OrderStatus != "partially_refunded" || resp.RefundedAmount != 40 {
+		t.Fatalf("response = %+v, want a partially refunded order", resp)
+	}
+	if _, keys := payments.refundCalls(); len(keys) != 1 || keys[0] != "refund-key" {
+		t.Fatalf("idempotency keys = %q, want the client's key", keys)
+	}
+}
+
+func TestGetPaymentByTransactionID(t *testing.T) {
+	tests := []struct {
+		name       string
+		payment    *proto.GetPaymentResponse
+		wantStatus int
+	}{
+		{
+			name:       "found",
+			payment:    paidPayment(),
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "not found",
+			payment:    &proto.GetPaymentResponse{Error: &proto.Error{Type: "NOT_FOUND_ERROR", Message: "payment not found"}},
+			wantStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payments := &fakePaymentClient{payment: tt.payment}
+
+			r := gin.New()
+			r.GET("/payments/transaction/:id", GetPaymentByTransactionID(payments))
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/payments/transaction/cs_1", nil))
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if len(payments.lookups) != 1 || payments.lookups[0] != "cs_1/admin" {
+				t.Fatalf("lookups = %q, want cs_1 looked up as admin", payments.lookups)
+			}
+		})
+	}
+}
+
+func TestListPayments(t *testing.T) {
+	day := func(date string) int64 {
+		parsed, _ := time.Parse(time.DateOnly, date)
+		return parsed.Unix()
+	}
+
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int
+		wantFrom   int64
+		wantTo     int64
+	}{
+		{
+			name:       "without range",
+			query:      "status=refunded&customer_id=customer-1",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "days",
+			query:      "from=2024-03-01&to=2024-03-31",
+			wantStatus: http.StatusOK,
+			wantFrom:   day("2024-03-01"),
+			wantTo:     day("2024-04-01"),
+		},
+		{
+			name:       "timestamps",
+			query:      "from=2024-03-01T10:00:00Z&to=2024-03-01T12:00:00%2B01:00",
+			wantStatus: http.StatusOK,
+			wantFrom:   day("2024-03-01") + 10*3600,
+			wantTo:     day("2024-03-01") + 11*3600,
+		},
+		{
+			name:       "same day",
+			query:      "from=2024-03-01&to=2024-03-01",
+			wantStatus: http.StatusOK,
+			wantFrom:   day("2024-03-01"),
+			wantTo:     day("2024-03-02"),
+		},
+		{
+			name:       "invalid from",
+			query:      "from=yesterday",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "invalid to",
+			query:      "to=03/01/2024",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "reversed range",
+			query:      "from=2024-03-02T00:00:00Z&to=2024-03-01T00:00:00Z",
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payments := &fakePaymentClient{}
+
+			r := gin.New()
+			r.GET("/payments", ListPayments(payments))
+
+			req := httptest.NewRequest(http.MethodGet, "/payments?"+tt.query, nil)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if tt.wantStatus != http.StatusOK {
+				if payments.search != nil {
+					t.Fatal("payments were searched with an invalid query")
+				}
+				return
+			}
+
+			if payments.search.CreatedFrom != tt.wantFrom || payments.search.CreatedTo != tt.wantTo {
+				t.Fatalf("range = [%d, %d), want [%d, %d)", payments.search.CreatedFrom, payments.search.CreatedTo, tt.wantFrom, tt.wantTo)
+			}
+			if query := req.URL.Query(); payments.search.Status != query.Get("status") || payments.search.CustomerId != query.Get("customer_id") {
+				t.Fatalf("search = %+v, want the status and customer filters of %q", payments.search, tt.query)
+			}
+		})
+	}
+}
+
+func TestApplyPaymentEventIgnoresRegressions(t *testing.T) {
+	tests := []struct {
+		name        string
+		orderStatus string
+		events      []payments.EventType
+		// withoutOrder events only carry the payment reference
+		withoutOrder bool
+		wantPayment  string
+		wantOrder    string
+	}{
+		{
+			name:        "failure after payment",
+			events:      []payments.EventType{payments.PaymentCompleted, payments.PaymentFailed},
+			wantPayment: "completed",
+			wantOrder:   "paid",
+		},
+		{
+			name:        "retry after failure",
+			events:      []payments.EventType{payments.PaymentFailed, payments.PaymentCompleted},
+			wantPayment: "completed",
+			wantOrder:   "paid",
+		},
+		{
+			name:        "capture before completion",
+			events:      []payments.EventType{payments.PaymentCaptured, payments.PaymentCompleted},
+			wantPayment: "completed",
+			wantOrder:   "paid",
+		},
+		{
+			name:        "capture after refund",
+			events:      []payments.EventType{payments.PaymentCompleted, payments.PaymentRefunded, payments.PaymentCaptured},
+			wantPayment: "refunded",
+			wantOrder:   "refunded",
+		},
+		{
+			name:        "completion after refund",
+			events:      []payments.EventType{payments.PaymentRefunded, payments.PaymentCompleted},
+			wantPayment: "refunded",
+			wantOrder:   "refunded",
+		},
+		{
+			name:        "partial refund after full refund",
+			events:      []payments.EventType{payments.PaymentCompleted, payments.PaymentRefunded, payments.PaymentPartiallyRefunded},
+			wantPayment: "refunded",
+			wantOrder:   "refunded",
+		},
+		{
+			name:        "full refund after partial refund",
+			events:      []payments.EventType{payments.PaymentCompleted, payments.PaymentPartiallyRefunded, payments.PaymentRefunded},
+			wantPayment: "refunded",
+			wantOrder:   "refunded",
+		},
+		{
+			name:        "refund of a cancelled order",
+			orderStatus: "cancelled",
+			events:      []payments.EventType{payments.PaymentRefunded},
+			wantPayment: "refunded",
+			wantOrder:   "cancelled",
+		},
+		{
+			name:        "dispute of a cancelled order",
+			orderStatus: "cancelled",
+			events:      []payments.EventType{payments.PaymentDisputed},
+			wantPayment: "disputed",
+			wantOrder:   "cancelled",
+		},
+		{
+			name:         "failure after refund found by payment reference",
+			events:       []payments.EventType{payments.PaymentCompleted, payments.PaymentRefunded, payments.PaymentFailed},
+			withoutOrder: true,
+			wantPayment:  "refunded",
+			wantOrder:    "refunded",
+		},
+		{
+			name:         "failure of a paid order recorded by another payment",
+			orderStatus:  "paid",
+			events:       []payments.EventType{payments.PaymentFailed},
+			withoutOrder: true,
+			wantPayment:  "failed",
+			wantOrder:    "paid",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			orderStatus := tt.orderStatus
+			if orderStatus == "" {
+				orderStatus = "pending"
+			}
+			orders := &fakeOrderClient{status: orderStatus}
+			paymentClient := &fakePaymentClient{}
+
+			for i, eventType := range tt.events {
+				event := &payments.Event{
+					Provider:         "stripe",
+					ID:               fmt.Sprintf("evt_%d", i),
+					Type:             eventType,
+					OrderID:          "order-1",
+					PaymentReference: "pi_1",
+					Amount:           100,
+					Currency:         "usd",
+				}
+				if tt.withoutOrder {
+					event.OrderID = ""
+				}
+				if err := applyPaymentEvent(context.Background(), event, paymentClient, orders); err != nil {
+					t.Fatalf("apply %s: %v", eventType, err)
+				}
+			}
+
+			if got := paymentClient.paymentStatus(); got != tt.wantPayment {
+				t.Errorf("payment status = %q, want %q", got, tt.wantPayment)
+			}
+			orders.mu.Lock()
+			defer orders.mu.Unlock()
+			if orders.status != tt.wantOrder {
+				t.Errorf("order status = %q, want %q (updates %v)", orders.status, tt.wantOrder, orders.updates)
+			}
+		})
+	}
+}
+
+func TestApplyPaymentEventKeepsTransactionID(t *testing.T) {
+	orders := &fakeOrderClient{status: "pending"}
+	paymentClient := &fakePaymentClient{payment: &proto.GetPaymentResponse{
+		Success: true, PaymentId: "pay-1", OrderId: "order-1", TransactionId: "cs_1", Amount: 100, Status: "pending",
+	}}
+
+	for i, eventType := range []payments.EventType{payments.PaymentCompleted, payments.PaymentPartiallyRefunded} {
+		event := &payments.Event{
+			Provider:         "stripe",
+			ID:               fmt.Sprintf("evt_%d", i),
+			Type:             eventType,
+			OrderID:          "order-1",
+			PaymentReference: "pi_1",
+			Amount:           100,
+			Currency:         "usd",
+		}
+		if err := applyPaymentEvent(context.Background(), event, paymentClient, orders); err != nil {
+			t.Fatalf("apply %s: %v", eventType, err)
+		}
+	}
+
+	// Support staff look payments up by the transaction ID shown in the Stripe dashboard
+	if got := paymentClient.payment.TransactionId; got != "cs_1" {
+		t.Fatalf("transaction ID = %q, want cs_1", got)
+	}
+}
+
+func TestApplyPaymentEventRefundTotals(t *testing.T) {
+	// refund is a PayPal refund, which carries the total refunded but not the amount of the payment
+	refund := func(id string, total float64) *payments.Event {
+		return &payments.Event{
+			Provider:         "paypal",
+			ID:               id,
+			Type:             payments.PaymentPartiallyRefunded,
+			PaymentReference: "42311647XV020574X",
+			RefundedAmount:   total,
+			Currency:         "cad",
+		}
+	}
+
+	tests := []struct {
+		name         string
+		refunds      []*payments.Event
+		wantPayment  string
+		wantRefunded float64
+		wantOrder    string
+	}{
+		{
+			name:         "partial refund",
+			refunds:      []*payments.Event{refund("WH-1", 19.99)},
+			wantPayment:  "partially_refunded",
+			wantRefunded: 19.99,
+			wantOrder:    "partially_refunded",
+		},
+		{
+			name:         "refunds adding up to the payment",
+			refunds:      []*payments.Event{refund("WH-1", 19.99), refund("WH-2", 50.97)},
+			wantPayment:  "refunded",
+			wantRefunded: 50.97,
+			wantOrder:    "refunded",
+		},
+		{
+			name:         "single full refund",
+			refunds:      []*payments.Event{refund("WH-1", 50.97)},
+			wantPayment:  "refunded",
+			wantRefunded: 50.97,
+			wantOrder:    "refunded",
+		},
+		{
+			name:         "earlier refund delivered late",
+			refunds:      []*payments.Event{refund("WH-2", 30), refund("WH-1", 10)},
+			wantPayment:  "partially_refunded",
+			wantRefunded: 30,
+			wantOrder:    "partially_refunded",
+		},
+		{
+			name:         "partial refund delivered after the full refund",
+			refunds:      []*payments.Event{refund("WH-2", 50.97), refund("WH-1", 19.99)},
+			wantPayment:  "refunded",
+			wantRefunded: 50.97,
+			wantOrder:    "refunded",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			orders := &fakeOrderClient{status: "paid"}
+			paymentClient := &fakePaymentClient{payment: &proto.GetPaymentResponse{
+				Success: true, PaymentId: "pay-1", OrderId: "order-1", Amount: 50.97, Status: "completed",
+			}, intentID: "42311647XV020574X"}
+
+			for _, event := range tt.refunds {
+				if err := applyPaymentEvent(context.Background(), event, paymentClient, orders); err != nil {
+					t.Fatalf("apply %s: %v", event.ID, err)
+				}
+			}
+
+			paymentClient.mu.Lock()
+			payment := paymentClient.payment
+			paymentClient.mu.Unlock()
+			if payment.Status != tt.wantPayment || payment.RefundedAmount != tt.wantRefunded || payment.Amount != 50.97 {
+				t.Errorf("payment = %s, %v refunded of %v, want %s, %v refunded of 50.97", payment.Status, payment.RefundedAmount, payment.Amount, tt.wantPayment, tt.wantRefunded)
+			}
+			orders.mu.Lock()
+			defer orders.mu.Unlock()
+			if orders.status != tt.wantOrder {
+				t.Errorf("order status = %q, want %q", orders.status, tt.wantOrder)
+			}
+		})
+	}
+}
+
+func TestApplyPaymentEventRefundOfUnknownPayment(t *testing.T) {
+	paymentClient := &fakePaymentClient{}
+	event := &payments.Event{
+		Provider:         "paypal",
+		ID:               "WH-1",
+		Type:             payments.PaymentPartiallyRefunded,
+		PaymentReference: "42311647XV020574X",
+		RefundedAmount:   10,
+		Currency:         "usd",
+	}
+
+	// The refund is retried until the capture that it returns has been recorded
+	if err := applyPaymentEvent(context.Background(), event, paymentClient, &fakeOrderClient{}); err == nil {
+		t.Fatal("applied a refund without knowing the amount of its payment")
+	}
+	if len(paymentClient.stored) != 0 {
+		t.Fatalf("stored %v, want nothing", paymentClient.stored)
+	}
+}
+
+// fakeProvider parses payloads holding normalized events as JSON
+type fakeProvider struct{}
+
+func (fakeProvider) Name() string {
+	return "stripe"
+}
+
+func (fakeProvider) VerifyWebhook(ctx context.Context, payload []byte, header http.Header) (payments.Delivery, error) {
+	return payments.Delivery{}, nil
+}
+
+func (fakeProvider) ParseEvent(payload []byte) (*payments.Event, error) {
+	var event payments.Event
+	return &event, json.Unmarshal(payload, &event)
+}
+
+// queuedEvent wraps event as it would be queued by the webhook handler
+func queuedEvent(t *testing.T, event payments.Event) webhookqueue.Event {
+	t.Helper()
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		t.Fatalf("marshal event: %v", err)
+	}
+	return webhookqueue.Event{ID: event.ID, Source: "stripe", Type: event.ProviderType, Payload: payload}
+}
+
+func TestProcessPaymentEventWithoutOrder(t *testing.T) {
+	store := eventstore.NewMemoryStore()
+	orders := &fakeOrderClient{status: "paid"}
+	paymentClient := &fakePaymentClient{payment: &proto.GetPaymentResponse{
+		Success: true, PaymentId: "pay-1", OrderId: "order-1", TransactionId: "cs_1", Amount: 100, Status: "completed",
+	}, intentID: "pi_1"}
+	handle := ProcessPaymentEvent(payments.Providers{"stripe": fakeProvider{}}, paymentClient, orders,
+		eventstore.NewDeduplicator(store, time.Minute, time.Hour), eventstore.NewLocker(store, time.Minute))
+
+	// Disputes and refunds only carry the payment intent
+	event := payments.Event{
+		Provider:         "stripe",
+		ID:               "evt_1",
+		ProviderType:     "charge.refunded",
+		Type:             payments.PaymentRefunded,
+		PaymentReference: "pi_1",
+		Amount:           100,
+		Currency:         "usd",
+	}
+	if err := handle(context.Background(), queuedEvent(t, event)); err != nil {
+		t.Fatalf("handle: %v", err)
+	}
+
+	if len(paymentClient.lookups) != 1 || paymentClient.lookups[0] != "pi_1/admin" {
+		t.Fatalf("lookups = %q, want pi_1 looked up as admin", paymentClient.lookups)
+	}
+	if got := paymentClient.paymentStatus(); got != "refunded" {
+		t.Errorf("payment status = %q, want refunded", got)
+	}
+	if got := orders.statusUpdates(); len(got) != 1 || got[0] != "refunded" {
+		t.Errorf("order updates = %v, want the order payment-svc resolved refunded", got)
+	}
+}
+
+func TestProcessPaymentEventSerializesEventsOfAPayment(t *testing.T) {
+	store := eventstore.NewMemoryStore()
+	paymentClient := &fakePaymentClient{lookupDelay: 20 * time.Millisecond}
+	handle := ProcessPaymentEvent(payments.Providers{"stripe": fakeProvider{}}, paymentClient, &fakeOrderClient{status: "pending"},
+		eventstore.NewDeduplicator(store, time.Minute, time.Hour), eventstore.NewLocker(store, time.Minute))
+
+	// Queue workers apply a failed attempt and the completion of the same payment at the same time
+	var wg sync.WaitGroup
+	for i, eventType := range []payments.EventType{payments.PaymentFailed, payments.PaymentCompleted, payments.PaymentFailed} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			event := payments.Event{
+				Provider:         "stripe",
+				ID:               fmt.Sprintf("evt_%d", i),
+				Type:             eventType,
+				PaymentReference: "pi_1",
+				Amount:           100,
+				Currency:         "usd",
+			}
+			if err := handle(context.Background(), queuedEvent(t, event)); err != nil {
+				t.Errorf("handle %s: %v", eventType, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	paymentClient.mu.Lock()
+	defer paymentClient.mu.Unlock()
+	if paymentClient.overlaps != 0 {
+		t.Fatalf("%d events were applied while another event of the payment was", paymentClient.overlaps)
+	}
+	if paymentClient.payment.Status != "completed" {
+		t.Fatalf("payment status = %q, want completed whatever order the events were applied in", paymentClient.payment.Status)
+	}
+}
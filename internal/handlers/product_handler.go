@@ -1,7 +1,6 @@
 package handlers
 
 import (
-	"context"
 	"mime/multipart"
 	"net/http"
 	"path/filepath"
@@ -64,10 +63,10 @@ func CreateProduct(cfg *config.Config, productClient grpc.ProductClient) gin.Han
 	return func(c *gin.Context) {
 		var req Product
 		if err := c.ShouldBind(&req); err != nil {
-			utils.Error("Failed to bind request", map[string]interface{}{
+			utils.ErrorContext(c.Request.Context(), "Failed to bind request", map[string]interface{}{
 				"error": err,
 			})
-			c.JSON(http.StatusBadRequest, utils.ErrorResponse{
+			utils.WriteError(c, http.StatusBadRequest, utils.ErrorResponse{
 				Type:    "VALIDATION_ERROR",
 				Message: "Invalid request format",
 				Details: map[string]string{"format": err.Error()},
@@ -82,10 +81,10 @@ func CreateProduct(cfg *config.Config, productClient grpc.ProductClient) gin.Han
 			allowedExtensions := map[string]bool{".jpg": true, ".jpeg": true, ".png": true, ".pdf": true}
 			ext := filepath.Ext(req.Image.Filename)
 			if !allowedExtensions[ext] {
-				utils.Error("Invalid file format", map[string]interface{}{
+				utils.ErrorContext(c.Request.Context(), "Invalid file format", map[string]interface{}{
 					"extension": ext,
 				})
-				c.JSON(http.StatusBadRequest, utils.ErrorResponse{
+				utils.WriteError(c, http.StatusBadRequest, utils.ErrorResponse{
 					Type:    "VALIDATION_ERROR",
 					Message: "Invalid file format",
 					Details: map[string]string{"format": "Only JPG, JPEG, and PNG files are allowed"},
@@ -96,10 +95,10 @@ func CreateProduct(cfg *config.Config, productClient grpc.ProductClient) gin.Han
 			// Upload image to S3
 			imageURLResp, err := utils.UploadImageToS3(c, cfg, "products", req.Image)
 			if err != nil {
-				utils.Error("Failed to upload image to S3", map[string]interface{}{
+				utils.ErrorContext(c.Request.Context(), "Failed to upload image to S3", map[string]interface{}{
 					"error": err,
 				})
-				c.JSON(http.StatusInternalServerError, utils.ErrorResponse{
+				utils.WriteError(c, http.StatusInternalServerError, utils.ErrorResponse{
 					Type:    "INTERNAL_ERROR",
 					Message: "Failed to upload image",
 					Details: map[string]string{"error": err.Error()},
@@ -110,7 +109,7 @@ func CreateProduct(cfg *config.Config, productClient grpc.ProductClient) gin.Han
 		}
 
 		// Call the gRPC service to create product
-		resp, err := productClient.CreateProduct(context.Background(), &proto.CreateProductRequest{
+		resp, err := productClient.CreateProduct(c.Request.Context(), &proto.CreateProductRequest{
 			Product: &proto.Product{
 				Name:                 req.Name,
 				Description:          req.Description,
@@ -122,30 +121,27 @@ func CreateProduct(cfg *config.Config, productClient grpc.ProductClient) gin.Han
 		})
 
 		if err != nil {
-			utils.Error("Failed to create product", map[string]interface{}{
+			utils.ErrorContext(c.Request.Context(), "Failed to create product", map[string]interface{}{
 				"error": err,
 			})
-			c.JSON(http.StatusInternalServerError, utils.ErrorResponse{
-				Type:    "INTERNAL_ERROR",
-				Message: "Failed to create product",
-				Details: map[string]string{"error": err.Error()},
-			})
+			errorResp, statusCode := utils.ConvertGrpcErrorToResponse(err, "Failed to create product")
+			utils.WriteError(c, statusCode, errorResp)
 			return
 		}
 
 		if !resp.Success {
-			utils.Error("Failed to create product", map[string]interface{}{
+			utils.ErrorContext(c.Request.Context(), "Failed to create product", map[string]interface{}{
 				"error": resp,
 			})
 
 			if resp.Error != nil {
 				errorResp, statusCode := utils.ConvertProtoErrorToResponse(resp.Error)
-				c.JSON(statusCode, errorResp)
+				utils.WriteError(c, statusCode, errorResp)
 				return
 			}
 
 			// Fallback if error structure is not available
-			c.JSON(http.StatusBadRequest, utils.ErrorResponse{
+			utils.WriteError(c, http.StatusBadRequest, utils.ErrorResponse{
 				Type:    "UNKNOWN_ERROR",
 				Message: "Failed to create product",
 			})
@@ -172,36 +168,33 @@ func GetProduct(productClient grpc.ProductClient) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		productID := c.Param("id")
 
-		resp, err := productClient.GetProduct(context.Background(), &proto.GetProductRequest{
+		resp, err := productClient.GetProduct(c.Request.Context(), &proto.GetProductRequest{
 			ProductId: productID,
 		})
 		if err != nil {
-			utils.Error("Failed to get product", map[string]interface{}{
+			utils.ErrorContext(c.Request.Context(), "Failed to get product", map[string]interface{}{
 				"error":      err,
 				"product_id": productID,
 			})
-			c.JSON(http.StatusInternalServerError, utils.ErrorResponse{
-				Type:    "INTERNAL_ERROR",
-				Message: "Failed to get product",
-				Details: map[string]string{"error": err.Error()},
-			})
+			errorResp, statusCode := utils.ConvertGrpcErrorToResponse(err, "Failed to get product")
+			utils.WriteError(c, statusCode, errorResp)
 			return
 		}
 
 		if !resp.Success {
-			utils.Error("Failed to get product", map[string]interface{}{
+			utils.ErrorContext(c.Request.Context(), "Failed to get product", map[string]interface{}{
 				"error":      resp,
 				"product_id": productID,
 			})
 
 			if resp.Error != nil {
 				errorResp, statusCode := utils.ConvertProtoErrorToResponse(resp.Error)
-				c.JSON(statusCode, errorResp)
+				utils.WriteError(c, statusCode, errorResp)
 				return
 			}
 
 			// Fallback if error structure is not available
-			c.JSON(http.StatusBadRequest, utils.ErrorResponse{
+			utils.WriteError(c, http.StatusBadRequest, utils.ErrorResponse{
 				Type:    "UNKNOWN_ERROR",
 				Message: "Failed to get product",
 			})
@@ -250,7 +243,7 @@ func GetProducts(productClient grpc.ProductClient) gin.HandlerFunc {
 			}
 		}
 
-		resp, err := productClient.ListProducts(context.Background(), &proto.ListProductsRequest{
+		resp, err := productClient.ListProducts(c.Request.Context(), &proto.ListProductsRequest{
 			Search:    search,
 			Filter:    filter,
 			SortBy:    sortBy,
@@ -260,32 +253,29 @@ func GetProducts(productClient grpc.ProductClient) gin.HandlerFunc {
 		})
 
 		if err != nil {
-			utils.Error("Failed to get products", map[string]interface{}{
+			utils.ErrorContext(c.Request.Context(), "Failed to get products", map[string]interface{}{
 				"error": err,
 				"page":  page,
 				"limit": limit,
 			})
-			c.JSON(http.StatusInternalServerError, utils.ErrorResponse{
-				Type:    "INTERNAL_ERROR",
-				Message: "Failed to get products",
-				Details: map[string]string{"error": err.Error()},
-			})
+			errorResp, statusCode := utils.ConvertGrpcErrorToResponse(err, "Failed to get products")
+			utils.WriteError(c, statusCode, errorResp)
 			return
 		}
 
 		if !resp.Success {
-			utils.Error("Failed to get products", map[string]interface{}{
+			utils.ErrorContext(c.Request.Context(), "Failed to get products", map[string]interface{}{
 				"error": resp,
 			})
 
 			if resp.Error != nil {
 				errorResp, statusCode := utils.ConvertProtoErrorToResponse(resp.Error)
-				c.JSON(statusCode, errorResp)
+				utils.WriteError(c, statusCode, errorResp)
 				return
 			}
 
 			// Fallback if error structure is not available
-			c.JSON(http.StatusBadRequest, utils.ErrorResponse{
+			utils.WriteError(c, http.StatusBadRequest, utils.ErrorResponse{
 				Type:    "UNKNOWN_ERROR",
 				Message: "Failed to get products",
 			})
@@ -319,10 +309,10 @@ func UpdateProduct(cfg *config.Config, productClient grpc.ProductClient) gin.Han
 
 		var req UpdateProductReq
 		if err := c.ShouldBind(&req); err != nil {
-			utils.Error("Failed to bind request", map[string]interface{}{
+			utils.ErrorContext(c.Request.Context(), "Failed to bind request", map[string]interface{}{
 				"error": err,
 			})
-			c.JSON(http.StatusBadRequest, utils.ErrorResponse{
+			utils.WriteError(c, http.StatusBadRequest, utils.ErrorResponse{
 				Type:    "VALIDATION_ERROR",
 				Message: "Invalid request format",
 				Details: map[string]string{"format": err.Error()},
@@ -337,10 +327,10 @@ func UpdateProduct(cfg *config.Config, productClient grpc.ProductClient) gin.Han
 			allowedExtensions := map[string]bool{".jpg": true, ".jpeg": true, ".png": true, ".pdf": true}
 			ext := filepath.Ext(req.Image.Filename)
 			if !allowedExtensions[ext] {
-				utils.Error("Invalid file format", map[string]interface{}{
+				utils.ErrorContext(c.Request.Context(), "Invalid file format", map[string]interface{}{
 					"extension": ext,
 				})
-				c.JSON(http.StatusBadRequest, utils.ErrorResponse{
+				utils.WriteError(c, http.StatusBadRequest, utils.ErrorResponse{
 					Type:    "VALIDATION_ERROR",
 					Message: "Invalid file format",
 					Details: map[string]string{"format": "Only JPG, JPEG, and PNG files are allowed"},
@@ -351,10 +341,10 @@ func UpdateProduct(cfg *config.Config, productClient grpc.ProductClient) gin.Han
 			// Upload image to S3
 			imageURLResp, err := utils.UploadImageToS3(c, cfg, "products", req.Image)
 			if err != nil {
-				utils.Error("Failed to upload image to S3", map[string]interface{}{
+				utils.ErrorContext(c.Request.Context(), "Failed to upload image to S3", map[string]interface{}{
 					"error": err,
 				})
-				c.JSON(http.StatusInternalServerError, utils.ErrorResponse{
+				utils.WriteError(c, http.StatusInternalServerError, utils.ErrorResponse{
 					Type:    "INTERNAL_ERROR",
 					Message: "Failed to upload image",
 					Details: map[string]string{"error": err.Error()},
@@ -364,7 +354,7 @@ func UpdateProduct(cfg *config.Config, productClient grpc.ProductClient) gin.Han
 			imageURL = imageURLResp
 		}
 
-		resp, err := productClient.UpdateProduct(context.Background(), &proto.UpdateProductRequest{
+		resp, err := productClient.UpdateProduct(c.Request.Context(), &proto.UpdateProductRequest{
 			ProductId: productID,
 			Product: &proto.Product{
 				Name:                 req.Name,
@@ -375,32 +365,29 @@ func UpdateProduct(cfg *config.Config, productClient grpc.ProductClient) gin.Han
 			},
 		})
 		if err != nil {
-			utils.Error("Failed to update product", map[string]interface{}{
+			utils.ErrorContext(c.Request.Context(), "Failed to update product", map[string]interface{}{
 				"error":      err,
 				"product_id": productID,
 			})
-			c.JSON(http.StatusInternalServerError, utils.ErrorResponse{
-				Type:    "INTERNAL_ERROR",
-				Message: "Failed to update product",
-				Details: map[string]string{"error": err.Error()},
-			})
+			errorResp, statusCode := utils.ConvertGrpcErrorToResponse(err, "Failed to update product")
+			utils.WriteError(c, statusCode, errorResp)
 			return
 		}
 
 		if !resp.Success {
-			utils.Error("Failed to update product", map[string]interface{}{
+			utils.ErrorContext(c.Request.Context(), "Failed to update product", map[string]interface{}{
 				"error":      resp.Message,
 				"product_id": productID,
 			})
 
 			if resp.Error != nil {
 				errorResp, statusCode := utils.ConvertProtoErrorToResponse(resp.Error)
-				c.JSON(statusCode, errorResp)
+				utils.WriteError(c, statusCode, errorResp)
 				return
 			}
 
 			// Fallback if error structure is not available
-			c.JSON(http.StatusBadRequest, utils.ErrorResponse{
+			utils.WriteError(c, http.StatusBadRequest, utils.ErrorResponse{
 				Type:    "UNKNOWN_ERROR",
 				Message: resp.Message,
 			})
@@ -431,36 +418,33 @@ func DeleteProduct(productClient grpc.ProductClient) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		productID := c.Param("id")
 
-		resp, err := productClient.DeleteProduct(context.Background(), &proto.DeleteProductRequest{
+		resp, err := productClient.DeleteProduct(c.Request.Context(), &proto.DeleteProductRequest{
 			ProductId: productID,
 		})
 		if err != nil {
-			utils.Error("Failed to delete product", map[string]interface{}{
+			utils.ErrorContext(c.Request.Context(), "Failed to delete product", map[string]interface{}{
 				"error":      err,
 				"product_id": productID,
 			})
-			c.JSON(http.StatusInternalServerError, utils.ErrorResponse{
-				Type:    "INTERNAL_ERROR",
-				Message: "Failed to delete product",
-				Details: map[string]string{"error": err.Error()},
-			})
+			errorResp, statusCode := utils.ConvertGrpcErrorToResponse(err, "Failed to delete product")
+			utils.WriteError(c, statusCode, errorResp)
 			return
 		}
 
 		if !resp.Success {
-			utils.Error("Failed to delete product", map[string]interface{}{
+			utils.ErrorContext(c.Request.Context(), "Failed to delete product", map[string]interface{}{
 				"error":      resp.Message,
 				"product_id": productID,
 			})
 
 			if resp.Error != nil {
 				errorResp, statusCode := utils.ConvertProtoErrorToResponse(resp.Error)
-				c.JSON(statusCode, errorResp)
+				utils.WriteError(c, statusCode, errorResp)
 				return
 			}
 
 			// Fallback if error structure is not available
-			c.JSON(http.StatusBadRequest, utils.ErrorResponse{
+			utils.WriteError(c, http.StatusBadRequest, utils.ErrorResponse{
 				Type:    "UNKNOWN_ERROR",
 				Message: resp.Message,
 			})
@@ -499,10 +483,10 @@ func UpdateStock(productClient grpc.ProductClient) gin.HandlerFunc {
 
 		var req proto.UpdateStockRequest
 		if err := c.ShouldBindJSON(&req); err != nil {
-			utils.Error("Failed to bind request", map[string]interface{}{
+			utils.ErrorContext(c.Request.Context(), "Failed to bind request", map[string]interface{}{
 				"error": err,
 			})
-			c.JSON(http.StatusBadRequest, utils.ErrorResponse{
+			utils.WriteError(c, http.StatusBadRequest, utils.ErrorResponse{
 				Type:    "VALIDATION_ERROR",
 				Message: "Invalid request format",
 				Details: map[string]string{"format": err.Error()},
@@ -512,35 +496,32 @@ func UpdateStock(productClient grpc.ProductClient) gin.HandlerFunc {
 
 		req.ProductId = productID
 
-		resp, err := productClient.UpdateStock(context.Background(), &req)
+		resp, err := productClient.UpdateStock(c.Request.Context(), &req)
 		if err != nil {
-			utils.Error("Failed to update stock", map[string]interface{}{
+			utils.ErrorContext(c.Request.Context(), "Failed to update stock", map[string]interface{}{
 				"error":           err,
 				"product_id":      productID,
 				"quantity_change": req.QuantityChange,
 			})
-			c.JSON(http.StatusInternalServerError, utils.ErrorResponse{
-				Type:    "INTERNAL_ERROR",
-				Message: "Failed to update stock",
-				Details: map[string]string{"error": err.Error()},
-			})
+			errorResp, statusCode := utils.ConvertGrpcErrorToResponse(err, "Failed to update stock")
+			utils.WriteError(c, statusCode, errorResp)
 			return
 		}
 
 		if !resp.Success {
-			utils.Error("Failed to update stock", map[string]interface{}{
+			utils.ErrorContext(c.Request.Context(), "Failed to update stock", map[string]interface{}{
 				"error":      resp.Message,
 				"product_id": productID,
 			})
 
 			if resp.Error != nil {
 				errorResp, statusCode := utils.ConvertProtoErrorToResponse(resp.Error)
-				c.JSON(statusCode, errorResp)
+				utils.WriteError(c, statusCode, errorResp)
 				return
 			}
 
 			// Fallback if error structure is not available
-			c.JSON(http.StatusBadRequest, utils.ErrorResponse{
+			utils.WriteError(c, http.StatusBadRequest, utils.ErrorResponse{
 				Type:    "UNKNOWN_ERROR",
 				Message: resp.Message,
 			})
@@ -595,7 +576,7 @@ func GetInventoryLogs(productClient grpc.ProductClient) gin.HandlerFunc {
 			}
 		}
 
-		resp, err := productClient.GetInventoryLogs(context.Background(), &proto.GetInventoryLogsRequest{
+		resp, err := productClient.GetInventoryLogs(c.Request.Context(), &proto.GetInventoryLogsRequest{
 			ProductId: productID,
 			Filter:    filter,
 			SortBy:    sortBy,
@@ -605,30 +586,27 @@ func GetInventoryLogs(productClient grpc.ProductClient) gin.HandlerFunc {
 		})
 
 		if err != nil {
-			utils.Error("Failed to get inventory logs", map[string]interface{}{
+			utils.ErrorContext(c.Request.Context(), "Failed to get inventory logs", map[string]interface{}{
 				"error":      err,
 				"product_id": productID,
 			})
-			c.JSON(http.StatusInternalServerError, utils.ErrorResponse{
-				Type:    "INTERNAL_ERROR",
-				Message: "Failed to get inventory logs",
-				Details: map[string]string{"error": err.Error()},
-			})
+			errorResp, statusCode := utils.ConvertGrpcErrorToResponse(err, "Failed to get inventory logs")
+			utils.WriteError(c, statusCode, errorResp)
 			return
 		}
 
 		if !resp.Success {
-			utils.Error("Failed to get inventory logs", map[string]interface{}{
+			utils.ErrorContext(c.Request.Context(), "Failed to get inventory logs", map[string]interface{}{
 				"error": resp,
 			})
 
 			if resp.Error != nil {
 				errorResp, statusCode := utils.ConvertProtoErrorToResponse(resp.Error)
-				c.JSON(statusCode, errorResp)
+				utils.WriteError(c, statusCode, errorResp)
 				return
 			}
 
-			c.JSON(http.StatusBadRequest, utils.ErrorResponse{
+			utils.WriteError(c, http.StatusBadRequest, utils.ErrorResponse{
 				Type:    "UNKNOWN_ERROR",
 				Message: "Failed to get inventory logs",
 			})
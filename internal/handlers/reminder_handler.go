@@ -1,7 +1,6 @@
 package handlers
 
 import (
-	"context"
 	"net/http"
 
 	"github.com/PharmaKart/gateway-svc/internal/grpc"
@@ -35,7 +34,7 @@ func ScheduleReminder(reminderClient grpc.ReminderClient) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var req proto.ScheduleReminderRequest
 		if err := c.ShouldBindJSON(&req); err != nil {
-			c.JSON(http.StatusBadRequest, utils.ErrorResponse{
+			utils.WriteError(c, http.StatusBadRequest, utils.ErrorResponse{
 				Type:    "VALIDATION_ERROR",
 				Message: "Invalid request format",
 				Details: map[string]string{"format": err.Error()},
@@ -43,31 +42,29 @@ func ScheduleReminder(reminderClient grpc.ReminderClient) gin.HandlerFunc {
 			return
 		}
 
-		resp, err := reminderClient.ScheduleReminder(context.Background(), &req)
+		resp, err := reminderClient.ScheduleReminder(c.Request.Context(), &req)
 		if err != nil {
-			utils.Error("Failed to schedule reminder", map[string]interface{}{
+			utils.ErrorContext(c.Request.Context(), "Failed to schedule reminder", map[string]interface{}{
 				"error": err,
 			})
-			c.JSON(http.StatusInternalServerError, utils.ErrorResponse{
-				Type:    "INTERNAL_ERROR",
-				Message: "Failed to schedule reminder",
-			})
+			errorResp, statusCode := utils.ConvertGrpcErrorToResponse(err, "Failed to schedule reminder")
+			utils.WriteError(c, statusCode, errorResp)
 			return
 		}
 
 		if !resp.Success {
-			utils.Error("Failed to schedule reminder", map[string]interface{}{
+			utils.ErrorContext(c.Request.Context(), "Failed to schedule reminder", map[string]interface{}{
 				"error": resp,
 			})
 
 			if resp.Error != nil {
 				errorResp, statusCode := utils.ConvertProtoErrorToResponse(resp.Error)
-				c.JSON(statusCode, errorResp)
+				utils.WriteError(c, statusCode, errorResp)
 				return
 			}
 
 			// Fallback if error structure is not available
-			c.JSON(http.StatusBadRequest, utils.ErrorResponse{
+			utils.WriteError(c, http.StatusBadRequest, utils.ErrorResponse{
 				Type:    "UNKNOWN_ERROR",
 				Message: "Failed to schedule reminder",
 			})
@@ -117,7 +114,7 @@ func ListReminders(reminderClient grpc.ReminderClient) gin.HandlerFunc {
 			}
 		}
 
-		resp, err := reminderClient.ListReminders(context.Background(), &proto.ListRemindersRequest{
+		resp, err := reminderClient.ListReminders(c.Request.Context(), &proto.ListRemindersRequest{
 			Filter:    filter,
 			SortBy:    sortBy,
 			SortOrder: sortOrder,
@@ -125,28 +122,26 @@ func ListReminders(reminderClient grpc.ReminderClient) gin.HandlerFunc {
 			Limit:     int32(limit),
 		})
 		if err != nil {
-			utils.Error("Failed to get reminders", map[string]interface{}{
+			utils.ErrorContext(c.Request.Context(), "Failed to get reminders", map[string]interface{}{
 				"error": err,
 			})
-			c.JSON(http.StatusInternalServerError, utils.ErrorResponse{
-				Type:    "INTERNAL_ERROR",
-				Message: "Failed to get reminders",
-			})
+			errorResp, statusCode := utils.ConvertGrpcErrorToResponse(err, "Failed to get reminders")
+			utils.WriteError(c, statusCode, errorResp)
 			return
 		}
 
 		if !resp.Success {
-			utils.Error("Failed to get reminders", map[string]interface{}{
+			utils.ErrorContext(c.Request.Context(), "Failed to get reminders", map[string]interface{}{
 				"error": resp,
 			})
 
 			if resp.Error != nil {
 				errorResp, statusCode := utils.ConvertProtoErrorToResponse(resp.Error)
-				c.JSON(statusCode, errorResp)
+				utils.WriteError(c, statusCode, errorResp)
 				return
 			}
 
-			c.JSON(http.StatusBadRequest, utils.ErrorResponse{
+			utils.WriteError(c, http.StatusBadRequest, utils.ErrorResponse{
 				Type:    "UNKNOWN_ERROR",
 				Message: "Failed to get reminders",
 			})
@@ -181,7 +176,7 @@ func ListCustomerReminders(reminderClient grpc.ReminderClient) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		customerID, ok := c.Get("user_id")
 		if !ok {
-			c.JSON(http.StatusUnauthorized, utils.ErrorResponse{
+			utils.WriteError(c, http.StatusUnauthorized, utils.ErrorResponse{
 				Type:    "AUTH_ERROR",
 				Message: "User ID not found in token",
 			})
@@ -206,7 +201,7 @@ func ListCustomerReminders(reminderClient grpc.ReminderClient) gin.HandlerFunc {
 			}
 		}
 
-		resp, err := reminderClient.ListCustomerReminders(context.Background(), &proto.ListCustomerRemindersRequest{
+		resp, err := reminderClient.ListCustomerReminders(c.Request.Context(), &proto.ListCustomerRemindersRequest{
 			CustomerId: customerID.(string),
 			Filter:     filter,
 			SortBy:     sortBy,
@@ -215,28 +210,26 @@ func ListCustomerReminders(reminderClient grpc.ReminderClient) gin.HandlerFunc {
 			Limit:      int32(limit),
 		})
 		if err != nil {
-			utils.Error("Failed to get reminders", map[string]interface{}{
+			utils.ErrorContext(c.Request.Context(), "Failed to get reminders", map[string]interface{}{
 				"error": err,
 			})
-			c.JSON(http.StatusInternalServerError, utils.ErrorResponse{
-				Type:    "INTERNAL_ERROR",
-				Message: "Failed to get reminders",
-			})
+			errorResp, statusCode := utils.ConvertGrpcErrorToResponse(err, "Failed to get reminders")
+			utils.WriteError(c, statusCode, errorResp)
 			return
 		}
 
 		if !resp.Success {
-			utils.Error("Failed to get customer reminders", map[string]interface{}{
+			utils.ErrorContext(c.Request.Context(), "Failed to get customer reminders", map[string]interface{}{
 				"error": resp,
 			})
 
 			if resp.Error != nil {
 				errorResp, statusCode := utils.ConvertProtoErrorToResponse(resp.Error)
-				c.JSON(statusCode, errorResp)
+				utils.WriteError(c, statusCode, errorResp)
 				return
 			}
 
-			c.JSON(http.StatusBadRequest, utils.ErrorResponse{
+			utils.WriteError(c, http.StatusBadRequest, utils.ErrorResponse{
 				Type:    "UNKNOWN_ERROR",
 				Message: "Failed to get reminders",
 			})
@@ -264,7 +257,7 @@ func DeleteReminder(reminderClient grpc.ReminderClient) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		customerID, ok := c.Get("user_id")
 		if !ok {
-			c.JSON(http.StatusUnauthorized, utils.ErrorResponse{
+			utils.WriteError(c, http.StatusUnauthorized, utils.ErrorResponse{
 				Type:    "AUTH_ERROR",
 				Message: "User ID not found in token",
 			})
@@ -273,33 +266,31 @@ func DeleteReminder(reminderClient grpc.ReminderClient) gin.HandlerFunc {
 
 		reminderID := c.Param("reminder_id")
 
-		resp, err := reminderClient.DeleteReminder(context.Background(), &proto.DeleteReminderRequest{
+		resp, err := reminderClient.DeleteReminder(c.Request.Context(), &proto.DeleteReminderRequest{
 			CustomerId: customerID.(string),
 			ReminderId: reminderID,
 		})
 		if err != nil {
-			utils.Error("Failed to delete reminder", map[string]interface{}{
+			utils.ErrorContext(c.Request.Context(), "Failed to delete reminder", map[string]interface{}{
 				"error": err,
 			})
-			c.JSON(http.StatusInternalServerError, utils.ErrorResponse{
-				Type:    "INTERNAL_ERROR",
-				Message: "Failed to delete reminder",
-			})
+			errorResp, statusCode := utils.ConvertGrpcErrorToResponse(err, "Failed to delete reminder")
+			utils.WriteError(c, statusCode, errorResp)
 			return
 		}
 
 		if !resp.Success {
-			utils.Error("Failed to delete reminder", map[string]interface{}{
+			utils.ErrorContext(c.Request.Context(), "Failed to delete reminder", map[string]interface{}{
 				"error": resp.Message,
 			})
 
 			if resp.Error != nil {
 				errorResp, statusCode := utils.ConvertProtoErrorToResponse(resp.Error)
-				c.JSON(statusCode, errorResp)
+				utils.WriteError(c, statusCode, errorResp)
 				return
 			}
 
-			c.JSON(http.StatusBadRequest, utils.ErrorResponse{
+			utils.WriteError(c, http.StatusBadRequest, utils.ErrorResponse{
 				Type:    "UNKNOWN_ERROR",
 				Message: resp.Message,
 			})
@@ -329,7 +320,7 @@ func UpdateReminder(reminderClient grpc.ReminderClient) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		customerID, ok := c.Get("user_id")
 		if !ok {
-			c.JSON(http.StatusUnauthorized, utils.ErrorResponse{
+			utils.WriteError(c, http.StatusUnauthorized, utils.ErrorResponse{
 				Type:    "AUTH_ERROR",
 				Message: "User ID not found in token",
 			})
@@ -340,7 +331,7 @@ func UpdateReminder(reminderClient grpc.ReminderClient) gin.HandlerFunc {
 
 		var req proto.UpdateReminderRequest
 		if err := c.ShouldBindJSON(&req); err != nil {
-			c.JSON(http.StatusBadRequest, utils.ErrorResponse{
+			utils.WriteError(c, http.StatusBadRequest, utils.ErrorResponse{
 				Type:    "VALIDATION_ERROR",
 				Message: "Invalid request format",
 				Details: map[string]string{"format": err.Error()},
@@ -351,30 +342,28 @@ func UpdateReminder(reminderClient grpc.ReminderClient) gin.HandlerFunc {
 		req.CustomerId = customerID.(string)
 		req.ReminderId = reminderID
 
-		resp, err := reminderClient.UpdateReminder(context.Background(), &req)
+		resp, err := reminderClient.UpdateReminder(c.Request.Context(), &req)
 		if err != nil {
-			utils.Error("Failed to update reminder", map[string]interface{}{
+			utils.ErrorContext(c.Request.Context(), "Failed to update reminder", map[string]interface{}{
 				"error": err,
 			})
-			c.JSON(http.StatusInternalServerError, utils.ErrorResponse{
-				Type:    "INTERNAL_ERROR",
-				Message: "Failed to update reminder",
-			})
+			errorResp, statusCode := utils.ConvertGrpcErrorToResponse(err, "Failed to update reminder")
+			utils.WriteError(c, statusCode, errorResp)
 			return
 		}
 
 		if !resp.Success {
-			utils.Error("Failed to update reminder", map[string]interface{}{
+			utils.ErrorContext(c.Request.Context(), "Failed to update reminder", map[string]interface{}{
 				"error": resp.Message,
 			})
 
 			if resp.Error != nil {
 				errorResp, statusCode := utils.ConvertProtoErrorToResponse(resp.Error)
-				c.JSON(statusCode, errorResp)
+				utils.WriteError(c, statusCode, errorResp)
 				return
 			}
 
-			c.JSON(http.StatusBadRequest, utils.ErrorResponse{
+			utils.WriteError(c, http.StatusBadRequest, utils.ErrorResponse{
 				Type:    "UNKNOWN_ERROR",
 				Message: resp.Message,
 			})
@@ -402,7 +391,7 @@ func ToggleReminder(reminderClient grpc.ReminderClient) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		customerID, ok := c.Get("user_id")
 		if !ok {
-			c.JSON(http.StatusUnauthorized, utils.ErrorResponse{
+			utils.WriteError(c, http.StatusUnauthorized, utils.ErrorResponse{
 				Type:    "AUTH_ERROR",
 				Message: "User ID not found in token",
 			})
@@ -411,33 +400,31 @@ func ToggleReminder(reminderClient grpc.ReminderClient) gin.HandlerFunc {
 
 		reminderID := c.Param("reminder_id")
 
-		resp, err := reminderClient.ToggleReminder(context.Background(), &proto.ToggleReminderRequest{
+		resp, err := reminderClient.ToggleReminder(c.Request.Context(), &proto.ToggleReminderRequest{
 			CustomerId: customerID.(string),
 			ReminderId: reminderID,
 		})
 		if err != nil {
-			utils.Error("Failed to toggle reminder", map[string]interface{}{
+			utils.ErrorContext(c.Request.Context(), "Failed to toggle reminder", map[string]interface{}{
 				"error": err,
 			})
-			c.JSON(http.StatusInternalServerError, utils.ErrorResponse{
-				Type:    "INTERNAL_ERROR",
-				Message: "Failed to toggle reminder",
-			})
+			errorResp, statusCode := utils.ConvertGrpcErrorToResponse(err, "Failed to toggle reminder")
+			utils.WriteError(c, statusCode, errorResp)
 			return
 		}
 
 		if !resp.Success {
-			utils.Error("Failed to toggle reminder", map[string]interface{}{
+			utils.ErrorContext(c.Request.Context(), "Failed to toggle reminder", map[string]interface{}{
 				"error": resp.Message,
 			})
 
 			if resp.Error != nil {
 				errorResp, statusCode := utils.ConvertProtoErrorToResponse(resp.Error)
-				c.JSON(statusCode, errorResp)
+				utils.WriteError(c, statusCode, errorResp)
 				return
 			}
 
-			c.JSON(http.StatusBadRequest, utils.ErrorResponse{
+			utils.WriteError(c, http.StatusBadRequest, utils.ErrorResponse{
 				Type:    "UNKNOWN_ERROR",
 				Message: resp.Message,
 			})
@@ -472,7 +459,7 @@ func ListReminderLogs(reminderClient grpc.ReminderClient) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userRole, ok := c.Get("user_role")
 		if !ok {
-			c.JSON(http.StatusUnauthorized, utils.ErrorResponse{
+			utils.WriteError(c, http.StatusUnauthorized, utils.ErrorResponse{
 				Type:    "AUTH_ERROR",
 				Message: "User Role not found in token",
 			})
@@ -481,7 +468,7 @@ func ListReminderLogs(reminderClient grpc.ReminderClient) gin.HandlerFunc {
 
 		customerID, ok := c.Get("user_id")
 		if !ok {
-			c.JSON(http.StatusUnauthorized, utils.ErrorResponse{
+			utils.WriteError(c, http.StatusUnauthorized, utils.ErrorResponse{
 				Type:    "AUTH_ERROR",
 				Message: "User ID not found in token",
 			})
@@ -512,7 +499,7 @@ func ListReminderLogs(reminderClient grpc.ReminderClient) gin.HandlerFunc {
 			}
 		}
 
-		resp, err := reminderClient.ListReminderLogs(context.Background(), &proto.ListReminderLogsRequest{
+		resp, err := reminderClient.ListReminderLogs(c.Request.Context(), &proto.ListReminderLogsRequest{
 			CustomerId: customerID.(string),
 			ReminderId: reminderID,
 			Filter:     filter,
@@ -522,28 +509,26 @@ func ListReminderLogs(reminderClient grpc.ReminderClient) gin.HandlerFunc {
 			Limit:      int32(limit),
 		})
 		if err != nil {
-			utils.Error("Failed to get reminder logs", map[string]interface{}{
+			utils.ErrorContext(c.Request.Context(), "Failed to get reminder logs", map[string]interface{}{
 				"error": err,
 			})
-			c.JSON(http.StatusInternalServerError, utils.ErrorResponse{
-				Type:    "INTERNAL_ERROR",
-				Message: "Failed to get reminder logs",
-			})
+			errorResp, statusCode := utils.ConvertGrpcErrorToResponse(err, "Failed to get reminder logs")
+			utils.WriteError(c, statusCode, errorResp)
 			return
 		}
 
 		if !resp.Success {
-			utils.Error("Failed to get reminder logs", map[string]interface{}{
+			utils.ErrorContext(c.Request.Context(), "Failed to get reminder logs", map[string]interface{}{
 				"error": resp,
 			})
 
 			if resp.Error != nil {
 				errorResp, statusCode := utils.ConvertProtoErrorToResponse(resp.Error)
-				c.JSON(statusCode, errorResp)
+				utils.WriteError(c, statusCode, errorResp)
 				return
 			}
 
-			c.JSON(http.StatusBadRequest, utils.ErrorResponse{
+			utils.WriteError(c, http.StatusBadRequest, utils.ErrorResponse{
 				Type:    "UNKNOWN_ERROR",
 				Message: "Failed to get reminder logs",
 			})
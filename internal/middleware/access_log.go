@@ -0,0 +1,67 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/PharmaKart/gateway-svc/internal/grpc"
+	"github.com/PharmaKart/gateway-svc/pkg/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// AccessLog writes one structured entry per request, including the downstream calls made to serve it.
+// Paths matching excludePaths are not logged; a trailing * matches any suffix.
+func AccessLog(excludePaths []string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if excludedPath(c.Request.URL.Path, excludePaths) {
+			c.Next()
+			return
+		}
+
+		ctx, callLog := grpc.ContextWithCallLog(c.Request.Context())
+		c.Request = c.Request.WithContext(ctx)
+
+		start := time.Now()
+		c.Next()
+
+		status := c.Writer.Status()
+		fields := map[string]interface{}{
+			"method":           c.Request.Method,
+			"route":            c.FullPath(),
+			"path":             c.Request.URL.Path,
+			"status":           status,
+			"latency_ms":       float64(time.Since(start).Microseconds()) / 1000,
+			"bytes":            c.Writer.Size(),
+			"client_ip":        c.ClientIP(),
+			"user_id":          c.GetString("user_id"),
+			"role":             c.GetString("user_role"),
+			"downstream_calls": callLog.Calls(),
+		}
+		if len(c.Errors) > 0 {
+			fields["errors"] = c.Errors.String()
+		}
+
+		switch {
+		case status >= http.StatusInternalServerError:
+			utils.ErrorContext(c.Request.Context(), "HTTP request", fields)
+		case status >= http.StatusBadRequest:
+			utils.WarnContext(c.Request.Context(), "HTTP request", fields)
+		default:
+			utils.InfoContext(c.Request.Context(), "HTTP request", fields)
+		}
+	}
+}
+
+func excludedPath(path string, excludePaths []string) bool {
+	for _, pattern := range excludePaths {
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+			if strings.HasPrefix(path, prefix) {
+				return true
+			}
+		} else if path == pattern {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,158 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/PharmaKart/gateway-svc/internal/grpc"
+	"github.com/PharmaKart/gateway-svc/pkg/config"
+	"github.com/PharmaKart/gateway-svc/pkg/utils"
+	"github.com/gin-gonic/gin"
+	googlegrpc "google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	testgrpc "google.golang.org/grpc/interop/grpc_testing"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// captureAccessLog logs at debug level into the returned buffer until the test ends
+func captureAccessLog(t *testing.T) *bytes.Buffer {
+	t.Helper()
+
+	utils.InitLogger(&config.Config{LogLevel: "debug"})
+	var out bytes.Buffer
+	utils.Logger.SetOutput(&out)
+	t.Cleanup(func() {
+		utils.InitLogger(&config.Config{LogLevel: "panic"})
+	})
+	return &out
+}
+
+func TestAccessLog(t *testing.T) {
+	listener := bufconn.Listen(1 << 20)
+	server := googlegrpc.NewServer()
+	testgrpc.RegisterTestServiceServer(server, &metadataServer{})
+	go server.Serve(listener)
+	defer server.Stop()
+
+	conn, err := googlegrpc.NewClient("passthrough:///bufconn",
+		googlegrpc.WithTransportCredentials(insecure.NewCredentials()),
+		googlegrpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithMetrics("test-svc"),
+	)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+
+	r := gin.New()
+	r.Use(RequestID(), AccessLog([]string{"/health", "/static/*"}))
+	r.GET("/orders/:id", func(c *gin.Context) {
+		c.Set("user_id", "user-1")
+		c.Set("user_role", "customer")
+		if _, err := testgrpc.NewTestServiceClient(conn).EmptyCall(c.Request.Context(), &testgrpc.Empty{}); err != nil {
+			t.Errorf("EmptyCall: %v", err)
+		}
+		c.Status(http.StatusOK)
+	})
+	r.GET("/fail/:code", func(c *gin.Context) {
+		_ = c.Error(http.ErrNotSupported)
+		switch c.Param("code") {
+		case "400":
+			c.Status(http.StatusBadRequest)
+		default:
+			c.Status(http.StatusBadGateway)
+		}
+	})
+	r.GET("/health", func(c *gin.Context) { c.Status(http.StatusOK) })
+	r.GET("/static/*file", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	tests := []struct {
+		name      string
+		path      string
+		wantLevel string
+		check     func(t *testing.T, entry map[string]interface{})
+	}{
+		{
+			name:      "successful request",
+			path:      "/orders/42",
+			wantLevel: "info",
+			check: func(t *testing.T, entry map[string]interface{}) {
+				if entry["route"] != "/orders/:id" || entry["path"] != "/orders/42" || entry["status"] != float64(http.StatusOK) {
+					t.Errorf("entry = %v, want route, path and status", entry)
+				}
+				if entry["user_id"] != "user-1" || entry["role"] != "customer" {
+					t.Errorf("entry = %v, want the user and role", entry)
+				}
+				if entry["request_id"] == nil || entry["request_id"] == "" {
+					t.Errorf("entry = %v, want the request ID", entry)
+				}
+
+				calls, _ := entry["downstream_calls"].([]interface{})
+				if len(calls) != 1 {
+					t.Fatalf("downstream_calls = %v, want 1 call", entry["downstream_calls"])
+				}
+				call := calls[0].(map[string]interface{})
+				if call["service"] != "test-svc" || call["method"] != "/grpc.testing.TestService/EmptyCall" || call["code"] != "OK" {
+					t.Errorf("downstream call = %v", call)
+				}
+			},
+		},
+		{
+			name:      "client error",
+			path:      "/fail/400",
+			wantLevel: "warning",
+			check: func(t *testing.T, entry map[string]interface{}) {
+				if entry["errors"] == nil {
+					t.Errorf("entry = %v, want the handler errors", entry)
+				}
+			},
+		},
+		{
+			name:      "server error",
+			path:      "/fail/502",
+			wantLevel: "error",
+		},
+		{
+			name: "excluded path",
+			path: "/health",
+		},
+		{
+			name: "excluded prefix",
+			path: "/static/app.js",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := captureAccessLog(t)
+			r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			logged := strings.TrimSpace(out.String())
+			if tt.wantLevel == "" {
+				if logged != "" {
+					t.Fatalf("logged %q for an excluded path", logged)
+				}
+				return
+			}
+
+			var entry map[string]interface{}
+			if err := json.Unmarshal([]byte(logged), &entry); err != nil {
+				t.Fatalf("decode entry %q: %v", logged, err)
+			}
+			if entry["msg"] != "HTTP request" || entry["level"] != tt.wantLevel {
+				t.Fatalf("entry = %v, want an HTTP request entry at level %s", entry, tt.wantLevel)
+			}
+			if tt.check != nil {
+				tt.check(t, entry)
+			}
+		})
+	}
+}
@@ -20,10 +20,10 @@ func AuthMiddleware(authClient grpc.AuthClient) gin.HandlerFunc {
 
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
-			utils.Error("Authorization header is missing", map[string]interface{}{
+			utils.ErrorContext(c.Request.Context(), "Authorization header is missing", map[string]interface{}{
 				"path": c.Request.URL.Path,
 			})
-			c.JSON(http.StatusUnauthorized, utils.ErrorResponse{
+			utils.WriteError(c, http.StatusUnauthorized, utils.ErrorResponse{
 				Type:    "AUTH_ERROR",
 				Message: "Authorization header is missing",
 			})
@@ -33,10 +33,10 @@ func AuthMiddleware(authClient grpc.AuthClient) gin.HandlerFunc {
 
 		tokenParts := strings.Split(authHeader, " ")
 		if len(tokenParts) != 2 || tokenParts[0] != "Bearer" {
-			utils.Error("Invalid authorization header", map[string]interface{}{
+			utils.ErrorContext(c.Request.Context(), "Invalid authorization header", map[string]interface{}{
 				"path": c.Request.URL.Path,
 			})
-			c.JSON(http.StatusUnauthorized, utils.ErrorResponse{
+			utils.WriteError(c, http.StatusUnauthorized, utils.ErrorResponse{
 				Type:    "AUTH_ERROR",
 				Message: "Invalid authorization header",
 			})
@@ -47,30 +47,28 @@ func AuthMiddleware(authClient grpc.AuthClient) gin.HandlerFunc {
 
 		resp, err := authClient.VerifyToken(c.Request.Context(), &proto.VerifyTokenRequest{Token: token})
 		if err != nil {
-			utils.Error("Failed to verify token", map[string]interface{}{
+			utils.ErrorContext(c.Request.Context(), "Failed to verify token", map[string]interface{}{
 				"error": err,
 			})
-			c.JSON(http.StatusUnauthorized, utils.ErrorResponse{
-				Type:    "AUTH_ERROR",
-				Message: "Failed to verify token",
-			})
+			errorResp, statusCode := utils.ConvertGrpcErrorToResponse(err, "Failed to verify token")
+			utils.WriteError(c, statusCode, errorResp)
 			c.Abort()
 			return
 		}
 
 		if !resp.Success {
-			utils.Error(resp.Message, map[string]interface{}{
+			utils.ErrorContext(c.Request.Context(), resp.Message, map[string]interface{}{
 				"path": c.Request.URL.Path,
 			})
 			if resp.Error != nil {
 				errorResp, statusCode := utils.ConvertProtoErrorToResponse(resp.Error)
-				c.JSON(statusCode, errorResp)
+				utils.WriteError(c, statusCode, errorResp)
 				c.Abort()
 				return
 			}
 
 			// Fallback if error structure is not available
-			c.JSON(http.StatusBadRequest, utils.ErrorResponse{
+			utils.WriteError(c, http.StatusBadRequest, utils.ErrorResponse{
 				Type:    "UNKNOWN_ERROR",
 				Message: resp.Message,
 			})
@@ -80,8 +78,9 @@ func AuthMiddleware(authClient grpc.AuthClient) gin.HandlerFunc {
 
 		c.Set("user_id", resp.UserId)
 		c.Set("user_role", resp.Role)
+		c.Set("token", token)
 
-		utils.Info("User authenticated", map[string]interface{}{
+		utils.InfoContext(c.Request.Context(), "User authenticated", map[string]interface{}{
 			"user_id":   resp.UserId,
 			"user_role": resp.Role,
 		})
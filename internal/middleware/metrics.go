@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/PharmaKart/gateway-svc/pkg/metrics"
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	httpRequests = metrics.Factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "gateway_http_requests_total",
+		Help: "Total number of HTTP requests handled by the gateway.",
+	}, []string{"method", "route", "status"})
+	httpRequestDuration = metrics.Factory.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "gateway_http_request_duration_seconds",
+		Help:    "Latency of HTTP requests handled by the gateway.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+	httpRequestsInFlight = metrics.Factory.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "gateway_http_requests_in_flight",
+		Help: "Number of HTTP requests currently being handled.",
+	}, []string{"method", "route"})
+)
+
+// Metrics records request counts, latencies and in-flight requests. Requests are labelled by the
+// route template rather than the raw path, so that IDs in URLs do not create new series.
+func Metrics() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		method := c.Request.Method
+
+		httpRequestsInFlight.WithLabelValues(method, route).Inc()
+		start := time.Now()
+
+		defer func() {
+			status := strconv.Itoa(c.Writer.Status())
+
+			httpRequestsInFlight.WithLabelValues(method, route).Dec()
+			httpRequestDuration.WithLabelValues(method, route, status).Observe(time.Since(start).Seconds())
+			httpRequests.WithLabelValues(method, route, status).Inc()
+		}()
+
+		c.Next()
+	}
+}
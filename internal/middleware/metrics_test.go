@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMetricsLabelRouteTemplates(t *testing.T) {
+	r := gin.New()
+	r.Use(Metrics())
+	r.GET("/orders/:id", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	routed := httpRequests.WithLabelValues(http.MethodGet, "/orders/:id", "200")
+	unmatched := httpRequests.WithLabelValues(http.MethodGet, "unmatched", "404")
+	routedBefore, unmatchedBefore := testutil.ToFloat64(routed), testutil.ToFloat64(unmatched)
+
+	for _, path := range []string{"/orders/1", "/orders/2", "/unknown"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	if got := testutil.ToFloat64(routed) - routedBefore; got != 2 {
+		t.Errorf("requests to /orders/:id = %v, want 2", got)
+	}
+	if got := testutil.ToFloat64(unmatched) - unmatchedBefore; got != 1 {
+		t.Errorf("unmatched requests = %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(httpRequestsInFlight.WithLabelValues(http.MethodGet, "/orders/:id")); got != 0 {
+		t.Errorf("requests in flight = %v, want 0", got)
+	}
+}
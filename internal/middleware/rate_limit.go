@@ -0,0 +1,121 @@
+package middleware
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/PharmaKart/gateway-svc/internal/ratelimit"
+	"github.com/PharmaKart/gateway-svc/pkg/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// RateLimitKeyFunc derives the bucket key for a request. An empty key skips rate limiting.
+type RateLimitKeyFunc func(c *gin.Context) string
+
+// RateLimiter applies a token bucket limit, refilling at rate tokens per second up to burst,
+// to buckets held in a shared store
+type RateLimiter struct {
+	store ratelimit.Store
+	name  string
+	rate  float64
+	burst int
+}
+
+// NewRateLimiter creates a named limiter backed by store.
+// A non-positive rate or burst disables the limiter.
+func NewRateLimiter(store ratelimit.Store, name string, rate float64, burst int) *RateLimiter {
+	if store == nil || rate <= 0 || burst <= 0 {
+		return nil
+	}
+
+	return &RateLimiter{
+		store: store,
+		name:  name,
+		rate:  rate,
+		burst: burst,
+	}
+}
+
+// Allow takes a token from the bucket identified by key
+func (l *RateLimiter) Allow(ctx context.Context, key string) (ratelimit.Result, error) {
+	return l.store.Take(ctx, l.name+":"+key, l.rate, l.burst)
+}
+
+// KeyByIP limits requests per client IP
+func KeyByIP(c *gin.Context) string {
+	return "ip:" + c.ClientIP()
+}
+
+// KeyByUser limits requests per authenticated user. Requests without a user are not limited.
+func KeyByUser(c *gin.Context) string {
+	userID, ok := c.Get("user_id")
+	if !ok {
+		return ""
+	}
+
+	id, ok := userID.(string)
+	if !ok || id == "" {
+		return ""
+	}
+
+	return "user:" + id
+}
+
+// KeyByRoute limits requests per client IP within a named route group
+func KeyByRoute(group string) RateLimitKeyFunc {
+	return func(c *gin.Context) string {
+		return "route:" + group + ":" + c.ClientIP()
+	}
+}
+
+// RateLimitMiddleware rejects requests with 429 once the bucket for their key is empty
+func RateLimitMiddleware(limiter *RateLimiter, keyFunc RateLimitKeyFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if limiter == nil {
+			c.Next()
+			return
+		}
+
+		key := keyFunc(c)
+		if key == "" {
+			c.Next()
+			return
+		}
+
+		result, err := limiter.Allow(c.Request.Context(), key)
+		if err != nil {
+			// Fail open so that a store outage does not take the gateway down with it
+			utils.ErrorContext(c.Request.Context(), "Failed to check rate limit", map[string]interface{}{
+				"error": err,
+				"key":   key,
+			})
+			c.Next()
+			return
+		}
+
+		c.Header("X-RateLimit-Limit", strconv.Itoa(result.Limit))
+		c.Header("X-RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		c.Header("X-RateLimit-Reset", strconv.FormatInt(time.Now().Add(result.ResetAfter).Unix(), 10))
+
+		if !result.Allowed {
+			retryAfter := strconv.Itoa(int(math.Ceil(result.RetryAfter.Seconds())))
+
+			utils.WarnContext(c.Request.Context(), "Rate limit exceeded", map[string]interface{}{
+				"path": c.Request.URL.Path,
+				"key":  key,
+			})
+			c.Header("Retry-After", retryAfter)
+			utils.AbortWithError(c, http.StatusTooManyRequests, utils.ErrorResponse{
+				Type:    "RATE_LIMIT_ERROR",
+				Message: "Too many requests",
+				Details: map[string]string{"retry_after": retryAfter},
+			})
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,160 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/PharmaKart/gateway-svc/internal/ratelimit"
+	"github.com/PharmaKart/gateway-svc/pkg/config"
+	"github.com/PharmaKart/gateway-svc/pkg/utils"
+	"github.com/gin-gonic/gin"
+)
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	utils.InitLogger(&config.Config{LogLevel: "panic"})
+	os.Exit(m.Run())
+}
+
+// newRateLimitedEngine serves GET /limited behind limiter, trusting X-Forwarded-For from trustedProxies only
+func newRateLimitedEngine(t *testing.T, limiter *RateLimiter, keyFunc RateLimitKeyFunc, trustedProxies []string) *gin.Engine {
+	t.Helper()
+
+	r := gin.New()
+	if err := r.SetTrustedProxies(trustedProxies); err != nil {
+		t.Fatalf("SetTrustedProxies: %v", err)
+	}
+	r.GET("/limited", RateLimitMiddleware(limiter, keyFunc), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	return r
+}
+
+func serve(r http.Handler, remoteAddr string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/limited", nil)
+	req.RemoteAddr = remoteAddr
+	for name, values := range header {
+		req.Header[name] = values
+	}
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestRateLimitMiddlewareRejectsOnceBurstIsSpent(t *testing.T) {
+	limiter := NewRateLimiter(ratelimit.NewMemoryStore(), "test", 0.001, 3)
+	r := newRateLimitedEngine(t, limiter, KeyByIP, nil)
+
+	for i := 0; i < 3; i++ {
+		w := serve(r, "192.0.2.1:1234", nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("request %d: status = %d, want %d", i+1, w.Code, http.StatusOK)
+		}
+		if got := w.Header().Get("X-RateLimit-Limit"); got != "3" {
+			t.Errorf("X-RateLimit-Limit = %q, want 3", got)
+		}
+	}
+
+	w := serve(r, "192.0.2.1:1234", nil)
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusTooManyRequests)
+	}
+	if w.Header().Get("Retry-After") == "" {
+		t.Error("Retry-After header is missing")
+	}
+	if got := w.Header().Get("X-RateLimit-Remaining"); got != "0" {
+		t.Errorf("X-RateLimit-Remaining = %q, want 0", got)
+	}
+
+	// Other clients have their own bucket
+	if w := serve(r, "192.0.2.2:1234", nil); w.Code != http.StatusOK {
+		t.Errorf("other client: status = %d, want %d", w.Code, http.StatusOK)
+	}
+}
+
+func TestRateLimitMiddlewareIgnoresForwardedForFromUntrustedClients(t *testing.T) {
+	limiter := NewRateLimiter(ratelimit.NewMemoryStore(), "test", 0.001, 1)
+	r := newRateLimitedEngine(t, limiter, KeyByRoute("auth"), nil)
+
+	if w := serve(r, "192.0.2.1:1234", http.Header{"X-Forwarded-For": {"198.51.100.1"}}); w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+	}
+
+	// A new forwarded address per request must not give the client a new bucket
+	w := serve(r, "192.0.2.1:1234", http.Header{"X-Forwarded-For": {"198.51.100.2"}})
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("spoofed X-Forwarded-For: status = %d, want %d", w.Code, http.StatusTooManyRequests)
+	}
+}
+
+func TestRateLimitMiddlewareHonorsForwardedForFromTrustedProxies(t *testing.T) {
+	limiter := NewRateLimiter(ratelimit.NewMemoryStore(), "test", 0.001, 1)
+	r := newRateLimitedEngine(t, limiter, KeyByIP, []string{"10.0.0.0/8"})
+
+	for _, client := range []string{"198.51.100.1", "198.51.100.2"} {
+		if w := serve(r, "10.0.0.5:1234", http.Header{"X-Forwarded-For": {client}}); w.Code != http.StatusOK {
+			t.Fatalf("client %s: status = %d, want %d", client, w.Code, http.StatusOK)
+		}
+	}
+
+	if w := serve(r, "10.0.0.5:1234", http.Header{"X-Forwarded-For": {"198.51.100.1"}}); w.Code != http.StatusTooManyRequests {
+		t.Fatalf("repeated client: status = %d, want %d", w.Code, http.StatusTooManyRequests)
+	}
+}
+
+func TestRateLimitMiddlewareSkipsRequestsWithoutKey(t *testing.T) {
+	limiter := NewRateLimiter(ratelimit.NewMemoryStore(), "test", 0.001, 1)
+	r := newRateLimitedEngine(t, limiter, KeyByUser, nil)
+
+	for i := 0; i < 3; i++ {
+		if w := serve(r, "192.0.2.1:1234", nil); w.Code != http.StatusOK {
+			t.Fatalf("request %d: status = %d, want %d", i+1, w.Code, http.StatusOK)
+		}
+	}
+}
+
+func TestNewRateLimiterDisabled(t *testing.T) {
+	tests := []struct {
+		name  string
+		store ratelimit.Store
+		rate  float64
+		burst int
+	}{
+		{"no store", nil, 1, 1},
+		{"zero rate", ratelimit.NewMemoryStore(), 0, 1},
+		{"zero burst", ratelimit.NewMemoryStore(), 1, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if limiter := NewRateLimiter(tt.store, "test", tt.rate, tt.burst); limiter != nil {
+				t.Fatal("limiter is enabled")
+			}
+		})
+	}
+}
+
+type failingStore struct{}
+
+func (failingStore) Take(ctx context.Context, key string, rate float64, burst int) (ratelimit.Result, error) {
+	return ratelimit.Result{}, errors.New("store unavailable")
+}
+
+func (failingStore) Close() error {
+	return nil
+}
+
+func TestRateLimitMiddlewareFailsOpen(t *testing.T) {
+	r := newRateLimitedEngine(t, NewRateLimiter(failingStore{}, "test", 1, 1), KeyByIP, nil)
+
+	for i := 0; i < 3; i++ {
+		if w := serve(r, "192.0.2.1:1234", nil); w.Code != http.StatusOK {
+			t.Fatalf("request %d: status = %d, want %d", i+1, w.Code, http.StatusOK)
+		}
+	}
+}
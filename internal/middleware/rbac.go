@@ -11,10 +11,10 @@ func RBACMiddleware(allowedRoles ...string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		role, exists := c.Get("user_role")
 		if !exists {
-			utils.Error("User not authenticated", map[string]interface{}{
+			utils.ErrorContext(c.Request.Context(), "User not authenticated", map[string]interface{}{
 				"path": c.Request.URL.Path,
 			})
-			c.AbortWithStatusJSON(http.StatusUnauthorized, utils.ErrorResponse{
+			utils.AbortWithError(c, http.StatusUnauthorized, utils.ErrorResponse{
 				Type:    "AUTH_ERROR",
 				Message: "User not authenticated",
 			})
@@ -31,10 +31,10 @@ func RBACMiddleware(allowedRoles ...string) gin.HandlerFunc {
 		}
 
 		if !allowed {
-			utils.Error("User not authorized", map[string]interface{}{
+			utils.ErrorContext(c.Request.Context(), "User not authorized", map[string]interface{}{
 				"path": c.Request.URL.Path,
 			})
-			c.AbortWithStatusJSON(http.StatusForbidden, utils.ErrorResponse{
+			utils.AbortWithError(c, http.StatusForbidden, utils.ErrorResponse{
 				Type:    "AUTH_ERROR",
 				Message: "User not authorized",
 			})
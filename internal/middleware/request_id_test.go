@@ -0,0 +1,75 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/PharmaKart/gateway-svc/pkg/utils"
+	"github.com/gin-gonic/gin"
+)
+
+func TestRequestID(t *testing.T) {
+	tests := []struct {
+		name     string
+		incoming string
+		wantKept bool
+	}{
+		{"caller ID", "order-42:retry_1.a", true},
+		{"missing", "", false},
+		{"unsafe characters", "id\nX-Injected: 1", false},
+		{"too long", strings.Repeat("a", maxRequestIDLength+1), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var fromGin, fromContext string
+			r := gin.New()
+			r.Use(RequestID())
+			r.GET("/", func(c *gin.Context) {
+				fromGin = c.GetString("request_id")
+				fromContext = utils.RequestIDFromContext(c.Request.Context())
+				c.Status(http.StatusOK)
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.incoming != "" {
+				req.Header.Set(utils.RequestIDHeader, tt.incoming)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			echoed := w.Header().Get(utils.RequestIDHeader)
+			if echoed == "" {
+				t.Fatal("response has no request ID")
+			}
+			if kept := echoed == tt.incoming; kept != tt.wantKept {
+				t.Fatalf("response request ID = %q, caller sent %q", echoed, tt.incoming)
+			}
+			if fromGin != echoed || fromContext != echoed {
+				t.Fatalf("handler saw %q in gin and %q in the context, response has %q", fromGin, fromContext, echoed)
+			}
+		})
+	}
+}
+
+func TestRequestIDGeneratesUniqueIDs(t *testing.T) {
+	r := gin.New()
+	r.Use(RequestID())
+	r.GET("/", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	seen := make(map[string]bool)
+	for i := 0; i < 100; i++ {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		requestID := w.Header().Get(utils.RequestIDHeader)
+		if !validRequestID(requestID) || seen[requestID] {
+			t.Fatalf("generated request ID %q is invalid or repeated", requestID)
+		}
+		seen[requestID] = true
+	}
+}
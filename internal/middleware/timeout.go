@@ -0,0 +1,25 @@
+package middleware
+
+import (
+	"context"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequestTimeout gives every request a total time budget. Downstream gRPC calls made with the
+// request context share this budget, and its deadline is propagated to the backends.
+func RequestTimeout(budget time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if budget <= 0 {
+			c.Next()
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), budget)
+		defer cancel()
+
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRequestTimeout(t *testing.T) {
+	tests := []struct {
+		name         string
+		budget       time.Duration
+		wantDeadline bool
+	}{
+		{"budget", 2 * time.Second, true},
+		{"disabled", 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				deadline    time.Time
+				hasDeadline bool
+			)
+			r := gin.New()
+			r.Use(RequestTimeout(tt.budget))
+			r.GET("/", func(c *gin.Context) {
+				deadline, hasDeadline = c.Request.Context().Deadline()
+				c.Status(http.StatusNoContent)
+			})
+
+			r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+			if hasDeadline != tt.wantDeadline {
+				t.Fatalf("has deadline = %v, want %v", hasDeadline, tt.wantDeadline)
+			}
+			if tt.wantDeadline && time.Until(deadline) > tt.budget {
+				t.Fatalf("deadline in %v, want within %v", time.Until(deadline), tt.budget)
+			}
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/PharmaKart/gateway-svc/internal/middleware"
+
+// Tracing starts a server span for every request. Downstream gRPC calls made with the request context
+// become children of this span. Requests come from public clients, so an incoming traceparent header
+// is only linked to: every request starts a new trace, sampled by the gateway, and client baggage is
+// not forwarded to the backends.
+func Tracing() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route := c.FullPath()
+		spanName := c.Request.Method + " " + route
+		if route == "" {
+			spanName = c.Request.Method
+		}
+
+		opts := []trace.SpanStartOption{
+			trace.WithNewRoot(),
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("http.request.method", c.Request.Method),
+				attribute.String("http.route", route),
+				attribute.String("url.path", c.Request.URL.Path),
+				attribute.String("client.address", c.ClientIP()),
+				attribute.String("user_agent.original", c.Request.UserAgent()),
+			),
+		}
+		incoming := trace.SpanContextFromContext(propagation.TraceContext{}.Extract(c.Request.Context(), propagation.HeaderCarrier(c.Request.Header)))
+		if incoming.IsValid() {
+			opts = append(opts, trace.WithLinks(trace.Link{SpanContext: incoming}))
+		}
+
+		ctx, span := otel.Tracer(tracerName).Start(c.Request.Context(), spanName, opts...)
+		defer span.End()
+
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+
+		status := c.Writer.Status()
+		span.SetAttributes(attribute.Int("http.response.status_code", status))
+
+		// Set by the auth middleware on protected routes
+		if userID := c.GetString("user_id"); userID != "" {
+			span.SetAttributes(attribute.String("enduser.id", userID))
+		}
+		if role := c.GetString("user_role"); role != "" {
+			span.SetAttributes(attribute.String("enduser.role", role))
+		}
+
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+	}
+}
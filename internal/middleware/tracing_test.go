@@ -0,0 +1,210 @@
+package middleware
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/PharmaKart/gateway-svc/internal/grpc"
+	"github.com/PharmaKart/gateway-svc/internal/proto"
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	googlegrpc "google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	testgrpc "google.golang.org/grpc/interop/grpc_testing"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+var (
+	spanExporterOnce sync.Once
+	spanExporter     *tracetest.InMemoryExporter
+)
+
+// recordSpans installs a global tracer provider recording every span in memory, and clears the spans
+// recorded so far. The global provider can only be installed once, so it is shared by every test.
+func recordSpans(t *testing.T) *tracetest.InMemoryExporter {
+	t.Helper()
+
+	spanExporterOnce.Do(func() {
+		spanExporter = tracetest.NewInMemoryExporter()
+		otel.SetTracerProvider(sdktrace.NewTracerProvider(
+			sdktrace.WithSyncer(spanExporter),
+			sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.AlwaysSample())),
+		))
+		otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	})
+	spanExporter.Reset()
+	return spanExporter
+}
+
+// metadataServer records the metadata of the last EmptyCall it served
+type metadataServer struct {
+	testgrpc.UnimplementedTestServiceServer
+
+	mu sync.Mutex
+	md metadata.MD
+}
+
+func (s *metadataServer) EmptyCall(ctx context.Context, req *testgrpc.Empty) (*testgrpc.Empty, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.md = md
+
+	return &testgrpc.Empty{}, nil
+}
+
+type roleAuthClient struct {
+	grpc.AuthClient
+}
+
+func (roleAuthClient) VerifyToken(ctx context.Context, req *proto.VerifyTokenRequest) (*proto.VerifyTokenResponse, error) {
+	return &proto.VerifyTokenResponse{Success: true, UserId: "user-1", Role: "admin"}, nil
+}
+
+func attributes(span tracetest.SpanStub) map[attribute.Key]attribute.Value {
+	values := make(map[attribute.Key]attribute.Value, len(span.Attributes))
+	for _, kv := range span.Attributes {
+		values[kv.Key] = kv.Value
+	}
+	return values
+}
+
+func TestTracing(t *testing.T) {
+	exporter := recordSpans(t)
+
+	listener := bufconn.Listen(1 << 20)
+	backend := &metadataServer{}
+	server := googlegrpc.NewServer()
+	testgrpc.RegisterTestServiceServer(server, backend)
+	go server.Serve(listener)
+	defer server.Stop()
+
+	conn, err := googlegrpc.NewClient("passthrough:///bufconn",
+		googlegrpc.WithTransportCredentials(insecure.NewCredentials()),
+		googlegrpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTracing("test-svc"),
+	)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+
+	r := gin.New()
+	r.Use(Tracing())
+	r.GET("/orders/:id", AuthMiddleware(roleAuthClient{}), func(c *gin.Context) {
+		if _, err := testgrpc.NewTestServiceClient(conn).EmptyCall(c.Request.Context(), &testgrpc.Empty{}); err != nil {
+			t.Errorf("EmptyCall: %v", err)
+		}
+		c.Status(http.StatusOK)
+	})
+
+	// A public client sending a sampled trace context of its own
+	clientTraceID := "4bf92f3577b34da6a3ce929d0e0e4736"
+	req := httptest.NewRequest(http.MethodGet, "/orders/42", nil)
+	req.Header.Set("Authorization", "Bearer token")
+	req.Header.Set("traceparent", "00-"+clientTraceID+"-00f067aa0ba902b7-01")
+	req.Header.Set("baggage", "tenant=spoofed")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := exporter.GetSpans()
+	var serverSpan, clientSpan *tracetest.SpanStub
+	for i := range spans {
+		switch spans[i].SpanKind {
+		case trace.SpanKindServer:
+			serverSpan = &spans[i]
+		case trace.SpanKindClient:
+			clientSpan = &spans[i]
+		}
+	}
+	if serverSpan == nil || clientSpan == nil {
+		t.Fatalf("recorded %d spans, want a server and a client span", len(spans))
+	}
+
+	t.Run("request is re-rooted", func(t *testing.T) {
+		if serverSpan.Parent.IsValid() {
+			t.Fatalf("server span has parent %v, want a new root", serverSpan.Parent)
+		}
+		if serverSpan.SpanContext.TraceID().String() == clientTraceID {
+			t.Fatal("server span continues the trace of the client")
+		}
+		if len(serverSpan.Links) != 1 || serverSpan.Links[0].SpanContext.TraceID().String() != clientTraceID {
+			t.Fatalf("links = %v, want a link to the client trace", serverSpan.Links)
+		}
+	})
+
+	t.Run("server span attributes", func(t *testing.T) {
+		attrs := attributes(*serverSpan)
+		want := map[attribute.Key]string{
+			"http.request.method": http.MethodGet,
+			"http.route":          "/orders/:id",
+			"url.path":            "/orders/42",
+			"enduser.id":          "user-1",
+			"enduser.role":        "admin",
+		}
+		for key, value := range want {
+			if got := attrs[key].Emit(); got != value {
+				t.Errorf("%s = %q, want %q", key, got, value)
+			}
+		}
+		if got := attrs["http.response.status_code"].AsInt64(); got != http.StatusOK {
+			t.Errorf("http.response.status_code = %d, want %d", got, http.StatusOK)
+		}
+		if serverSpan.Name != "GET /orders/:id" {
+			t.Errorf("name = %q, want %q", serverSpan.Name, "GET /orders/:id")
+		}
+	})
+
+	t.Run("client span attributes", func(t *testing.T) {
+		if clientSpan.Parent.SpanID() != serverSpan.SpanContext.SpanID() {
+			t.Fatal("client span is not a child of the server span")
+		}
+
+		attrs := attributes(*clientSpan)
+		want := map[attribute.Key]string{
+			"rpc.system":   "grpc",
+			"rpc.service":  "grpc.testing.TestService",
+			"rpc.method":   "EmptyCall",
+			"peer.service": "test-svc",
+		}
+		for key, value := range want {
+			if got := attrs[key].Emit(); got != value {
+				t.Errorf("%s = %q, want %q", key, got, value)
+			}
+		}
+	})
+
+	t.Run("trace context is propagated", func(t *testing.T) {
+		backend.mu.Lock()
+		md := backend.md
+		backend.mu.Unlock()
+
+		carrier := propagation.HeaderCarrier{}
+		for _, key := range []string{"traceparent", "baggage"} {
+			for _, value := range md.Get(key) {
+				carrier.Set(key, value)
+			}
+		}
+		ctx := otel.GetTextMapPropagator().Extract(context.Background(), carrier)
+
+		propagated := trace.SpanContextFromContext(ctx)
+		if propagated.TraceID() != serverSpan.SpanContext.TraceID() || propagated.SpanID() != clientSpan.SpanContext.SpanID() {
+			t.Fatalf("backend got trace context %v, want the client span of the gateway trace", propagated)
+		}
+		if len(md.Get("baggage")) > 0 {
+			t.Fatalf("client baggage %v was forwarded", md.Get("baggage"))
+		}
+	})
+}
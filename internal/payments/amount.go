@@ -0,0 +1,34 @@
+package payments
+
+import (
+	"strconv"
+	"strings"
+)
+
+// zeroDecimalCurrencies are charged in whole units, see https://stripe.com/docs/currencies#zero-decimal
+var zeroDecimalCurrencies = map[string]bool{
+	"bif": true, "clp": true, "djf": true, "gnf": true, "jpy": true, "kmf": true, "krw": true, "mga": true,
+	"pyg": true, "rwf": true, "ugx": true, "vnd": true, "vuv": true, "xaf": true, "xof": true, "xpf": true,
+}
+
+// threeDecimalCurrencies are charged in thousandths of a unit
+var threeDecimalCurrencies = map[string]bool{
+	"bhd": true, "jod": true, "kwd": true, "omr": true, "tnd": true,
+}
+
+// fromMinorUnits converts an amount in the smallest unit of currency, e.g. cents, to major units
+func fromMinorUnits(amount int64, currency string) float64 {
+	switch currency = strings.ToLower(currency); {
+	case zeroDecimalCurrencies[currency]:
+		return float64(amount)
+	case threeDecimalCurrencies[currency]:
+		return float64(amount) / 1000
+	default:
+		return float64(amount) / 100
+	}
+}
+
+// parseDecimalAmount parses an amount given as a decimal string in major units, e.g. "19.99"
+func parseDecimalAmount(value string) (float64, error) {
+	return strconv.ParseFloat(value, 64)
+}
@@ -0,0 +1,377 @@
+package payments
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	paypalName = "paypal"
+
+	// maxCertSize bounds the download of a signing certificate
+	maxCertSize = 64 << 10
+
+	// defaultPayPalTolerance is how far the transmission time of a delivery may be from now by default
+	defaultPayPalTolerance = 5 * time.Minute
+)
+
+// paypalProvider handles PayPal webhooks. Deliveries are signed with a PayPal certificate over the
+// transmission ID and time, the webhook ID and a CRC32 of the body.
+type paypalProvider struct {
+	webhookID  string
+	tolerance  time.Duration
+	httpClient *http.Client
+	// roots verify the signing certificates, nil meaning the system roots
+	roots *x509.CertPool
+
+	mu    sync.Mutex
+	certs map[string]*x509.Certificate
+}
+
+// NewPayPalProvider creates the PayPal provider for the webhook registered with webhookID. Deliveries
+// transmitted more than tolerance before or after now are rejected, so that they cannot be replayed.
+func NewPayPalProvider(webhookID string, tolerance time.Duration) Provider {
+	if tolerance <= 0 {
+		tolerance = defaultPayPalTolerance
+	}
+
+	return &paypalProvider{
+		webhookID:  webhookID,
+		tolerance:  tolerance,
+		httpClient: &http.Client{Timeout: 5 * time.Second},
+		certs:      make(map[string]*x509.Certificate),
+	}
+}
+
+func (p *paypalProvider) Name() string {
+	return paypalName
+}
+
+func (p *paypalProvider) VerifyWebhook(ctx context.Context, payload []byte, header http.Header) (Delivery, error) {
+	transmissionID := header.Get("Paypal-Transmission-Id")
+	transmissionTime := header.Get("Paypal-Transmission-Time")
+	certURL := header.Get("Paypal-Cert-Url")
+	authAlgo := header.Get("Paypal-Auth-Algo")
+	signature, err := base64.StdEncoding.DecodeString(header.Get("Paypal-Transmission-Sig"))
+	if err != nil || transmissionID == "" || transmissionTime == "" || len(signature) == 0 {
+		return Delivery{}, fmt.Errorf("%w: missing or malformed transmission headers", ErrInvalidSignature)
+	}
+	if authAlgo != "SHA256withRSA" {
+		return Delivery{}, fmt.Errorf("%w: unsupported algorithm %q", ErrInvalidSignature, authAlgo)
+	}
+
+	transmittedAt, err := time.Parse(time.RFC3339, transmissionTime)
+	if err != nil {
+		return Delivery{}, fmt.Errorf("%w: malformed transmission time %q", ErrInvalidSignature, transmissionTime)
+	}
+	if age := time.Since(transmittedAt); age > p.tolerance || age < -p.tolerance {
+		return Delivery{}, fmt.Errorf("%w: transmission time %s is outside the tolerance of %s", ErrInvalidSignature, transmissionTime, p.tolerance)
+	}
+
+	cert, err := p.certificate(ctx, certURL)
+	if err != nil {
+		return Delivery{}, err
+	}
+	publicKey, ok := cert.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return Delivery{}, fmt.Errorf("%w: certificate does not hold an RSA key", ErrInvalidSignature)
+	}
+
+	message := strings.Join([]string{
+		transmissionID,
+		transmissionTime,
+		p.webhookID,
+		strconv.FormatUint(uint64(crc32.ChecksumIEEE(payload)), 10),
+	}, "|")
+	digest := sha256.Sum256([]byte(message))
+	if err := rsa.VerifyPKCS1v15(publicKey, crypto.SHA256, digest[:], signature); err != nil {
+		return Delivery{}, fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+	}
+
+	var event paypalEvent
+	if err := json.Unmarshal(payload, &event); err != nil || event.ID == "" {
+		return Delivery{}, fmt.Errorf("%w: event has no ID", ErrInvalidSignature)
+	}
+
+	return Delivery{ID: event.ID, Type: event.EventType}, nil
+}
+
+// certificate returns the signing certificate at certURL, which must be served by PayPal over HTTPS.
+// Certificates are cached, since PayPal signs every delivery with the same few certificates.
+func (p *paypalProvider) certificate(ctx context.Context, certURL string) (*x509.Certificate, error) {
+	u, err := url.Parse(certURL)
+	if err != nil || u.Scheme != "https" || !(u.Hostname() == "paypal.com" || strings.HasSuffix(u.Hostname(), ".paypal.com")) {
+		return nil, fmt.Errorf("%w: certificate URL %q is not served by PayPal", ErrInvalidSignature, certURL)
+	}
+
+	p.mu.Lock()
+	cert, ok := p.certs[certURL]
+	p.mu.Unlock()
+	if ok && time.Now().Before(cert.NotAfter) {
+		return cert, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, certURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download PayPal certificate: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download PayPal certificate: status %d", resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxCertSize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to download PayPal certificate: %w", err)
+	}
+
+	cert, err = parseCertificateChain(data, p.roots)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+	}
+
+	p.mu.Lock()
+	p.certs[certURL] = cert
+	p.mu.Unlock()
+
+	return cert, nil
+}
+
+// parseCertificateChain returns the first certificate of a PEM chain, after verifying the chain
+// against roots
+func parseCertificateChain(data []byte, roots *x509.CertPool) (*x509.Certificate, error) {
+	var chain []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, cert)
+	}
+	if len(chain) == 0 {
+		return nil, errors.New("no certificate found")
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range chain[1:] {
+		intermediates.AddCert(cert)
+	}
+	if _, err := chain[0].Verify(x509.VerifyOptions{Roots: roots, Intermediates: intermediates}); err != nil {
+		return nil, err
+	}
+
+	return chain[0], nil
+}
+
+// paypalEvent is the envelope of a PayPal webhook event
+type paypalEvent struct {
+	ID        string          `json:"id"`
+	EventType string          `json:"event_type"`
+	Resource  json.RawMessage `json:"resource"`
+}
+
+type paypalAmount struct {
+	CurrencyCode string `json:"currency_code"`
+	Value        string `json:"value"`
+}
+
+type paypalLink struct {
+	Href string `json:"href"`
+	Rel  string `json:"rel"`
+}
+
+// paypalCapture is the resource of capture events. payment-svc sets the custom ID of the
+// purchase unit to the order ID.
+type paypalCapture struct {
+	ID       string       `json:"id"`
+	Status   string       `json:"status"`
+	Amount   paypalAmount `json:"amount"`
+	CustomID string       `json:"custom_id"`
+	Links    []paypalLink `json:"links"`
+}
+
+// paypalRefund is the resource of refund events
+type paypalRefund struct {
+	ID                     string       `json:"id"`
+	Status                 string       `json:"status"`
+	Amount                 paypalAmount `json:"amount"`
+	CustomID               string       `json:"custom_id"`
+	Links                  []paypalLink `json:"links"`
+	SellerPayableBreakdown struct {
+		TotalRefundedAmount *paypalAmount `json:"total_refunded_amount"`
+	} `json:"seller_payable_breakdown"`
+}
+
+type paypalDispute struct {
+	DisputeID            string       `json:"dispute_id"`
+	Reason               string       `json:"reason"`
+	DisputeAmount        paypalAmount `json:"dispute_amount"`
+	DisputedTransactions []struct {
+		SellerTransactionID string `json:"seller_transaction_id"`
+		Custom              string `json:"custom"`
+	} `json:"disputed_transactions"`
+}
+
+// paypalParsers fill in a normalized event from the PayPal event types that affect payments
+var paypalParsers = map[string]func(event paypalEvent, normalized *Event) error{
+	"PAYMENT.CAPTURE.COMPLETED": parsePayPalCapture(PaymentCompleted),
+	"PAYMENT.CAPTURE.PENDING":   parsePayPalCapture(PaymentPending),
+	"PAYMENT.CAPTURE.DENIED":    parsePayPalCapture(PaymentFailed),
+	"PAYMENT.CAPTURE.REFUNDED":  parsePayPalRefund,
+	"CUSTOMER.DISPUTE.CREATED":  parsePayPalDispute,
+}
+
+func (p *paypalProvider) ParseEvent(payload []byte) (*Event, error) {
+	var event paypalEvent
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, &EventError{Provider: paypalName, Err: fmt.Errorf("%w: %v", ErrMalformedEvent, err)}
+	}
+
+	normalized := &Event{
+		Provider:     paypalName,
+		ID:           event.ID,
+		ProviderType: event.EventType,
+	}
+
+	parse, ok := paypalParsers[event.EventType]
+	if !ok {
+		return normalized, nil
+	}
+	if err := parse(event, normalized); err != nil {
+		return nil, err
+	}
+	return normalized, nil
+}
+
+func paypalEventError(event paypalEvent, field string, err error) *EventError {
+	return &EventError{Provider: paypalName, EventID: event.ID, EventType: event.EventType, Field: field, Err: err}
+}
+
+// decodePayPalResource decodes the resource of an event into v
+func decodePayPalResource(event paypalEvent, v interface{}) error {
+	if len(event.Resource) == 0 {
+		return paypalEventError(event, "resource", ErrMissingField)
+	}
+
+	if err := json.Unmarshal(event.Resource, v); err != nil {
+		return paypalEventError(event, "", fmt.Errorf("%w: %v", ErrMalformedEvent, err))
+	}
+	return nil
+}
+
+// setPayPalAmount fills in the amount of an event, which PayPal gives in major units
+func setPayPalAmount(event paypalEvent, normalized *Event, field string, amount paypalAmount) error {
+	value, err := parseDecimalAmount(amount.Value)
+	if err != nil {
+		return paypalEventError(event, field, fmt.Errorf("%w: %v", ErrMalformedEvent, err))
+	}
+
+	normalized.Amount = value
+	normalized.Currency = strings.ToLower(amount.CurrencyCode)
+	return nil
+}
+
+func parsePayPalCapture(eventType EventType) func(event paypalEvent, normalized *Event) error {
+	return func(event paypalEvent, normalized *Event) error {
+		var capture paypalCapture
+		if err := decodePayPalResource(event, &capture); err != nil {
+			return err
+		}
+		if capture.CustomID == "" {
+			return paypalEventError(event, "resource.custom_id", ErrMissingField)
+		}
+
+		normalized.Type = eventType
+		normalized.OrderID = capture.CustomID
+		normalized.PaymentReference = capture.ID
+		return setPayPalAmount(event, normalized, "resource.amount", capture.Amount)
+	}
+}
+
+// parsePayPalRefund covers full and partial refunds. PayPal does not report the captured amount with a
+// refund, so the refund is recorded as partial with the total refunded so far, and payment-svc's amount of
+// the payment tells whether it has been refunded in full.
+func parsePayPalRefund(event paypalEvent, normalized *Event) error {
+	var refund paypalRefund
+	if err := decodePayPalResource(event, &refund); err != nil {
+		return err
+	}
+
+	normalized.Type = PaymentPartiallyRefunded
+	normalized.OrderID = refund.CustomID
+
+	// The refund links up to the capture it returns
+	for _, link := range refund.Links {
+		if link.Rel == "up" {
+			normalized.PaymentReference = path.Base(link.Href)
+		}
+	}
+	if normalized.OrderID == "" && normalized.PaymentReference == "" {
+		return paypalEventError(event, "resource.custom_id", ErrMissingField)
+	}
+
+	// Older refunds lack the breakdown, and only report their own amount
+	total, field := refund.Amount, "resource.amount"
+	if refund.SellerPayableBreakdown.TotalRefundedAmount != nil {
+		total, field = *refund.SellerPayableBreakdown.TotalRefundedAmount, "resource.seller_payable_breakdown.total_refunded_amount"
+	}
+	value, err := parseDecimalAmount(total.Value)
+	if err != nil {
+		return paypalEventError(event, field, fmt.Errorf("%w: %v", ErrMalformedEvent, err))
+	}
+
+	normalized.RefundedAmount = value
+	normalized.Currency = strings.ToLower(total.CurrencyCode)
+	return nil
+}
+
+func parsePayPalDispute(event paypalEvent, normalized *Event) error {
+	var dispute paypalDispute
+	if err := decodePayPalResource(event, &dispute); err != nil {
+		return err
+	}
+	if len(dispute.DisputedTransactions) == 0 {
+		return paypalEventError(event, "resource.disputed_transactions", ErrMissingField)
+	}
+
+	transaction := dispute.DisputedTransactions[0]
+	if transaction.Custom == "" && transaction.SellerTransactionID == "" {
+		return paypalEventError(event, "resource.disputed_transactions", ErrMissingField)
+	}
+
+	normalized.Type = PaymentDisputed
+	normalized.OrderID = transaction.Custom
+	normalized.PaymentReference = transaction.SellerTransactionID
+	normalized.Reason = dispute.Reason
+	return setPayPalAmount(event, normalized, "resource.dispute_amount", dispute.DisputeAmount)
+}
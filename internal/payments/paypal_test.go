@@ -0,0 +1,445 @@
+package payments
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"hash/crc32"
+	"io"
+	"math/big"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const (
+	testWebhookID = "8PT597110X687430LKGECATA"
+	testCertURL   = "https://api.sandbox.paypal.com/v1/notifications/certs/CERT-360caa42-fca2a594-a5cafa77"
+)
+
+// paypalSigner signs deliveries like PayPal, with a certificate issued by a test root
+type paypalSigner struct {
+	key       *rsa.PrivateKey
+	chain     []byte
+	roots     *x509.CertPool
+	untrusted []byte
+}
+
+func newPayPalSigner(t *testing.T) *paypalSigner {
+	t.Helper()
+
+	root, rootKey := newCertificate(t, "Test Root CA", nil, nil)
+	leaf, leafKey := newCertificate(t, "messageverificationcerts.paypal.com", root, rootKey)
+	other, otherKey := newCertificate(t, "Other Root CA", nil, nil)
+	forged, _ := newCertificate(t, "messageverificationcerts.paypal.com", other, otherKey)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(root)
+	return &paypalSigner{
+		key:       leafKey,
+		chain:     append(encodeCertificate(leaf), encodeCertificate(root)...),
+		roots:     roots,
+		untrusted: encodeCertificate(forged),
+	}
+}
+
+// newCertificate issues a certificate for name, self-signed when parent is nil
+func newCertificate(t *testing.T, name string, parent *x509.Certificate, parentKey *rsa.PrivateKey) (*x509.Certificate, *rsa.PrivateKey) {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+		BasicConstraintsValid: true,
+		IsCA:                  parent == nil,
+	}
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatalf("create certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("parse certificate: %v", err)
+	}
+	return cert, key
+}
+
+func encodeCertificate(cert *x509.Certificate) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+}
+
+// headers signs payload for webhookID as transmitted at transmittedAt
+func (s *paypalSigner) headers(t *testing.T, payload []byte, webhookID string, transmittedAt time.Time) http.Header {
+	t.Helper()
+
+	transmissionID := "69cd13f0-d67a-11e5-baa3-778b53f4ae55"
+	transmissionTime := transmittedAt.UTC().Format(time.RFC3339)
+	message := strings.Join([]string{
+		transmissionID,
+		transmissionTime,
+		webhookID,
+		strconv.FormatUint(uint64(crc32.ChecksumIEEE(payload)), 10),
+	}, "|")
+	digest := sha256.Sum256([]byte(message))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, s.key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+
+	header := http.Header{}
+	header.Set("Paypal-Transmission-Id", transmissionID)
+	header.Set("Paypal-Transmission-Time", transmissionTime)
+	header.Set("Paypal-Transmission-Sig", base64.StdEncoding.EncodeToString(signature))
+	header.Set("Paypal-Cert-Url", testCertURL)
+	header.Set("Paypal-Auth-Algo", "SHA256withRSA")
+	return header
+}
+
+// certServer serves a certificate chain for every URL and counts the downloads
+type certServer struct {
+	chain     []byte
+	downloads atomic.Int32
+}
+
+func (s *certServer) RoundTrip(req *http.Request) (*http.Response, error) {
+	s.downloads.Add(1)
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Body:       io.NopCloser(bytes.NewReader(s.chain)),
+		Request:    req,
+	}, nil
+}
+
+func newTestPayPalProvider(signer *paypalSigner, chain []byte) (*paypalProvider, *certServer) {
+	server := &certServer{chain: chain}
+	provider := NewPayPalProvider(testWebhookID, 5*time.Minute).(*paypalProvider)
+	provider.httpClient = &http.Client{Transport: server}
+	provider.roots = signer.roots
+	return provider, server
+}
+
+func TestPayPalVerifyWebhook(t *testing.T) {
+	signer := newPayPalSigner(t)
+	payload := readPayload(t, paypalName, "payment_capture_completed")
+
+	tests := []struct {
+		name    string
+		header  func() http.Header
+		payload []byte
+		chain   []byte
+		wantErr bool
+	}{
+		{
+			name:   "valid",
+			header: func() http.Header { return signer.headers(t, payload, testWebhookID, time.Now()) },
+		},
+		{
+			name:   "transmitted within the tolerance",
+			header: func() http.Header { return signer.headers(t, payload, testWebhookID, time.Now().Add(-4*time.Minute)) },
+		},
+		{
+			name:    "tampered payload",
+			header:  func() http.Header { return signer.headers(t, payload, testWebhookID, time.Now()) },
+			payload: bytes.Replace(payload, []byte(`"50.97"`), []byte(`"0.97"`), 1),
+			wantErr: true,
+		},
+		{
+			name:    "signed for another webhook",
+			header:  func() http.Header { return signer.headers(t, payload, "1JE4291016473214C", time.Now()) },
+			wantErr: true,
+		},
+		{
+			name:    "replayed after the tolerance",
+			header:  func() http.Header { return signer.headers(t, payload, testWebhookID, time.Now().Add(-6*time.Minute)) },
+			wantErr: true,
+		},
+		{
+			name:    "transmitted in the future",
+			header:  func() http.Header { return signer.headers(t, payload, testWebhookID, time.Now().Add(6*time.Minute)) },
+			wantErr: true,
+		},
+		{
+			name: "malformed transmission time",
+			header: func() http.Header {
+				header := signer.headers(t, payload, testWebhookID, time.Now())
+				header.Set("Paypal-Transmission-Time", time.Now().Format(time.RFC1123))
+				return header
+			},
+			wantErr: true,
+		},
+		{
+			name: "certificate not served by PayPal",
+			header: func() http.Header {
+				header := signer.headers(t, payload, testWebhookID, time.Now())
+				header.Set("Paypal-Cert-Url", "https://paypal.com.example.net/certs/CERT-1")
+				return header
+			},
+			wantErr: true,
+		},
+		{
+			name: "certificate over HTTP",
+			header: func() http.Header {
+				header := signer.headers(t, payload, testWebhookID, time.Now())
+				header.Set("Paypal-Cert-Url", strings.Replace(testCertURL, "https://", "http://", 1))
+				return header
+			},
+			wantErr: true,
+		},
+		{
+			name:    "untrusted certificate",
+			header:  func() http.Header { return signer.headers(t, payload, testWebhookID, time.Now()) },
+			chain:   signer.untrusted,
+			wantErr: true,
+		},
+		{
+			name: "unsupported algorithm",
+			header: func() http.Header {
+				header := signer.headers(t, payload, testWebhookID, time.Now())
+				header.Set("Paypal-Auth-Algo", "SHA1withRSA")
+				return header
+			},
+			wantErr: true,
+		},
+		{
+			name: "missing signature",
+			header: func() http.Header {
+				header := signer.headers(t, payload, testWebhookID, time.Now())
+				header.Del("Paypal-Transmission-Sig")
+				return header
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chain := signer.chain
+			if tt.chain != nil {
+				chain = tt.chain
+			}
+			provider, _ := newTestPayPalProvider(signer, chain)
+
+			body := payload
+			if tt.payload != nil {
+				body = tt.payload
+			}
+			delivery, err := provider.VerifyWebhook(context.Background(), body, tt.header())
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidSignature) {
+					t.Fatalf("VerifyWebhook error = %v, want %v", err, ErrInvalidSignature)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("VerifyWebhook: %v", err)
+			}
+			want := Delivery{ID: "WH-2WR32451HC0233532-67976317FL4543714", Type: "PAYMENT.CAPTURE.COMPLETED"}
+			if delivery != want {
+				t.Fatalf("delivery = %+v, want %+v", delivery, want)
+			}
+		})
+	}
+}
+
+func TestPayPalCachesCertificates(t *testing.T) {
+	signer := newPayPalSigner(t)
+	provider, server := newTestPayPalProvider(signer, signer.chain)
+
+	for _, name := range []string{"payment_capture_completed", "payment_capture_refunded_partial", "customer_dispute_created"} {
+		payload := readPayload(t, paypalName, name)
+		if _, err := provider.VerifyWebhook(context.Background(), payload, signer.headers(t, payload, testWebhookID, time.Now())); err != nil {
+			t.Fatalf("VerifyWebhook %s: %v", name, err)
+		}
+	}
+
+	if downloads := server.downloads.Load(); downloads != 1 {
+		t.Fatalf("downloaded the certificate %d times, want once", downloads)
+	}
+}
+
+func TestPayPalParseEvent(t *testing.T) {
+	tests := []struct {
+		payload string
+		want    Event
+	}{
+		{
+			payload: "payment_capture_completed",
+			want: Event{
+				ID:               "WH-2WR32451HC0233532-67976317FL4543714",
+				ProviderType:     "PAYMENT.CAPTURE.COMPLETED",
+				Type:             PaymentCompleted,
+				OrderID:          "order-42",
+				PaymentReference: "42311647XV020574X",
+				Amount:           50.97,
+				Currency:         "cad",
+			},
+		},
+		{
+			payload: "payment_capture_denied",
+			want: Event{
+				ID:               "WH-4SW78779LY2325805-07E03580SX1414828",
+				ProviderType:     "PAYMENT.CAPTURE.DENIED",
+				Type:             PaymentFailed,
+				OrderID:          "order-43",
+				PaymentReference: "7NW873794T343360M",
+				Amount:           24.99,
+				Currency:         "aud",
+			},
+		},
+		{
+			payload: "payment_capture_refunded_partial",
+			want: Event{
+				ID:               "WH-1GE84257G0350133W-6RW800890C634293G",
+				ProviderType:     "PAYMENT.CAPTURE.REFUNDED",
+				Type:             PaymentPartiallyRefunded,
+				OrderID:          "order-42",
+				PaymentReference: "42311647XV020574X",
+				RefundedAmount:   19.99,
+				Currency:         "cad",
+			},
+		},
+		{
+			// The second refund reports the total refunded, not its own amount
+			payload: "payment_capture_refunded_remaining",
+			want: Event{
+				ID:               "WH-7YX49823S2290830K-0JE13296W68552352",
+				ProviderType:     "PAYMENT.CAPTURE.REFUNDED",
+				Type:             PaymentPartiallyRefunded,
+				PaymentReference: "42311647XV020574X",
+				RefundedAmount:   50.97,
+				Currency:         "cad",
+			},
+		},
+		{
+			payload: "payment_capture_refunded_without_breakdown",
+			want: Event{
+				ID:               "WH-5HX61023PL7722430-3WB39815FE1146221",
+				ProviderType:     "PAYMENT.CAPTURE.REFUNDED",
+				Type:             PaymentPartiallyRefunded,
+				OrderID:          "order-47",
+				PaymentReference: "9XK24715CV8411238",
+				RefundedAmount:   10,
+				Currency:         "usd",
+			},
+		},
+		{
+			payload: "customer_dispute_created",
+			want: Event{
+				ID:               "WH-4M0448861G563140B-9EX36365822141321",
+				ProviderType:     "CUSTOMER.DISPUTE.CREATED",
+				Type:             PaymentDisputed,
+				OrderID:          "order-42",
+				PaymentReference: "42311647XV020574X",
+				Amount:           50.97,
+				Currency:         "cad",
+				Reason:           "MERCHANDISE_OR_SERVICE_NOT_RECEIVED",
+			},
+		},
+		{
+			payload: "checkout_order_approved",
+			want: Event{
+				ID:           "WH-1AB23456CD7890123-4EF56789GH0123456",
+				ProviderType: "CHECKOUT.ORDER.APPROVED",
+				Type:         Ignored,
+			},
+		},
+	}
+
+	provider := NewPayPalProvider(testWebhookID, 0)
+	for _, tt := range tests {
+		t.Run(tt.payload, func(t *testing.T) {
+			event, err := provider.ParseEvent(readPayload(t, paypalName, tt.payload))
+			if err != nil {
+				t.Fatalf("ParseEvent: %v", err)
+			}
+
+			tt.want.Provider = paypalName
+			if *event != tt.want {
+				t.Fatalf("event = %+v, want %+v", *event, tt.want)
+			}
+		})
+	}
+}
+
+func TestPayPalParseEventErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		payload   []byte
+		wantErr   error
+		wantField string
+	}{
+		{
+			name:      "capture without order",
+			payload:   readPayload(t, paypalName, "payment_capture_completed_without_order"),
+			wantErr:   ErrMissingField,
+			wantField: "resource.custom_id",
+		},
+		{
+			name:      "refund without order or capture",
+			payload:   []byte(`{"id":"WH-1","event_type":"PAYMENT.CAPTURE.REFUNDED","resource":{"id":"1JU08902781691411","amount":{"currency_code":"USD","value":"1.00"}}}`),
+			wantErr:   ErrMissingField,
+			wantField: "resource.custom_id",
+		},
+		{
+			name:      "malformed refunded total",
+			payload:   []byte(`{"id":"WH-1","event_type":"PAYMENT.CAPTURE.REFUNDED","resource":{"custom_id":"order-1","seller_payable_breakdown":{"total_refunded_amount":{"currency_code":"USD","value":"1,00"}}}}`),
+			wantErr:   ErrMalformedEvent,
+			wantField: "resource.seller_payable_breakdown.total_refunded_amount",
+		},
+		{
+			name:      "dispute without transactions",
+			payload:   []byte(`{"id":"WH-1","event_type":"CUSTOMER.DISPUTE.CREATED","resource":{"dispute_id":"PP-D-1","dispute_amount":{"currency_code":"USD","value":"1.00"}}}`),
+			wantErr:   ErrMissingField,
+			wantField: "resource.disputed_transactions",
+		},
+		{
+			name:      "missing resource",
+			payload:   []byte(`{"id":"WH-1","event_type":"PAYMENT.CAPTURE.COMPLETED"}`),
+			wantErr:   ErrMissingField,
+			wantField: "resource",
+		},
+	}
+
+	provider := NewPayPalProvider(testWebhookID, 0)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			event, err := provider.ParseEvent(tt.payload)
+			if err == nil {
+				t.Fatalf("ParseEvent = %+v, want an error", event)
+			}
+
+			var eventErr *EventError
+			if !errors.As(err, &eventErr) || !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, want an *EventError wrapping %v", err, tt.wantErr)
+			}
+			if eventErr.Provider != paypalName || eventErr.Field != tt.wantField {
+				t.Fatalf("error = %+v, want field %q", eventErr, tt.wantField)
+			}
+		})
+	}
+}
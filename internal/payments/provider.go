@@ -0,0 +1,136 @@
+// Package payments verifies the webhooks of payment processors and normalizes their events, so that
+// handlers apply payment updates the same way whichever processor sent them.
+package payments
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/PharmaKart/gateway-svc/pkg/config"
+)
+
+var (
+	// ErrInvalidSignature is returned for deliveries that were not signed by the provider
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+	// ErrMalformedEvent is returned for events whose object cannot be decoded
+	ErrMalformedEvent = errors.New("malformed event object")
+	// ErrMissingField is returned for events without data needed to apply them
+	ErrMissingField = errors.New("missing required field")
+)
+
+// EventError reports an event that cannot be applied because its data is malformed or incomplete.
+// Retrying such an event cannot succeed.
+type EventError struct {
+	Provider  string
+	EventID   string
+	EventType string
+	Field     string
+	Err       error
+}
+
+func (e *EventError) Error() string {
+	if e.Field != "" {
+		return fmt.Sprintf("%s event %s (%s): %s: %v", e.Provider, e.EventID, e.EventType, e.Field, e.Err)
+	}
+	return fmt.Sprintf("%s event %s (%s): %v", e.Provider, e.EventID, e.EventType, e.Err)
+}
+
+func (e *EventError) Unwrap() error {
+	return e.Err
+}
+
+// EventType is the provider-neutral kind of a payment event
+type EventType string
+
+const (
+	// Ignored events do not change any payment
+	Ignored EventType = ""
+	// PaymentCompleted means the money has been collected
+	PaymentCompleted EventType = "payment_completed"
+	// PaymentPending means the customer has paid with a method that settles later
+	PaymentPending EventType = "payment_pending"
+	// PaymentCaptured reports a successful charge, along with its receipt
+	PaymentCaptured EventType = "payment_captured"
+	// PaymentFailed means a payment attempt was declined or could not be settled
+	PaymentFailed EventType = "payment_failed"
+	// PaymentExpired means the customer did not pay before the checkout expired
+	PaymentExpired EventType = "payment_expired"
+	// PaymentRefunded means the whole payment has been returned
+	PaymentRefunded EventType = "payment_refunded"
+	// PaymentPartiallyRefunded means part of the payment has been returned
+	PaymentPartiallyRefunded EventType = "payment_partially_refunded"
+	// PaymentDisputed means the customer has disputed the payment with their bank
+	PaymentDisputed EventType = "payment_disputed"
+)
+
+// Event is a verified payment event, normalized from the provider's format
+type Event struct {
+	Provider string
+	ID       string
+	// ProviderType is the event type used by the provider, e.g. charge.refunded
+	ProviderType string
+	Type         EventType
+
+	// OrderID and CustomerID are set when the provider carries them. Otherwise payment-svc finds the
+	// payment by PaymentReference, the provider's ID of the payment, e.g. a Stripe payment intent.
+	OrderID          string
+	CustomerID       string
+	PaymentReference string
+
+	// Amount is in major units of Currency, e.g. dollars. It is zero for refunds that do not carry the
+	// amount of the payment.
+	Amount float64
+	// RefundedAmount is the total refunded so far, for refund events
+	RefundedAmount float64
+	Currency       string
+	ReceiptURL     string
+	// Reason explains failures and disputes, when the provider gives one
+	Reason string
+}
+
+// Delivery identifies the event carried by a verified webhook delivery
+type Delivery struct {
+	ID   string
+	Type string
+}
+
+// Provider verifies and normalizes the webhook events of a payment processor
+type Provider interface {
+	// Name identifies the provider in webhook URLs, queued events and metrics
+	Name() string
+	// VerifyWebhook checks that a delivery was sent by the provider. Deliveries with a bad signature
+	// fail with ErrInvalidSignature; other errors are transient.
+	VerifyWebhook(ctx context.Context, payload []byte, header http.Header) (Delivery, error)
+	// ParseEvent normalizes a verified payload. Events that cannot be applied fail with an *EventError.
+	ParseEvent(payload []byte) (*Event, error)
+}
+
+// Providers are the enabled payment providers, by name
+type Providers map[string]Provider
+
+// NewProviders creates the providers enabled by the configuration. Stripe is always enabled.
+func NewProviders(cfg *config.Config) Providers {
+	providers := Providers{}
+	providers.add(NewStripeProvider(cfg.StripeWebhookSecret, cfg.StripeWebhookPreviousSecrets, cfg.StripeWebhookTolerance))
+	if cfg.PayPalWebhookID != "" {
+		providers.add(NewPayPalProvider(cfg.PayPalWebhookID, cfg.PayPalWebhookTolerance))
+	}
+	return providers
+}
+
+func (p Providers) add(provider Provider) {
+	p[provider.Name()] = provider
+}
+
+// Names returns the names of the providers in alphabetical order
+func (p Providers) Names() []string {
+	names := make([]string, 0, len(p))
+	for name := range p {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,312 @@
+package payments
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/PharmaKart/gateway-svc/pkg/metrics"
+	"github.com/PharmaKart/gateway-svc/pkg/utils"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stripe/stripe-go"
+	"github.com/stripe/stripe-go/webhook"
+)
+
+const stripeName = "stripe"
+
+var stripeSignatures = metrics.Factory.NewCounterVec(prometheus.CounterOpts{
+	Name: "gateway_stripe_webhook_signatures_total",
+	Help: "Stripe webhook deliveries by the endpoint secret that validated their signature, or none.",
+}, []string{"secret"})
+
+// stripeProvider handles Stripe webhooks, signed with the endpoint secret. Previous secrets are
+// accepted too, so that deliveries signed before a rotation reaches Stripe are not rejected.
+type stripeProvider struct {
+	secrets   []string
+	tolerance time.Duration
+}
+
+// NewStripeProvider creates the Stripe provider. Signatures are checked against the current secret
+// first, then the previous ones, and rejected when they are older than tolerance.
+func NewStripeProvider(current string, previous []string, tolerance time.Duration) Provider {
+	if tolerance <= 0 {
+		tolerance = webhook.DefaultTolerance
+	}
+
+	return &stripeProvider{
+		secrets:   append([]string{current}, previous...),
+		tolerance: tolerance,
+	}
+}
+
+func (p *stripeProvider) Name() string {
+	return stripeName
+}
+
+func (p *stripeProvider) VerifyWebhook(ctx context.Context, payload []byte, header http.Header) (Delivery, error) {
+	signature := header.Get("Stripe-Signature")
+
+	var err error
+	for i, secret := range p.secrets {
+		var event stripe.Event
+		event, err = webhook.ConstructEventWithTolerance(payload, signature, secret, p.tolerance)
+		if err == nil {
+			stripeSignatures.WithLabelValues(secretLabel(i)).Inc()
+			if i > 0 {
+				// Stripe still signs with a secret being rotated out, which must not be removed yet
+				utils.WarnContext(ctx, "Stripe webhook validated by a previous secret", map[string]interface{}{
+					"event_id":     event.ID,
+					"validated_by": secretLabel(i),
+				})
+			}
+			return Delivery{ID: event.ID, Type: event.Type}, nil
+		}
+
+		// Other failures, such as an expired timestamp, do not depend on the secret
+		if !errors.Is(err, webhook.ErrNoValidSignature) {
+			break
+		}
+	}
+
+	stripeSignatures.WithLabelValues("none").Inc()
+	return Delivery{}, fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+}
+
+// secretLabel names a secret by its position, so that metrics never expose the secret itself
+func secretLabel(i int) string {
+	if i == 0 {
+		return "current"
+	}
+	return "previous_" + strconv.Itoa(i)
+}
+
+// stripeParsers fill in a normalized event from the Stripe event types that affect payments
+var stripeParsers = map[string]func(event stripe.Event, normalized *Event) error{
+	"checkout.session.completed":               parseCheckoutSessionCompleted,
+	"checkout.session.async_payment_succeeded": parseCheckoutSession(PaymentCompleted),
+	"checkout.session.async_payment_failed":    parseCheckoutSession(PaymentFailed),
+	"checkout.session.expired":                 parseCheckoutSession(PaymentExpired),
+	"charge.succeeded":                         parseChargeSucceeded,
+	"charge.refunded":                          parseChargeRefunded,
+	"charge.dispute.created":                   parseDisputeCreated,
+	"payment_intent.payment_failed":            parsePaymentIntentFailed,
+}
+
+func (p *stripeProvider) ParseEvent(payload []byte) (*Event, error) {
+	var event stripe.Event
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, &EventError{Provider: stripeName, Err: fmt.Errorf("%w: %v", ErrMalformedEvent, err)}
+	}
+
+	normalized := &Event{
+		Provider:     stripeName,
+		ID:           event.ID,
+		ProviderType: event.Type,
+	}
+
+	parse, ok := stripeParsers[event.Type]
+	if !ok {
+		return normalized, nil
+	}
+	if err := parse(event, normalized); err != nil {
+		return nil, err
+	}
+	return normalized, nil
+}
+
+// checkoutSession adds the fields that the pinned stripe-go version does not decode
+type checkoutSession struct {
+	stripe.CheckoutSession
+	AmountTotal   int64           `json:"amount_total"`
+	Currency      stripe.Currency `json:"currency"`
+	PaymentStatus string          `json:"payment_status"`
+	Status        string          `json:"status"`
+}
+
+// UnmarshalJSON decodes both parts separately, since stripe.CheckoutSession has its own UnmarshalJSON
+func (s *checkoutSession) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &s.CheckoutSession); err != nil {
+		return err
+	}
+
+	var extra struct {
+		AmountTotal   int64           `json:"amount_total"`
+		Currency      stripe.Currency `json:"currency"`
+		PaymentStatus string          `json:"payment_status"`
+		Status        string          `json:"status"`
+	}
+	if err := json.Unmarshal(data, &extra); err != nil {
+		return err
+	}
+
+	s.AmountTotal = extra.AmountTotal
+	s.Currency = extra.Currency
+	s.PaymentStatus = extra.PaymentStatus
+	s.Status = extra.Status
+	return nil
+}
+
+// paymentIntentID returns the ID of the payment intent of the session, if it has one yet
+func (s *checkoutSession) paymentIntentID() string {
+	if s.PaymentIntent == nil {
+		return ""
+	}
+	return s.PaymentIntent.ID
+}
+
+// decodeStripeObject decodes the object of an event into v
+func decodeStripeObject(event stripe.Event, v interface{}) error {
+	if event.Data == nil || len(event.Data.Raw) == 0 {
+		return stripeEventError(event, "data.object", ErrMissingField)
+	}
+
+	if err := json.Unmarshal(event.Data.Raw, v); err != nil {
+		return stripeEventError(event, "", fmt.Errorf("%w: %v", ErrMalformedEvent, err))
+	}
+	return nil
+}
+
+func stripeEventError(event stripe.Event, field string, err error) *EventError {
+	return &EventError{Provider: stripeName, EventID: event.ID, EventType: event.Type, Field: field, Err: err}
+}
+
+// requirePaymentReference checks that payment-svc can find the payment of an event, either by the
+// order ID in its metadata or by its payment intent
+func requirePaymentReference(event stripe.Event, normalized *Event) error {
+	if normalized.OrderID == "" && normalized.PaymentReference == "" {
+		return stripeEventError(event, "payment_intent", ErrMissingField)
+	}
+	return nil
+}
+
+// decodeCheckoutSession fills in the order, customer and amount of a checkout session event. The
+// order and customer IDs are attached to the session by payment-svc, and are required.
+func decodeCheckoutSession(event stripe.Event, normalized *Event) (*checkoutSession, error) {
+	var session checkoutSession
+	if err := decodeStripeObject(event, &session); err != nil {
+		return nil, err
+	}
+
+	normalized.OrderID = session.Metadata["order_id"]
+	if normalized.OrderID == "" {
+		normalized.OrderID = session.ClientReferenceID
+	}
+	if normalized.OrderID == "" {
+		return nil, stripeEventError(event, "metadata.order_id", ErrMissingField)
+	}
+
+	normalized.CustomerID = session.Metadata["customer_id"]
+	if normalized.CustomerID == "" {
+		return nil, stripeEventError(event, "metadata.customer_id", ErrMissingField)
+	}
+
+	// The payment intent links the later charge, refund and dispute events to this order
+	normalized.PaymentReference = session.paymentIntentID()
+	normalized.Amount = fromMinorUnits(session.AmountTotal, string(session.Currency))
+	normalized.Currency = string(session.Currency)
+	return &session, nil
+}
+
+func parseCheckoutSession(eventType EventType) func(event stripe.Event, normalized *Event) error {
+	return func(event stripe.Event, normalized *Event) error {
+		normalized.Type = eventType
+		_, err := decodeCheckoutSession(event, normalized)
+		return err
+	}
+}
+
+func parseCheckoutSessionCompleted(event stripe.Event, normalized *Event) error {
+	session, err := decodeCheckoutSession(event, normalized)
+	if err != nil {
+		return err
+	}
+
+	// Delayed payment methods complete the session unpaid, and are settled by an async payment event
+	normalized.Type = PaymentPending
+	if session.PaymentStatus == "paid" {
+		normalized.Type = PaymentCompleted
+	}
+	return nil
+}
+
+// decodeCharge fills in the order, customer and amount of a charge event
+func decodeCharge(event stripe.Event, normalized *Event) (*stripe.Charge, error) {
+	var charge stripe.Charge
+	if err := decodeStripeObject(event, &charge); err != nil {
+		return nil, err
+	}
+
+	normalized.OrderID = charge.Metadata["order_id"]
+	normalized.CustomerID = charge.Metadata["customer_id"]
+	normalized.PaymentReference = charge.PaymentIntent
+	normalized.Amount = fromMinorUnits(charge.Amount, string(charge.Currency))
+	normalized.Currency = string(charge.Currency)
+	normalized.ReceiptURL = charge.ReceiptURL
+	return &charge, requirePaymentReference(event, normalized)
+}
+
+func parseChargeSucceeded(event stripe.Event, normalized *Event) error {
+	normalized.Type = PaymentCaptured
+	_, err := decodeCharge(event, normalized)
+	return err
+}
+
+// parseChargeRefunded covers full and partial refunds, including refunds issued from the Stripe dashboard
+func parseChargeRefunded(event stripe.Event, normalized *Event) error {
+	charge, err := decodeCharge(event, normalized)
+	if err != nil {
+		return err
+	}
+
+	normalized.RefundedAmount = fromMinorUnits(charge.AmountRefunded, string(charge.Currency))
+	normalized.Type = PaymentPartiallyRefunded
+	if charge.Refunded || charge.AmountRefunded >= charge.Amount {
+		normalized.Type = PaymentRefunded
+	}
+	return nil
+}
+
+func parseDisputeCreated(event stripe.Event, normalized *Event) error {
+	var dispute stripe.Dispute
+	if err := decodeStripeObject(event, &dispute); err != nil {
+		return err
+	}
+
+	normalized.Type = PaymentDisputed
+	normalized.OrderID = dispute.Metadata["order_id"]
+	normalized.CustomerID = dispute.Metadata["customer_id"]
+	if dispute.PaymentIntent != nil {
+		normalized.PaymentReference = dispute.PaymentIntent.ID
+	}
+	normalized.Amount = fromMinorUnits(dispute.Amount, string(dispute.Currency))
+	normalized.Currency = string(dispute.Currency)
+	normalized.Reason = string(dispute.Reason)
+	return requirePaymentReference(event, normalized)
+}
+
+// parsePaymentIntentFailed covers failed payment attempts. A customer who retries in the same checkout
+// session moves the order to paid again when the session completes.
+func parsePaymentIntentFailed(event stripe.Event, normalized *Event) error {
+	var intent stripe.PaymentIntent
+	if err := decodeStripeObject(event, &intent); err != nil {
+		return err
+	}
+	if intent.ID == "" {
+		return stripeEventError(event, "id", ErrMissingField)
+	}
+
+	normalized.Type = PaymentFailed
+	normalized.OrderID = intent.Metadata["order_id"]
+	normalized.CustomerID = intent.Metadata["customer_id"]
+	normalized.PaymentReference = intent.ID
+	normalized.Amount = fromMinorUnits(intent.Amount, intent.Currency)
+	normalized.Currency = intent.Currency
+	if intent.LastPaymentError != nil {
+		normalized.Reason = intent.LastPaymentError.Msg
+	}
+	return nil
+}
@@ -0,0 +1,422 @@
+package payments
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/PharmaKart/gateway-svc/pkg/config"
+	"github.com/PharmaKart/gateway-svc/pkg/utils"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stripe/stripe-go/webhook"
+)
+
+func TestMain(m *testing.M) {
+	utils.InitLogger(&config.Config{LogLevel: "panic"})
+	os.Exit(m.Run())
+}
+
+// readPayload returns a recorded webhook payload from testdata
+func readPayload(t *testing.T, provider, name string) []byte {
+	t.Helper()
+
+	payload, err := os.ReadFile(filepath.Join("testdata", provider, name+".json"))
+	if err != nil {
+		t.Fatalf("read payload: %v", err)
+	}
+	return payload
+}
+
+// stripeHeader signs payload with secret like Stripe, as sent at signedAt
+func stripeHeader(payload []byte, secret string, signedAt time.Time) http.Header {
+	signature := webhook.ComputeSignature(signedAt, payload, secret)
+
+	header := http.Header{}
+	header.Set("Stripe-Signature", fmt.Sprintf("t=%d,v1=%s", signedAt.Unix(), hex.EncodeToString(signature)))
+	return header
+}
+
+func TestStripeVerifyWebhook(t *testing.T) {
+	const secret = "whsec_test_current"
+	payload := readPayload(t, stripeName, "charge_refunded_partial")
+
+	tests := []struct {
+		name    string
+		payload []byte
+		header  http.Header
+		wantErr bool
+	}{
+		{
+			name:   "valid",
+			header: stripeHeader(payload, secret, time.Now()),
+		},
+		{
+			name:    "tampered payload",
+			payload: bytes.Replace(payload, []byte(`"amount_refunded": 1999`), []byte(`"amount_refunded": 5097`), 1),
+			header:  stripeHeader(payload, secret, time.Now()),
+			wantErr: true,
+		},
+		{
+			name:    "signed with another secret",
+			header:  stripeHeader(payload, "whsec_test_other", time.Now()),
+			wantErr: true,
+		},
+		{
+			name:    "replayed after the tolerance",
+			header:  stripeHeader(payload, secret, time.Now().Add(-6*time.Minute)),
+			wantErr: true,
+		},
+		{
+			name:    "missing signature",
+			header:  http.Header{},
+			wantErr: true,
+		},
+	}
+
+	provider := NewStripeProvider(secret, nil, 5*time.Minute)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := payload
+			if tt.payload != nil {
+				body = tt.payload
+			}
+
+			delivery, err := provider.VerifyWebhook(context.Background(), body, tt.header)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidSignature) {
+					t.Fatalf("VerifyWebhook error = %v, want %v", err, ErrInvalidSignature)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("VerifyWebhook: %v", err)
+			}
+			want := Delivery{ID: "evt_3PcKxQL5mTz3fQ0a1Rq8vNe5", Type: "charge.refunded"}
+			if delivery != want {
+				t.Fatalf("delivery = %+v, want %+v", delivery, want)
+			}
+		})
+	}
+}
+
+func TestStripeParseEvent(t *testing.T) {
+	tests := []struct {
+		payload string
+		want    Event
+	}{
+		{
+			payload: "checkout_session_completed",
+			want: Event{
+				ID:               "evt_1PcKxSL5mTz3fQ0aHcK7dJqe",
+				ProviderType:     "checkout.session.completed",
+				Type:             PaymentCompleted,
+				OrderID:          "order-42",
+				CustomerID:       "customer-7",
+				PaymentReference: "pi_3PcKxQL5mTz3fQ0a1gV2Y8nR",
+				Amount:           50.97,
+				Currency:         "cad",
+			},
+		},
+		{
+			payload: "checkout_session_completed_unpaid",
+			want: Event{
+				ID:               "evt_1PcL2aL5mTz3fQ0aVb0qKs8m",
+				ProviderType:     "checkout.session.completed",
+				Type:             PaymentPending,
+				OrderID:          "order-43",
+				CustomerID:       "customer-8",
+				PaymentReference: "pi_3PcL2YL5mTz3fQ0a0hJ4Wc1e",
+				Amount:           24.99,
+				Currency:         "usd",
+			},
+		},
+		{
+			payload: "checkout_session_async_payment_failed",
+			want: Event{
+				ID:               "evt_1PcNf3L5mTz3fQ0aYt6Lp2Qw",
+				ProviderType:     "checkout.session.async_payment_failed",
+				Type:             PaymentFailed,
+				OrderID:          "order-43",
+				CustomerID:       "customer-8",
+				PaymentReference: "pi_3PcL2YL5mTz3fQ0a0hJ4Wc1e",
+				Amount:           24.99,
+				Currency:         "usd",
+			},
+		},
+		{
+			payload: "checkout_session_expired",
+			want: Event{
+				ID:           "evt_1PcPa1L5mTz3fQ0aJ3mBv7Xs",
+				ProviderType: "checkout.session.expired",
+				Type:         PaymentExpired,
+				OrderID:      "order-44",
+				CustomerID:   "customer-9",
+				Amount:       120000,
+				Currency:     "jpy",
+			},
+		},
+		{
+			payload: "charge_succeeded",
+			want: Event{
+				ID:               "evt_3PcKxQL5mTz3fQ0a1Lz0aHd2",
+				ProviderType:     "charge.succeeded",
+				Type:             PaymentCaptured,
+				PaymentReference: "pi_3PcKxQL5mTz3fQ0a1gV2Y8nR",
+				Amount:           50.97,
+				Currency:         "cad",
+				ReceiptURL:       "https://pay.stripe.com/receipts/payment/CAcaFwoVYWNjdF8xTHFpd3g",
+			},
+		},
+		{
+			payload: "charge_refunded_partial",
+			want: Event{
+				ID:               "evt_3PcKxQL5mTz3fQ0a1Rq8vNe5",
+				ProviderType:     "charge.refunded",
+				Type:             PaymentPartiallyRefunded,
+				OrderID:          "order-42",
+				CustomerID:       "customer-7",
+				PaymentReference: "pi_3PcKxQL5mTz3fQ0a1gV2Y8nR",
+				Amount:           50.97,
+				RefundedAmount:   19.99,
+				Currency:         "cad",
+				ReceiptURL:       "https://pay.stripe.com/receipts/payment/CAcaFwoVYWNjdF8xTHFpd3g",
+			},
+		},
+		{
+			payload: "charge_refunded_full",
+			want: Event{
+				ID:               "evt_3PcKxQL5mTz3fQ0a1Ub2mYc9",
+				ProviderType:     "charge.refunded",
+				Type:             PaymentRefunded,
+				OrderID:          "order-42",
+				CustomerID:       "customer-7",
+				PaymentReference: "pi_3PcKxQL5mTz3fQ0a1gV2Y8nR",
+				Amount:           50.97,
+				RefundedAmount:   50.97,
+				Currency:         "cad",
+				ReceiptURL:       "https://pay.stripe.com/receipts/payment/CAcaFwoVYWNjdF8xTHFpd3g",
+			},
+		},
+		{
+			payload: "charge_dispute_created",
+			want: Event{
+				ID:               "evt_1PdA7tL5mTz3fQ0aGk5Hn2Wd",
+				ProviderType:     "charge.dispute.created",
+				Type:             PaymentDisputed,
+				PaymentReference: "pi_3PcZ4nL5mTz3fQ0a0Wq5Ej3h",
+				Amount:           7.25,
+				Currency:         "kwd",
+				Reason:           "fraudulent",
+			},
+		},
+		{
+			payload: "payment_intent_payment_failed",
+			want: Event{
+				ID:               "evt_3PcM9bL5mTz3fQ0a0Ys4Hk7p",
+				ProviderType:     "payment_intent.payment_failed",
+				Type:             PaymentFailed,
+				OrderID:          "order-45",
+				CustomerID:       "customer-10",
+				PaymentReference: "pi_3PcM9bL5mTz3fQ0a0Fm2Tq6c",
+				Amount:           18.5,
+				Currency:         "usd",
+				Reason:           "Your card has insufficient funds.",
+			},
+		},
+		{
+			payload: "customer_created",
+			want: Event{
+				ID:           "evt_1PcS0hL5mTz3fQ0aEe3Mt5Ky",
+				ProviderType: "customer.created",
+				Type:         Ignored,
+			},
+		},
+	}
+
+	provider := NewStripeProvider("whsec_test", nil, 0)
+	for _, tt := range tests {
+		t.Run(tt.payload, func(t *testing.T) {
+			event, err := provider.ParseEvent(readPayload(t, stripeName, tt.payload))
+			if err != nil {
+				t.Fatalf("ParseEvent: %v", err)
+			}
+
+			tt.want.Provider = stripeName
+			if *event != tt.want {
+				t.Fatalf("event = %+v, want %+v", *event, tt.want)
+			}
+		})
+	}
+}
+
+func TestStripeParseEventErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		payload   []byte
+		wantErr   error
+		wantField string
+	}{
+		{
+			name:      "missing customer",
+			payload:   readPayload(t, stripeName, "checkout_session_completed_without_customer"),
+			wantErr:   ErrMissingField,
+			wantField: "metadata.customer_id",
+		},
+		{
+			name:      "missing payment reference",
+			payload:   readPayload(t, stripeName, "charge_succeeded_without_reference"),
+			wantErr:   ErrMissingField,
+			wantField: "payment_intent",
+		},
+		{
+			name:      "missing order",
+			payload:   []byte(`{"id":"evt_1","type":"checkout.session.expired","data":{"object":{"id":"cs_1","metadata":{"customer_id":"customer-1"}}}}`),
+			wantErr:   ErrMissingField,
+			wantField: "metadata.order_id",
+		},
+		{
+			name:      "missing object",
+			payload:   []byte(`{"id":"evt_1","type":"charge.refunded"}`),
+			wantErr:   ErrMissingField,
+			wantField: "data.object",
+		},
+		{
+			name:      "missing payment intent ID",
+			payload:   []byte(`{"id":"evt_1","type":"payment_intent.payment_failed","data":{"object":{"amount":100}}}`),
+			wantErr:   ErrMissingField,
+			wantField: "id",
+		},
+		{
+			name:    "mistyped field",
+			payload: []byte(`{"id":"evt_1","type":"charge.succeeded","data":{"object":{"amount":"10.00","payment_intent":"pi_1"}}}`),
+			wantErr: ErrMalformedEvent,
+		},
+		{
+			name:    "not JSON",
+			payload: []byte(`event`),
+			wantErr: ErrMalformedEvent,
+		},
+	}
+
+	provider := NewStripeProvider("whsec_test", nil, 0)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			event, err := provider.ParseEvent(tt.payload)
+			if err == nil {
+				t.Fatalf("ParseEvent = %+v, want an error", event)
+			}
+
+			var eventErr *EventError
+			if !errors.As(err, &eventErr) || !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, want an *EventError wrapping %v", err, tt.wantErr)
+			}
+			if eventErr.Provider != stripeName || eventErr.Field != tt.wantField {
+				t.Fatalf("error = %+v, want field %q", eventErr, tt.wantField)
+			}
+		})
+	}
+}
+
+func TestFromMinorUnits(t *testing.T) {
+	tests := []struct {
+		amount   int64
+		currency string
+		want     float64
+	}{
+		{amount: 1999, currency: "usd", want: 19.99},
+		{amount: 1999, currency: "USD", want: 19.99},
+		{amount: 5, currency: "eur", want: 0.05},
+		{amount: 1999, currency: "jpy", want: 1999},
+		{amount: 1999, currency: "KRW", want: 1999},
+		{amount: 1999, currency: "kwd", want: 1.999},
+		{amount: 0, currency: "cad", want: 0},
+	}
+
+	for _, tt := range tests {
+		if got := fromMinorUnits(tt.amount, tt.currency); got != tt.want {
+			t.Errorf("fromMinorUnits(%d, %q) = %v, want %v", tt.amount, tt.currency, got, tt.want)
+		}
+	}
+}
+
+func TestStripeVerifyWebhookDuringRotation(t *testing.T) {
+	const (
+		current  = "whsec_test_current"
+		previous = "whsec_test_previous"
+		oldest   = "whsec_test_oldest"
+	)
+	payload := readPayload(t, stripeName, "checkout_session_completed")
+
+	tests := []struct {
+		name      string
+		header    http.Header
+		wantErr   bool
+		wantLabel string
+	}{
+		{
+			name:      "current secret",
+			header:    stripeHeader(payload, current, time.Now()),
+			wantLabel: "current",
+		},
+		{
+			name:      "previous secret",
+			header:    stripeHeader(payload, previous, time.Now()),
+			wantLabel: "previous_1",
+		},
+		{
+			name:      "oldest previous secret",
+			header:    stripeHeader(payload, oldest, time.Now()),
+			wantLabel: "previous_2",
+		},
+		{
+			name:      "removed secret",
+			header:    stripeHeader(payload, "whsec_test_removed", time.Now()),
+			wantErr:   true,
+			wantLabel: "none",
+		},
+		{
+			name:      "previous secret after the tolerance",
+			header:    stripeHeader(payload, previous, time.Now().Add(-10*time.Minute)),
+			wantErr:   true,
+			wantLabel: "none",
+		},
+	}
+
+	provider := NewStripeProvider(current, []string{previous, oldest}, 5*time.Minute)
+	labels := []string{"current", "previous_1", "previous_2", "none"}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := make(map[string]float64)
+			for _, label := range labels {
+				before[label] = testutil.ToFloat64(stripeSignatures.WithLabelValues(label))
+			}
+
+			_, err := provider.VerifyWebhook(context.Background(), payload, tt.header)
+			if tt.wantErr != (err != nil) {
+				t.Fatalf("VerifyWebhook error = %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidSignature) {
+				t.Fatalf("VerifyWebhook error = %v, want %v", err, ErrInvalidSignature)
+			}
+
+			// Only the secret that validated the delivery is counted, by position rather than by value
+			for _, label := range labels {
+				want := 0.0
+				if label == tt.wantLabel {
+					want = 1
+				}
+				if got := testutil.ToFloat64(stripeSignatures.WithLabelValues(label)) - before[label]; got != want {
+					t.Errorf("%s signatures counted %v times, want %v", label, got, want)
+				}
+			}
+		})
+	}
+}
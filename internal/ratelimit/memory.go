@@ -0,0 +1,81 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+	rate   float64
+	burst  int
+}
+
+type memoryStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// NewMemoryStore creates a Store that keeps buckets in process memory
+func NewMemoryStore() Store {
+	return newMemoryStore(time.Now)
+}
+
+// newMemoryStore creates a memory store that reads the time from now
+func newMemoryStore(now func() time.Time) *memoryStore {
+	return &memoryStore{
+		buckets:   make(map[string]*bucket),
+		lastSweep: now(),
+		now:       now,
+	}
+}
+
+func (s *memoryStore) Take(ctx context.Context, key string, rate float64, burst int) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.sweep(now)
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(burst), last: now}
+		s.buckets[key] = b
+	}
+	b.rate = rate
+	b.burst = burst
+
+	// Refill tokens for the time elapsed since the last request
+	b.tokens = math.Min(float64(burst), b.tokens+now.Sub(b.last).Seconds()*rate)
+	b.last = now
+
+	allowed := b.tokens >= 1
+	if allowed {
+		b.tokens--
+	}
+
+	return newResult(allowed, b.tokens, rate, burst), nil
+}
+
+func (s *memoryStore) Close() error {
+	return nil
+}
+
+// sweep drops buckets that have been idle long enough to be full again
+func (s *memoryStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < time.Minute {
+		return
+	}
+	s.lastSweep = now
+
+	for key, b := range s.buckets {
+		if now.Sub(b.last) > durationFor(float64(b.burst), b.rate) {
+			delete(s.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// tokenBucketScript refills and takes from a bucket atomically using the Redis server clock,
+// so replicas with skewed clocks still agree on the bucket state. It is run by SHA once loaded.
+var tokenBucketScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1])
+local ts = tonumber(state[2])
+if tokens == nil or ts == nil then
+	tokens = burst
+	ts = now
+end
+
+tokens = math.min(burst, tokens + math.max(0, now - ts) / 1000 * rate)
+local allowed = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+end
+
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', tostring(now))
+redis.call('PEXPIRE', KEYS[1], math.ceil(burst / rate * 1000) + 1000)
+return {allowed, tostring(tokens)}
+`)
+
+type redisStore struct {
+	client    redis.UniversalClient
+	keyPrefix string
+}
+
+// NewRedisStore creates a Store backed by a Redis server. The store owns the client.
+func NewRedisStore(client redis.UniversalClient, keyPrefix string) Store {
+	return &redisStore{
+		client:    client,
+		keyPrefix: keyPrefix,
+	}
+}
+
+func (s *redisStore) Take(ctx context.Context, key string, rate float64, burst int) (Result, error) {
+	reply, err := tokenBucketScript.Run(ctx, s.client, []string{s.keyPrefix + key},
+		strconv.FormatFloat(rate, 'f', -1, 64), burst).Result()
+	if err != nil {
+		return Result{}, err
+	}
+
+	values, ok := reply.([]interface{})
+	if !ok || len(values) != 2 {
+		return Result{}, fmt.Errorf("unexpected rate limit reply: %v", reply)
+	}
+
+	allowed, ok := values[0].(int64)
+	if !ok {
+		return Result{}, fmt.Errorf("unexpected rate limit reply: %v", reply)
+	}
+
+	rawTokens, ok := values[1].(string)
+	if !ok {
+		return Result{}, fmt.Errorf("unexpected rate limit reply: %v", reply)
+	}
+
+	tokens, err := strconv.ParseFloat(rawTokens, 64)
+	if err != nil {
+		return Result{}, err
+	}
+
+	return newResult(allowed == 1, tokens, rate, burst), nil
+}
+
+func (s *redisStore) Close() error {
+	return s.client.Close()
+}
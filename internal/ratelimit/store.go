@@ -0,0 +1,58 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+
+	"github.com/PharmaKart/gateway-svc/pkg/config"
+	"github.com/redis/go-redis/v9"
+)
+
+// Result describes the outcome of taking a token from a bucket
+type Result struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	RetryAfter time.Duration
+	ResetAfter time.Duration
+}
+
+// Store holds token buckets so that limits can be shared between gateway replicas
+type Store interface {
+	// Take removes a token from the bucket identified by key, refilling it at rate tokens per second up to burst
+	Take(ctx context.Context, key string, rate float64, burst int) (Result, error)
+	Close() error
+}
+
+// newResult builds a Result from the token count left in a bucket after a take
+func newResult(allowed bool, tokens, rate float64, burst int) Result {
+	result := Result{
+		Allowed:    allowed,
+		Limit:      burst,
+		Remaining:  int(tokens),
+		ResetAfter: durationFor(float64(burst)-tokens, rate),
+	}
+	if !allowed {
+		result.RetryAfter = durationFor(1-tokens, rate)
+	}
+
+	return result
+}
+
+func durationFor(tokens, rate float64) time.Duration {
+	return time.Duration(tokens / rate * float64(time.Second))
+}
+
+// NewStore creates the Store selected by the configuration
+func NewStore(cfg *config.Config) Store {
+	switch cfg.RateLimitStore {
+	case "redis":
+		return NewRedisStore(redis.NewClient(&redis.Options{
+			Addr:     cfg.RedisAddr,
+			Password: cfg.RedisPassword,
+			DB:       cfg.RedisDB,
+		}), "gateway:ratelimit:")
+	default:
+		return NewMemoryStore()
+	}
+}
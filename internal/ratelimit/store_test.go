@@ -0,0 +1,240 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/PharmaKart/gateway-svc/internal/testutil"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// newStores returns every Store implementation, with the Redis store backed by an in-process server
+func newStores(t *testing.T) map[string]testutil.Backend[Store] {
+	return testutil.Stores(t,
+		func(now func() time.Time) Store { return newMemoryStore(now) },
+		func(client *redis.Client) Store { return NewRedisStore(client, "test:") },
+	)
+}
+
+func TestStoreTake(t *testing.T) {
+	tests := []struct {
+		name  string
+		rate  float64
+		burst int
+		takes int
+		// wantAllowed is the outcome of each take
+		wantAllowed []bool
+		wantResult  Result
+	}{
+		{
+			name:        "within burst",
+			rate:        1,
+			burst:       3,
+			takes:       2,
+			wantAllowed: []bool{true, true},
+			wantResult:  Result{Allowed: true, Limit: 3, Remaining: 1},
+		},
+		{
+			name:        "burst exhausted",
+			rate:        0.5,
+			burst:       2,
+			takes:       3,
+			wantAllowed: []bool{true, true, false},
+			wantResult:  Result{Allowed: false, Limit: 2, Remaining: 0},
+		},
+		{
+			name:        "burst of one",
+			rate:        0.001,
+			burst:       1,
+			takes:       2,
+			wantAllowed: []bool{true, false},
+			wantResult:  Result{Allowed: false, Limit: 1, Remaining: 0},
+		},
+	}
+
+	for name, backend := range newStores(t) {
+		store := backend.Store
+		for _, tt := range tests {
+			t.Run(name+"/"+tt.name, func(t *testing.T) {
+				key := t.Name()
+
+				var result Result
+				for i := 0; i < tt.takes; i++ {
+					var err error
+					result, err = store.Take(context.Background(), key, tt.rate, tt.burst)
+					if err != nil {
+						t.Fatalf("Take: %v", err)
+					}
+					if result.Allowed != tt.wantAllowed[i] {
+						t.Fatalf("take %d: allowed = %v, want %v", i+1, result.Allowed, tt.wantAllowed[i])
+					}
+				}
+
+				if result.Limit != tt.wantResult.Limit || result.Remaining != tt.wantResult.Remaining {
+					t.Errorf("limit, remaining = %d, %d, want %d, %d", result.Limit, result.Remaining, tt.wantResult.Limit, tt.wantResult.Remaining)
+				}
+				if !result.Allowed {
+					// The next token arrives after 1/rate seconds at most
+					if result.RetryAfter <= 0 || result.RetryAfter > time.Duration(float64(time.Second)/tt.rate) {
+						t.Errorf("RetryAfter = %v, want within (0, %v]", result.RetryAfter, time.Duration(float64(time.Second)/tt.rate))
+					}
+				} else if result.RetryAfter != 0 {
+					t.Errorf("RetryAfter = %v for an allowed take", result.RetryAfter)
+				}
+			})
+		}
+	}
+}
+
+func TestStoreRefills(t *testing.T) {
+	for name, backend := range newStores(t) {
+		store := backend.Store
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			// 20 tokens per second refill one token every 50ms
+			for i := 0; i < 2; i++ {
+				if result, err := store.Take(ctx, "refill", 20, 2); err != nil || !result.Allowed {
+					t.Fatalf("take %d: allowed = %v, err = %v", i+1, result.Allowed, err)
+				}
+			}
+			if result, err := store.Take(ctx, "refill", 20, 2); err != nil || result.Allowed {
+				t.Fatalf("empty bucket: allowed = %v, err = %v", result.Allowed, err)
+			}
+
+			backend.Advance(120 * time.Millisecond)
+
+			if result, err := store.Take(ctx, "refill", 20, 2); err != nil || !result.Allowed {
+				t.Fatalf("after refill: allowed = %v, err = %v", result.Allowed, err)
+			}
+		})
+	}
+}
+
+func TestStoreKeysAreIndependent(t *testing.T) {
+	for name, backend := range newStores(t) {
+		store := backend.Store
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			if result, _ := store.Take(ctx, "a", 0.001, 1); !result.Allowed {
+				t.Fatal("first take on a was rejected")
+			}
+			if result, _ := store.Take(ctx, "a", 0.001, 1); result.Allowed {
+				t.Fatal("second take on a was allowed")
+			}
+			if result, _ := store.Take(ctx, "b", 0.001, 1); !result.Allowed {
+				t.Fatal("first take on b was rejected")
+			}
+		})
+	}
+}
+
+func TestStoreConcurrentTakes(t *testing.T) {
+	const burst = 10
+
+	for name, backend := range newStores(t) {
+		store := backend.Store
+		t.Run(name, func(t *testing.T) {
+			var (
+				wg      sync.WaitGroup
+				mu      sync.Mutex
+				allowed int
+			)
+			for i := 0; i < 3*burst; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+
+					result, err := store.Take(context.Background(), "concurrent", 0.001, burst)
+					if err != nil {
+						t.Errorf("Take: %v", err)
+						return
+					}
+					if result.Allowed {
+						mu.Lock()
+						allowed++
+						mu.Unlock()
+					}
+				}()
+			}
+			wg.Wait()
+
+			if allowed != burst {
+				t.Fatalf("allowed %d concurrent takes, want %d", allowed, burst)
+			}
+		})
+	}
+}
+
+// commandRecorder records the names of the commands sent by a client
+type commandRecorder struct {
+	mu       sync.Mutex
+	commands []string
+}
+
+func (r *commandRecorder) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+func (r *commandRecorder) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		r.mu.Lock()
+		r.commands = append(r.commands, cmd.Name())
+		r.mu.Unlock()
+		return next(ctx, cmd)
+	}
+}
+
+func (r *commandRecorder) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return next
+}
+
+func TestRedisStoreSendsScriptOnce(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	recorder := &commandRecorder{}
+	client.AddHook(recorder)
+
+	store := NewRedisStore(client, "test:")
+	defer store.Close()
+
+	for i := 0; i < 3; i++ {
+		if _, err := store.Take(context.Background(), "script", 1, 5); err != nil {
+			t.Fatalf("Take: %v", err)
+		}
+	}
+
+	// The script is only sent in full when the server does not have it cached yet
+	want := []string{"evalsha", "eval", "evalsha", "evalsha"}
+	if len(recorder.commands) != len(want) {
+		t.Fatalf("commands = %v, want %v", recorder.commands, want)
+	}
+	for i := range want {
+		if recorder.commands[i] != want[i] {
+			t.Fatalf("commands = %v, want %v", recorder.commands, want)
+		}
+	}
+
+	if !server.Exists("test:script") {
+		t.Fatal("bucket was not stored under the key prefix")
+	}
+	if ttl := server.TTL("test:script"); ttl <= 0 {
+		t.Fatalf("bucket TTL = %v, want it to expire once full", ttl)
+	}
+}
+
+func TestRedisStoreUnavailable(t *testing.T) {
+	server := miniredis.RunT(t)
+	store := NewRedisStore(redis.NewClient(&redis.Options{Addr: server.Addr(), MaxRetries: -1}), "test:")
+	defer store.Close()
+
+	server.Close()
+
+	if _, err := store.Take(context.Background(), "down", 1, 1); err == nil {
+		t.Fatal("Take succeeded without a server")
+	}
+}
@@ -0,0 +1,67 @@
+package revocation
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/PharmaKart/gateway-svc/internal/auth"
+)
+
+// ErrUndetermined means a token cannot be matched against the revocation of its user's sessions,
+// because it carries no iat claim. Such tokens must be verified by auth-svc.
+var ErrUndetermined = errors.New("token revocation cannot be determined without an iat claim")
+
+// List rejects tokens revoked through any gateway replica sharing its store
+type List struct {
+	store Store
+	ttl   time.Duration
+	now   func() time.Time
+}
+
+// NewList creates a List. Revocations are remembered for ttl at most, which should cover the access
+// token lifetime.
+func NewList(store Store, ttl time.Duration) *List {
+	return &List{
+		store: store,
+		ttl:   ttl,
+		now:   time.Now,
+	}
+}
+
+// RevokeToken rejects token until it expires
+func (l *List) RevokeToken(ctx context.Context, token string) error {
+	expiresAt := l.now().Add(l.ttl)
+	if exp, ok := auth.ClaimTime(token, "exp"); ok && exp.Before(expiresAt) {
+		expiresAt = exp
+	}
+	return l.store.RevokeToken(ctx, auth.HashToken(token), expiresAt)
+}
+
+// RevokeUser rejects every token issued to userID before the current second. iat only has a precision
+// of one second, so tokens issued in that second, such as those of a login right after the revocation,
+// are accepted.
+func (l *List) RevokeUser(ctx context.Context, userID string) error {
+	return l.store.RevokeUser(ctx, userID, l.now().Truncate(time.Second), l.ttl)
+}
+
+// Revoked reports whether token, verified as belonging to userID, has been revoked
+func (l *List) Revoked(ctx context.Context, token, userID string) (bool, error) {
+	status, err := l.store.Lookup(ctx, auth.HashToken(token), userID)
+	if err != nil {
+		return false, err
+	}
+	if status.TokenRevoked {
+		return true, nil
+	}
+	if status.UserRevokedAt.IsZero() {
+		return false, nil
+	}
+
+	iat, ok := auth.ClaimTime(token, "iat")
+	if !ok {
+		return false, ErrUndetermined
+	}
+
+	return iat.Before(status.UserRevokedAt), nil
+}
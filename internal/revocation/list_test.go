@@ -0,0 +1,182 @@
+package revocation
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/PharmaKart/gateway-svc/internal/testutil"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// newStores returns every Store implementation, with the Redis store backed by an in-process server
+func newStores(t *testing.T) map[string]testutil.Backend[Store] {
+	return testutil.Stores(t,
+		func(now func() time.Time) Store { return newMemoryStore(now) },
+		func(client *redis.Client) Store { return NewRedisStore(client, "test:") },
+	)
+}
+
+// token builds a JWT-shaped token carrying claims. The list never verifies signatures.
+func token(t *testing.T, claims map[string]interface{}) string {
+	t.Helper()
+
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatalf("marshal claims: %v", err)
+	}
+	return "eyJhbGciOiJub25lIn0." + base64.RawURLEncoding.EncodeToString(payload) + ".sig"
+}
+
+func TestListRevoked(t *testing.T) {
+	// Revocations happen late in a second, after tokens issued earlier in it
+	now := time.Now().Truncate(time.Second).Add(900 * time.Millisecond)
+	tests := []struct {
+		name    string
+		claims  map[string]interface{}
+		userID  string
+		revoke  func(ctx context.Context, list *List, token string) error
+		want    bool
+		wantErr error
+	}{
+		{
+			name:   "nothing revoked",
+			claims: map[string]interface{}{"sub": "u1", "iat": now.Unix()},
+			userID: "u1",
+			revoke: func(ctx context.Context, list *List, token string) error { return nil },
+		},
+		{
+			name:   "token revoked",
+			claims: map[string]interface{}{"sub": "u1", "iat": now.Unix()},
+			userID: "u1",
+			revoke: func(ctx context.Context, list *List, token string) error {
+				return list.RevokeToken(ctx, token)
+			},
+			want: true,
+		},
+		{
+			name:   "another token revoked",
+			claims: map[string]interface{}{"sub": "u1", "iat": now.Unix()},
+			userID: "u1",
+			revoke: func(ctx context.Context, list *List, token string) error {
+				return list.RevokeToken(ctx, token+"x")
+			},
+		},
+		{
+			name:   "issued before the user was revoked",
+			claims: map[string]interface{}{"sub": "u1", "iat": now.Add(-time.Minute).Unix()},
+			userID: "u1",
+			revoke: func(ctx context.Context, list *List, token string) error {
+				return list.RevokeUser(ctx, "u1")
+			},
+			want: true,
+		},
+		{
+			name:   "issued in the second before the user was revoked",
+			claims: map[string]interface{}{"sub": "u1", "iat": now.Add(-time.Second).Unix()},
+			userID: "u1",
+			revoke: func(ctx context.Context, list *List, token string) error {
+				return list.RevokeUser(ctx, "u1")
+			},
+			want: true,
+		},
+		{
+			// A login right after revoking every session must not be rejected
+			name:   "issued in the second the user was revoked",
+			claims: map[string]interface{}{"sub": "u1", "iat": now.Unix()},
+			userID: "u1",
+			revoke: func(ctx context.Context, list *List, token string) error {
+				return list.RevokeUser(ctx, "u1")
+			},
+		},
+		{
+			name:   "issued after the user was revoked",
+			claims: map[string]interface{}{"sub": "u1", "iat": now.Add(time.Second).Unix()},
+			userID: "u1",
+			revoke: func(ctx context.Context, list *List, token string) error {
+				return list.RevokeUser(ctx, "u1")
+			},
+		},
+		{
+			name:   "another user revoked",
+			claims: map[string]interface{}{"sub": "u1", "iat": now.Unix()},
+			userID: "u1",
+			revoke: func(ctx context.Context, list *List, token string) error {
+				return list.RevokeUser(ctx, "u2")
+			},
+		},
+		{
+			name:   "user revoked and no iat",
+			claims: map[string]interface{}{"sub": "u1"},
+			userID: "u1",
+			revoke: func(ctx context.Context, list *List, token string) error {
+				return list.RevokeUser(ctx, "u1")
+			},
+			wantErr: ErrUndetermined,
+		},
+	}
+
+	for _, tt := range tests {
+		for name, backend := range newStores(t) {
+			store := backend.Store
+			t.Run(name+"/"+tt.name, func(t *testing.T) {
+				ctx := context.Background()
+				list := NewList(store, time.Hour)
+				tok := token(t, tt.claims)
+
+				// Revoke through another replica sharing the store
+				revoker := NewList(store, time.Hour)
+				revoker.now = func() time.Time { return now }
+				if err := tt.revoke(ctx, revoker, tok); err != nil {
+					t.Fatalf("revoke: %v", err)
+				}
+
+				revoked, err := list.Revoked(ctx, tok, tt.userID)
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Revoked error = %v, want %v", err, tt.wantErr)
+				}
+				if revoked != tt.want {
+					t.Fatalf("Revoked = %v, want %v", revoked, tt.want)
+				}
+			})
+		}
+	}
+}
+
+func TestListRevocationsExpire(t *testing.T) {
+	for name, backend := range newStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			list := NewList(backend.Store, time.Hour)
+			tok := token(t, map[string]interface{}{"sub": "u1", "exp": time.Now().Add(2 * time.Second).Unix()})
+
+			if err := list.RevokeToken(ctx, tok); err != nil {
+				t.Fatalf("RevokeToken: %v", err)
+			}
+			if revoked, err := list.Revoked(ctx, tok, "u1"); err != nil || !revoked {
+				t.Fatalf("Revoked = %v, %v, want true", revoked, err)
+			}
+
+			// The revocation is only kept until the token expires
+			backend.Advance(3 * time.Second)
+
+			if revoked, err := list.Revoked(ctx, tok, "u1"); err != nil || revoked {
+				t.Fatalf("Revoked = %v, %v after expiry, want false", revoked, err)
+			}
+		})
+	}
+}
+
+func TestRedisStoreUnavailable(t *testing.T) {
+	server := miniredis.RunT(t)
+	list := NewList(NewRedisStore(redis.NewClient(&redis.Options{Addr: server.Addr(), MaxRetries: -1}), "test:"), time.Hour)
+	server.Close()
+
+	if _, err := list.Revoked(context.Background(), token(t, map[string]interface{}{"sub": "u1"}), "u1"); err == nil {
+		t.Fatal("Revoked succeeded without a server")
+	}
+}
@@ -0,0 +1,94 @@
+package revocation
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type userRevocation struct {
+	revokedAt time.Time
+	expiresAt time.Time
+}
+
+type memoryStore struct {
+	mu        sync.Mutex
+	tokens    map[string]time.Time
+	users     map[string]userRevocation
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// NewMemoryStore creates a Store local to this gateway instance
+func NewMemoryStore() Store {
+	return newMemoryStore(time.Now)
+}
+
+// newMemoryStore creates a memory store that reads the time from now
+func newMemoryStore(now func() time.Time) *memoryStore {
+	return &memoryStore{
+		tokens: make(map[string]time.Time),
+		users:  make(map[string]userRevocation),
+		now:    now,
+	}
+}
+
+func (s *memoryStore) RevokeToken(ctx context.Context, tokenHash string, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sweep(s.now())
+	s.tokens[tokenHash] = expiresAt
+	return nil
+}
+
+func (s *memoryStore) RevokeUser(ctx context.Context, userID string, revokedAt time.Time, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sweep(s.now())
+	s.users[userID] = userRevocation{
+		revokedAt: revokedAt,
+		expiresAt: revokedAt.Add(ttl),
+	}
+	return nil
+}
+
+func (s *memoryStore) Lookup(ctx context.Context, tokenHash, userID string) (Status, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+
+	var status Status
+	if expiresAt, ok := s.tokens[tokenHash]; ok && now.Before(expiresAt) {
+		status.TokenRevoked = true
+	}
+	if user, ok := s.users[userID]; ok && now.Before(user.expiresAt) {
+		status.UserRevokedAt = user.revokedAt
+	}
+	return status, nil
+}
+
+func (s *memoryStore) Close() error {
+	return nil
+}
+
+// sweep drops expired revocations at most once a minute
+func (s *memoryStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < time.Minute {
+		return
+	}
+	s.lastSweep = now
+
+	for tokenHash, expiresAt := range s.tokens {
+		if !now.Before(expiresAt) {
+			delete(s.tokens, tokenHash)
+		}
+	}
+	for userID, user := range s.users {
+		if !now.Before(user.expiresAt) {
+			delete(s.users, userID)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package revocation
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+type redisStore struct {
+	client    redis.UniversalClient
+	keyPrefix string
+}
+
+// NewRedisStore creates a Store backed by a Redis server, shared by every gateway replica. The store
+// owns the client.
+func NewRedisStore(client redis.UniversalClient, keyPrefix string) Store {
+	return &redisStore{
+		client:    client,
+		keyPrefix: keyPrefix,
+	}
+}
+
+func (s *redisStore) RevokeToken(ctx context.Context, tokenHash string, expiresAt time.Time) error {
+	ttl := time.Until(expiresAt)
+	if ttl <= 0 {
+		return nil
+	}
+	return s.client.Set(ctx, s.tokenKey(tokenHash), "1", ttl).Err()
+}
+
+func (s *redisStore) RevokeUser(ctx context.Context, userID string, revokedAt time.Time, ttl time.Duration) error {
+	return s.client.Set(ctx, s.userKey(userID), revokedAt.UnixMilli(), ttl).Err()
+}
+
+func (s *redisStore) Lookup(ctx context.Context, tokenHash, userID string) (Status, error) {
+	values, err := s.client.MGet(ctx, s.tokenKey(tokenHash), s.userKey(userID)).Result()
+	if err != nil {
+		return Status{}, err
+	}
+	if len(values) != 2 {
+		return Status{}, fmt.Errorf("unexpected revocation lookup reply: %v", values)
+	}
+
+	status := Status{TokenRevoked: values[0] != nil}
+	if raw, ok := values[1].(string); ok {
+		millis, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return Status{}, fmt.Errorf("invalid user revocation time %q: %w", raw, err)
+		}
+		status.UserRevokedAt = time.UnixMilli(millis)
+	}
+	return status, nil
+}
+
+func (s *redisStore) Close() error {
+	return s.client.Close()
+}
+
+func (s *redisStore) tokenKey(tokenHash string) string {
+	return s.keyPrefix + "token:" + tokenHash
+}
+
+func (s *redisStore) userKey(userID string) string {
+	return s.keyPrefix + "user:" + userID
+}
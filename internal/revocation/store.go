@@ -0,0 +1,43 @@
+// Package revocation records revoked tokens and users in a store shared by every gateway replica, so
+// that a logout or a session revocation takes effect on all of them at once.
+package revocation
+
+import (
+	"context"
+	"time"
+
+	"github.com/PharmaKart/gateway-svc/pkg/config"
+	"github.com/redis/go-redis/v9"
+)
+
+// Status is what a Store knows about the revocation of a token
+type Status struct {
+	TokenRevoked bool
+	// UserRevokedAt is the time the sessions of the token's user were last revoked, or zero
+	UserRevokedAt time.Time
+}
+
+// Store holds revocations until the tokens they cover have expired
+type Store interface {
+	// RevokeToken rejects the token with the given hash until expiresAt
+	RevokeToken(ctx context.Context, tokenHash string, expiresAt time.Time) error
+	// RevokeUser rejects the tokens of userID issued before revokedAt, and remembers it for ttl
+	RevokeUser(ctx context.Context, userID string, revokedAt time.Time, ttl time.Duration) error
+	// Lookup returns the revocations that apply to the token with the given hash, issued to userID
+	Lookup(ctx context.Context, tokenHash, userID string) (Status, error)
+	Close() error
+}
+
+// NewStore creates the Store selected by the configuration
+func NewStore(cfg *config.Config) Store {
+	switch cfg.TokenRevocationStore {
+	case "redis":
+		return NewRedisStore(redis.NewClient(&redis.Options{
+			Addr:     cfg.RedisAddr,
+			Password: cfg.RedisPassword,
+			DB:       cfg.RedisDB,
+		}), "gateway:revoked:")
+	default:
+		return NewMemoryStore()
+	}
+}
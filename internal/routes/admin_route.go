@@ -0,0 +1,22 @@
+package routes
+
+import (
+	"github.com/PharmaKart/gateway-svc/internal/grpc"
+	"github.com/PharmaKart/gateway-svc/internal/handlers"
+	"github.com/PharmaKart/gateway-svc/internal/middleware"
+	"github.com/PharmaKart/gateway-svc/internal/webhookqueue"
+	"github.com/gin-gonic/gin"
+)
+
+func RegisterAdminRoutes(r *gin.RouterGroup, authClient grpc.AuthClient, tokenCache *grpc.TokenCache, breakers []*grpc.CircuitBreaker, webhookQueue *webhookqueue.Queue) {
+	admin := r.Group("/admin")
+	admin.Use(middleware.AuthMiddleware(authClient))
+	admin.Use(middleware.RBACMiddleware("admin"))
+	{
+		admin.GET("/auth/cache", handlers.GetTokenCacheStats(tokenCache))
+		admin.GET("/circuit-breakers", handlers.GetCircuitBreakers(breakers))
+		admin.GET("/webhooks/dead-letters", handlers.ListWebhookDeadLetters(webhookQueue))
+		admin.POST("/webhooks/dead-letters/replay", handlers.ReplayWebhookDeadLetters(webhookQueue))
+		admin.POST("/webhooks/dead-letters/:id/replay", handlers.ReplayWebhookDeadLetter(webhookQueue))
+	}
+}
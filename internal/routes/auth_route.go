@@ -3,10 +3,16 @@ package routes
 import (
 	"github.com/PharmaKart/gateway-svc/internal/grpc"
 	"github.com/PharmaKart/gateway-svc/internal/handlers"
+	"github.com/PharmaKart/gateway-svc/internal/middleware"
 	"github.com/gin-gonic/gin"
 )
 
-func RegisterAuthRoutes(r *gin.RouterGroup, authClient grpc.AuthClient) {
-	r.POST("/register", handlers.Register(authClient))
-	r.POST("/login", handlers.Login(authClient))
+func RegisterAuthRoutes(r *gin.RouterGroup, authClient grpc.AuthClient, rateLimit, refreshRateLimit gin.HandlerFunc) {
+	r.POST("/register", rateLimit, handlers.Register(authClient))
+	r.POST("/login", rateLimit, handlers.Login(authClient))
+	r.POST("/token/refresh", refreshRateLimit, handlers.RefreshToken(authClient))
+
+	// Registered per route so that the authentication middleware does not leak onto the shared group
+	r.POST("/logout", middleware.AuthMiddleware(authClient), handlers.Logout(authClient))
+	r.DELETE("/sessions", middleware.AuthMiddleware(authClient), handlers.RevokeAllSessions(authClient))
 }
@@ -4,22 +4,29 @@ import (
 	"github.com/PharmaKart/gateway-svc/internal/grpc"
 	"github.com/PharmaKart/gateway-svc/internal/handlers"
 	"github.com/PharmaKart/gateway-svc/internal/middleware"
+	"github.com/PharmaKart/gateway-svc/internal/webhookqueue"
 	"github.com/PharmaKart/gateway-svc/pkg/config"
 	"github.com/gin-gonic/gin"
 )
 
-func RegisterOrderRoutes(r *gin.RouterGroup, cfg *config.Config, authClient grpc.AuthClient, orderClient grpc.OrderClient, paymentClient grpc.PaymentClient) {
+func RegisterOrderRoutes(r *gin.RouterGroup, cfg *config.Config, authClient grpc.AuthClient, orderClient grpc.OrderClient, paymentClient grpc.PaymentClient, refundQueue *webhookqueue.Queue, userRateLimit, orderRateLimit gin.HandlerFunc) {
 	r.Use(middleware.AuthMiddleware(authClient))
+
+	// The user limit is attached to a subgroup, so that it does not leak onto the routes registered later
+	orders := r.Group("/orders")
+	orders.Use(userRateLimit)
 	{
-		r.POST("/orders", handlers.PlaceOrder(cfg, orderClient))
-		r.GET("/orders", handlers.ListCustomersOrders(orderClient))
-		r.GET("/orders/:id", handlers.GetOrder(orderClient, paymentClient))
-		r.PUT("/orders/:id", handlers.UpdateOrderStatus(orderClient))
-		r.POST("/orders/:id/payment", handlers.GenerateNewPaymentUrl(orderClient))
+		orders.POST("", orderRateLimit, handlers.PlaceOrder(cfg, orderClient))
+		orders.GET("", handlers.ListCustomersOrders(orderClient))
+		orders.GET("/:id", handlers.GetOrder(orderClient, paymentClient))
+		orders.PUT("/:id", handlers.UpdateOrderStatus(orderClient))
+		orders.POST("/:id/payment", handlers.GenerateNewPaymentUrl(orderClient))
+		orders.POST("/:id/cancel", handlers.CancelOrder(orderClient, paymentClient, refundQueue))
 	}
 
 	admin := r.Group("/admin")
 	admin.Use(middleware.AuthMiddleware(authClient))
+	admin.Use(userRateLimit)
 	admin.Use(middleware.RBACMiddleware("admin"))
 	{
 		admin.GET("/orders", handlers.ListAllOrders(orderClient))
@@ -4,17 +4,29 @@ import (
 	"github.com/PharmaKart/gateway-svc/internal/grpc"
 	"github.com/PharmaKart/gateway-svc/internal/handlers"
 	"github.com/PharmaKart/gateway-svc/internal/middleware"
-	"github.com/PharmaKart/gateway-svc/pkg/config"
+	"github.com/PharmaKart/gateway-svc/internal/payments"
+	"github.com/PharmaKart/gateway-svc/internal/webhookqueue"
 	"github.com/gin-gonic/gin"
 )
 
-func RegisterPaymentRoutes(r *gin.RouterGroup, cfg *config.Config, authClient grpc.AuthClient, paymentClient grpc.PaymentClient) {
-	r.POST("/payment/webhook", handlers.HandleWebhook(cfg, paymentClient))
+func RegisterWebhookRoutes(r *gin.RouterGroup, providers payments.Providers, webhookQueue *webhookqueue.Queue) {
+	r.POST("/payment/webhook", handlers.HandleWebhook(providers, webhookQueue))
+	r.POST("/payment/webhook/:provider", handlers.HandleWebhook(providers, webhookQueue))
+}
 
+func RegisterPaymentRoutes(r *gin.RouterGroup, authClient grpc.AuthClient, paymentClient grpc.PaymentClient, orderClient grpc.OrderClient) {
 	r.Use(middleware.AuthMiddleware(authClient))
 	{
 		r.GET("/payment/:id", handlers.GetPayment(paymentClient))
 		r.GET("/payment/order/:id", handlers.GetPaymentByOrderID(paymentClient))
 	}
 
+	// The parent group already authenticates, so admin routes only check the role
+	admin := r.Group("/admin")
+	admin.Use(middleware.RBACMiddleware("admin"))
+	{
+		admin.GET("/payments", handlers.ListPayments(paymentClient))
+		admin.GET("/payments/transaction/:id", handlers.GetPaymentByTransactionID(paymentClient))
+		admin.POST("/payments/:id/refund", handlers.RefundPayment(paymentClient, orderClient))
+	}
 }
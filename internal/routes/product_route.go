@@ -8,12 +8,13 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-func RegisterProductRoutes(r *gin.RouterGroup, cfg *config.Config, authClient grpc.AuthClient, productClient grpc.ProductClient) {
+func RegisterProductRoutes(r *gin.RouterGroup, cfg *config.Config, authClient grpc.AuthClient, productClient grpc.ProductClient, userRateLimit gin.HandlerFunc) {
 	r.GET("/products", handlers.GetProducts(productClient))
 	r.GET("/products/:id", handlers.GetProduct(productClient))
 
 	admin := r.Group("/admin")
 	admin.Use(middleware.AuthMiddleware(authClient))
+	admin.Use(userRateLimit)
 	admin.Use(middleware.RBACMiddleware("admin"))
 	{
 		admin.POST("/products", handlers.CreateProduct(cfg, productClient))
@@ -3,6 +3,10 @@ package routes
 import (
 	"github.com/PharmaKart/gateway-svc/internal/grpc"
 	"github.com/PharmaKart/gateway-svc/internal/handlers"
+	"github.com/PharmaKart/gateway-svc/internal/middleware"
+	"github.com/PharmaKart/gateway-svc/internal/payments"
+	"github.com/PharmaKart/gateway-svc/internal/ratelimit"
+	"github.com/PharmaKart/gateway-svc/internal/webhookqueue"
 	"github.com/PharmaKart/gateway-svc/pkg/config"
 	"github.com/gin-gonic/gin"
 )
@@ -18,23 +22,46 @@ import (
 // @license.url http://www.apache.org/licenses/LICENSE-2.0.html
 // @host localhost:8080
 // @BasePath /
-func RegisterRoutes(r *gin.Engine, cfg *config.Config, authClient grpc.AuthClient, productClient grpc.ProductClient, orderClient grpc.OrderClient, paymentClient grpc.PaymentClient, reminderClient grpc.ReminderClient) {
+func RegisterRoutes(r *gin.Engine, cfg *config.Config, rateLimitStore ratelimit.Store, tokenCache *grpc.TokenCache, breakers []*grpc.CircuitBreaker, healthChecker *grpc.HealthChecker, authClient grpc.AuthClient, productClient grpc.ProductClient, orderClient grpc.OrderClient, paymentClient grpc.PaymentClient, reminderClient grpc.ReminderClient, providers payments.Providers, webhookQueue *webhookqueue.Queue) {
+	var ipLimiter, userLimiter, authLimiter, orderLimiter *middleware.RateLimiter
+	if cfg.RateLimitEnabled {
+		ipLimiter = middleware.NewRateLimiter(rateLimitStore, "ip", cfg.RateLimitRPS, cfg.RateLimitBurst)
+		userLimiter = middleware.NewRateLimiter(rateLimitStore, "user", cfg.UserRateLimitRPS, cfg.UserRateLimitBurst)
+		authLimiter = middleware.NewRateLimiter(rateLimitStore, "auth", cfg.AuthRateLimitRPS, cfg.AuthRateLimitBurst)
+		orderLimiter = middleware.NewRateLimiter(rateLimitStore, "orders", cfg.OrderRateLimitRPS, cfg.OrderRateLimitBurst)
+	}
+	userRateLimit := middleware.RateLimitMiddleware(userLimiter, middleware.KeyByUser)
+
+	// Payment providers retry webhooks in bursts, so webhooks are not throttled with client traffic
+	webhooks := r.Group("/api/v1")
+	webhooks.Use(middleware.RequestTimeout(cfg.RequestTimeout))
+	RegisterWebhookRoutes(webhooks, providers, webhookQueue)
+
 	api := r.Group("/api/v1")
+	api.Use(middleware.RequestTimeout(cfg.RequestTimeout))
+	api.Use(middleware.RateLimitMiddleware(ipLimiter, middleware.KeyByIP))
+
 	// Register auth routes
-	RegisterAuthRoutes(api, authClient)
+	// Token refreshes have their own bucket, so that clients refreshing often cannot lock their users out of login
+	RegisterAuthRoutes(api, authClient, middleware.RateLimitMiddleware(authLimiter, middleware.KeyByRoute("auth")), middleware.RateLimitMiddleware(authLimiter, middleware.KeyByRoute("refresh")))
 
 	// Register product routes
-	RegisterProductRoutes(api, cfg, authClient, productClient)
+	RegisterProductRoutes(api, cfg, authClient, productClient, userRateLimit)
 
 	// Register order routes
-	RegisterOrderRoutes(api, cfg, authClient, orderClient, paymentClient)
+	RegisterOrderRoutes(api, cfg, authClient, orderClient, paymentClient, webhookQueue, userRateLimit, middleware.RateLimitMiddleware(orderLimiter, middleware.KeyByUser))
 
 	// Register payment routes
-	RegisterPaymentRoutes(api, cfg, authClient, paymentClient)
+	RegisterPaymentRoutes(api, authClient, paymentClient, orderClient)
 
 	// Register reminder routes
 	RegisterReminderRoutes(api, authClient, reminderClient)
 
-	// Register health check route
+	// Register gateway admin routes
+	RegisterAdminRoutes(api, authClient, tokenCache, breakers, webhookQueue)
+
+	// Register health check routes
 	r.GET("/health", handlers.HealthCheck)
+	r.GET("/livez", handlers.Livez)
+	r.GET("/readyz", handlers.Readyz(healthChecker))
 }
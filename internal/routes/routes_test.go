@@ -0,0 +1,120 @@
+package routes
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/PharmaKart/gateway-svc/internal/grpc"
+	"github.com/PharmaKart/gateway-svc/internal/payments"
+	"github.com/PharmaKart/gateway-svc/internal/proto"
+	"github.com/PharmaKart/gateway-svc/internal/ratelimit"
+	"github.com/PharmaKart/gateway-svc/pkg/config"
+	"github.com/PharmaKart/gateway-svc/pkg/utils"
+	"github.com/gin-gonic/gin"
+)
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	utils.InitLogger(&config.Config{LogLevel: "panic"})
+	os.Exit(m.Run())
+}
+
+func TestWebhooksAreNotRateLimited(t *testing.T) {
+	cfg := &config.Config{
+		RequestTimeout:   time.Second,
+		RateLimitEnabled: true,
+		RateLimitRPS:     0.001,
+		RateLimitBurst:   1,
+	}
+
+	r := gin.New()
+	RegisterRoutes(r, cfg, ratelimit.NewMemoryStore(), nil, nil, nil, nil, nil, nil, nil, nil, payments.Providers{}, nil)
+
+	// Unknown providers are rejected by the handler, after the rate limiter would have run
+	for i := 0; i < 3; i++ {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/payment/webhook/unknown", nil)
+		req.RemoteAddr = "192.0.2.1:1234"
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		if w.Code != http.StatusNotFound {
+			t.Fatalf("request %d: status = %d, want %d", i+1, w.Code, http.StatusNotFound)
+		}
+	}
+}
+
+func TestMetricsAreNotServedPublicly(t *testing.T) {
+	r := gin.New()
+	RegisterRoutes(r, &config.Config{}, ratelimit.NewMemoryStore(), nil, nil, nil, nil, nil, nil, nil, nil, payments.Providers{}, nil)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+}
+
+// fakeAuthClient accepts every token as a customer's
+type fakeAuthClient struct {
+	grpc.AuthClient
+}
+
+func (fakeAuthClient) VerifyToken(ctx context.Context, req *proto.VerifyTokenRequest) (*proto.VerifyTokenResponse, error) {
+	return &proto.VerifyTokenResponse{Success: true, UserId: "user-1", Role: "customer"}, nil
+}
+
+// serve sends count requests to path from the same client, and returns the last status. Handlers without
+// a backend client panic into a 500, which still shows that the request got past the rate limiters.
+func serve(r *gin.Engine, method, path string, count int) int {
+	var code int
+	for i := 0; i < count; i++ {
+		req := httptest.NewRequest(method, path, nil)
+		req.RemoteAddr = "192.0.2.1:1234"
+		req.Header.Set("Authorization", "Bearer token")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		code = w.Code
+	}
+	return code
+}
+
+func TestRateLimitsStayOnTheirRoutes(t *testing.T) {
+	cfg := &config.Config{
+		RequestTimeout:     time.Second,
+		RateLimitEnabled:   true,
+		RateLimitRPS:       1000,
+		RateLimitBurst:     1000,
+		UserRateLimitRPS:   0.001,
+		UserRateLimitBurst: 1,
+		AuthRateLimitRPS:   0.001,
+		AuthRateLimitBurst: 1,
+	}
+
+	r := gin.New()
+	r.Use(gin.Recovery())
+	RegisterRoutes(r, cfg, ratelimit.NewMemoryStore(), nil, nil, nil, fakeAuthClient{}, nil, nil, nil, nil, payments.Providers{}, nil)
+
+	if code := serve(r, http.MethodGet, "/api/v1/orders", 2); code != http.StatusTooManyRequests {
+		t.Fatalf("orders: status = %d, want %d once the user limit is used up", code, http.StatusTooManyRequests)
+	}
+	// Routes registered after the orders do not inherit their user limit
+	if code := serve(r, http.MethodGet, "/api/v1/reminders", 2); code == http.StatusTooManyRequests {
+		t.Fatal("reminders are limited by the user limit of orders")
+	}
+	if code := serve(r, http.MethodGet, "/api/v1/payment/pay-1", 2); code == http.StatusTooManyRequests {
+		t.Fatal("payments are limited by the user limit of orders")
+	}
+
+	// Refreshing tokens does not use up the logins of the client
+	if code := serve(r, http.MethodPost, "/api/v1/token/refresh", 2); code != http.StatusTooManyRequests {
+		t.Fatalf("refresh: status = %d, want %d once the refresh limit is used up", code, http.StatusTooManyRequests)
+	}
+	if code := serve(r, http.MethodPost, "/api/v1/login", 1); code == http.StatusTooManyRequests {
+		t.Fatal("login is limited by token refreshes")
+	}
+}
@@ -0,0 +1,80 @@
+// Package testutil holds the fixtures shared by the tests of the packages that keep state in memory or in
+// Redis, so that every implementation of a store is tested the same way.
+package testutil
+
+import (
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// Clock is a manual clock for memory stores, which only moves when advanced
+type Clock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// NewClock creates a clock stopped at the current time
+func NewClock() *Clock {
+	return &Clock{now: time.Now()}
+}
+
+// Now returns the time of the clock
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+// Advance moves the clock forward by d
+func (c *Clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+}
+
+// Backend is a store under test, and a way to let time pass for it without sleeping
+type Backend[S io.Closer] struct {
+	Store   S
+	Advance func(d time.Duration)
+}
+
+// Stores returns the memory and Redis implementations of a store, by name. The memory store reads the
+// time from a manual clock, and the Redis store is backed by an in-process server whose clock and key
+// expiry move with it. Both are closed when the test ends.
+func Stores[S io.Closer](t *testing.T, memory func(now func() time.Time) S, newRedis func(client *redis.Client) S) map[string]Backend[S] {
+	t.Helper()
+
+	// Each store has its own clock, since the tests advance them one at a time
+	clock, serverClock := NewClock(), NewClock()
+
+	server := miniredis.RunT(t)
+	server.SetTime(serverClock.Now())
+
+	stores := map[string]Backend[S]{
+		"memory": {
+			Store:   memory(clock.Now),
+			Advance: clock.Advance,
+		},
+		"redis": {
+			Store: newRedis(redis.NewClient(&redis.Options{Addr: server.Addr()})),
+			Advance: func(d time.Duration) {
+				serverClock.Advance(d)
+				server.SetTime(serverClock.Now())
+				server.FastForward(d)
+			},
+		},
+	}
+	t.Cleanup(func() {
+		for _, backend := range stores {
+			backend.Store.Close()
+		}
+	})
+	return stores
+}
@@ -0,0 +1,55 @@
+package tracing
+
+import (
+	"context"
+
+	"github.com/PharmaKart/gateway-svc/pkg/config"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// NewProvider creates a tracer provider that batches spans to exporter. New traces are sampled at
+// sampleRatio, and spans follow the sampling decision of their parent.
+func NewProvider(exporter sdktrace.SpanExporter, serviceName string, sampleRatio float64) (*sdktrace.TracerProvider, error) {
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(
+		attribute.String("service.name", serviceName),
+	))
+	if err != nil {
+		return nil, err
+	}
+
+	return sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(sampleRatio))),
+	), nil
+}
+
+// Init installs the W3C trace context propagator and, when an OTLP endpoint is configured, a global
+// tracer provider exporting over OTLP/HTTP. The exporter reads the standard OTEL_EXPORTER_OTLP_*
+// variables. The returned function flushes pending spans and stops the provider.
+func Init(ctx context.Context, cfg *config.Config) (func(context.Context) error, error) {
+	// Propagate the trace context of the gateway's spans to the backends
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	if !cfg.TracingEnabled || cfg.OTLPEndpoint == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	provider, err := NewProvider(exporter, cfg.TracingServiceName, cfg.TraceSampleRatio)
+	if err != nil {
+		return nil, err
+	}
+	otel.SetTracerProvider(provider)
+
+	return provider.Shutdown, nil
+}
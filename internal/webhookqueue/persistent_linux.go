@@ -0,0 +1,29 @@
+//go:build linux
+
+package webhookqueue
+
+import (
+	"fmt"
+	"syscall"
+)
+
+// volatileFilesystems are the filesystems whose content is lost with the container or the machine
+var volatileFilesystems = map[uint32]string{
+	0x01021994: "tmpfs",
+	0x858458f6: "ramfs",
+	0x794c7630: "overlayfs",
+}
+
+// checkPersistent returns an error when dir is on a filesystem that does not outlive the container,
+// such as its writable overlay layer or a tmpfs, where queued events would be lost on restart
+func checkPersistent(dir string) error {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(dir, &stat); err != nil {
+		return fmt.Errorf("check webhook queue directory %s: %w", dir, err)
+	}
+
+	if name, ok := volatileFilesystems[uint32(stat.Type)]; ok {
+		return fmt.Errorf("%w: %s is on %s", ErrNotPersistent, dir, name)
+	}
+	return nil
+}
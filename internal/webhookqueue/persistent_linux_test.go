@@ -0,0 +1,35 @@
+//go:build linux
+
+package webhookqueue
+
+import (
+	"context"
+	"errors"
+	"os"
+	"syscall"
+	"testing"
+)
+
+func TestNewRequiresPersistentStorage(t *testing.T) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs("/dev/shm", &stat); err != nil || uint32(stat.Type) != 0x01021994 {
+		t.Skip("/dev/shm is not a tmpfs")
+	}
+	dir, err := os.MkdirTemp("/dev/shm", "webhook-queue")
+	if err != nil {
+		t.Skipf("cannot write to /dev/shm: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	handler := func(ctx context.Context, event Event) error { return nil }
+
+	if _, err := New(dir, handler, Options{RequirePersistent: true}); !errors.Is(err, ErrNotPersistent) {
+		t.Fatalf("New on a tmpfs error = %v, want %v", err, ErrNotPersistent)
+	}
+
+	q, err := New(dir, handler, Options{})
+	if err != nil {
+		t.Fatalf("New without RequirePersistent: %v", err)
+	}
+	q.Close(context.Background())
+}
@@ -0,0 +1,8 @@
+//go:build !linux
+
+package webhookqueue
+
+// checkPersistent cannot tell volatile filesystems apart outside Linux, so it accepts every directory
+func checkPersistent(dir string) error {
+	return nil
+}
@@ -0,0 +1,575 @@
+// Package webhookqueue persists verified webhook events before they are acknowledged, and processes
+// them in the background with retries, so that a downstream outage delays events instead of losing them.
+package webhookqueue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/PharmaKart/gateway-svc/pkg/metrics"
+	"github.com/PharmaKart/gateway-svc/pkg/utils"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// idleWait bounds how long an idle worker sleeps before looking for due events again
+const idleWait = time.Minute
+
+var (
+	// ErrNotFound is returned when replaying an event that is not a dead letter
+	ErrNotFound = errors.New("webhook event not found")
+	// ErrNotPersistent means the queue directory would not survive a restart of the gateway
+	ErrNotPersistent = errors.New("webhook queue directory is not on persistent storage")
+)
+
+var queueAttempts = metrics.Factory.NewCounterVec(prometheus.CounterOpts{
+	Name: "gateway_webhook_queue_attempts_total",
+	Help: "Attempts to process queued webhook events, by outcome.",
+}, []string{"source", "result"})
+
+// Event is a verified webhook event waiting to be processed
+type Event struct {
+	ID          string          `json:"id"`
+	Source      string          `json:"source"`
+	Type        string          `json:"type"`
+	Payload     json.RawMessage `json:"payload"`
+	RequestID   string          `json:"request_id,omitempty"`
+	ReceivedAt  time.Time       `json:"received_at"`
+	Attempts    int             `json:"attempts"`
+	NextAttempt time.Time       `json:"next_attempt"`
+	LastError   string          `json:"last_error,omitempty"`
+	FailedAt    *time.Time      `json:"failed_at,omitempty"`
+}
+
+func (e *Event) key() string {
+	return e.Source + ":" + e.ID
+}
+
+// Handler processes an event. Events whose handler fails are retried with exponential backoff,
+// unless the error is marked as permanent.
+type Handler func(ctx context.Context, event Event) error
+
+// permanentError marks a failure that retrying cannot fix
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e *permanentError) Unwrap() error {
+	return e.err
+}
+
+// Permanent marks err as a failure that retrying cannot fix, so that the event is moved to the dead
+// letters without further attempts
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// Options configures the workers and the retry schedule of a Queue. With RequirePersistent, the
+// queue refuses to open a directory whose filesystem would not survive a restart.
+type Options struct {
+	Workers           int
+	MaxAttempts       int
+	InitialBackoff    time.Duration
+	MaxBackoff        time.Duration
+	RequirePersistent bool
+}
+
+// Stats counts the events held by a Queue
+type Stats struct {
+	Pending     int `json:"pending"`
+	DeadLetters int `json:"dead_letters"`
+}
+
+// Queue is a durable queue of webhook events. Events that still fail after MaxAttempts are moved to
+// a dead-letter list, where they stay until they are replayed.
+type Queue struct {
+	store   *fileStore
+	handler Handler
+	opts    Options
+
+	// mu guards the maps below, and is never held during file I/O. Changes to an event are serialized by
+	// its entry in busy instead, so that a slow disk cannot block the workers or the stats.
+	mu       sync.Mutex
+	pending  map[string]*Event
+	inFlight map[string]bool
+	dead     map[string]*Event
+	busy     map[string]chan struct{}
+
+	ctx       context.Context
+	cancel    context.CancelFunc
+	wake      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+// New opens the queue stored in dir, resumes the events left pending by a previous run and starts
+// the workers
+func New(dir string, handler Handler, opts Options) (*Queue, error) {
+	if opts.Workers <= 0 {
+		opts.Workers = 1
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = 1
+	}
+	if opts.MaxBackoff < opts.InitialBackoff {
+		opts.MaxBackoff = opts.InitialBackoff
+	}
+
+	store, err := newFileStore(dir)
+	if err != nil {
+		return nil, err
+	}
+	if opts.RequirePersistent {
+		if err := checkPersistent(dir); err != nil {
+			return nil, err
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	q := &Queue{
+		store:    store,
+		handler:  handler,
+		opts:     opts,
+		pending:  make(map[string]*Event),
+		inFlight: make(map[string]bool),
+		dead:     make(map[string]*Event),
+		busy:     make(map[string]chan struct{}),
+		ctx:      ctx,
+		cancel:   cancel,
+		wake:     make(chan struct{}, opts.Workers),
+		done:     make(chan struct{}),
+	}
+
+	pending, errs := store.load(pendingDir)
+	dead, deadErrs := store.load(deadDir)
+	for _, err := range append(errs, deadErrs...) {
+		utils.Error("Failed to load queued webhook event", map[string]interface{}{
+			"error": err,
+		})
+	}
+	for _, event := range pending {
+		q.pending[event.key()] = event
+	}
+	for _, event := range dead {
+		q.dead[event.key()] = event
+	}
+
+	if len(q.pending) > 0 || len(q.dead) > 0 {
+		utils.Info("Resuming webhook queue", map[string]interface{}{
+			"pending":      len(q.pending),
+			"dead_letters": len(q.dead),
+		})
+	}
+
+	for i := 0; i < opts.Workers; i++ {
+		q.wg.Add(1)
+		go q.work()
+	}
+
+	return q, nil
+}
+
+// Enqueue persists event so that it is processed even if the gateway stops before processing it.
+// An event that is already pending is not queued again.
+func (q *Queue) Enqueue(event Event) error {
+	now := time.Now()
+	event.ReceivedAt = now
+	event.NextAttempt = now
+	event.Attempts = 0
+
+	key := event.key()
+	unlock := q.lockEvent(key)
+	defer unlock()
+
+	q.mu.Lock()
+	_, pending := q.pending[key]
+	dead := q.dead[key]
+	q.mu.Unlock()
+
+	if pending {
+		return nil
+	}
+
+	if err := q.store.save(pendingDir, &event); err != nil {
+		return err
+	}
+	if dead != nil {
+		// A new delivery supersedes the dead letter
+		if err := q.store.remove(deadDir, dead); err != nil {
+			utils.Error("Failed to remove dead webhook event", map[string]interface{}{
+				"error": err,
+				"event": event.ID,
+			})
+		}
+	}
+
+	q.mu.Lock()
+	delete(q.dead, key)
+	q.pending[key] = &event
+	q.mu.Unlock()
+
+	q.notify()
+	return nil
+}
+
+// lockEvent waits until no other goroutine is changing the event with the given key, and returns the
+// function that lets them proceed
+func (q *Queue) lockEvent(key string) (unlock func()) {
+	q.mu.Lock()
+	for {
+		busy, ok := q.busy[key]
+		if !ok {
+			break
+		}
+		q.mu.Unlock()
+		<-busy
+		q.mu.Lock()
+	}
+
+	busy := make(chan struct{})
+	q.busy[key] = busy
+	q.mu.Unlock()
+
+	return func() {
+		q.mu.Lock()
+		delete(q.busy, key)
+		q.mu.Unlock()
+		close(busy)
+	}
+}
+
+// Stats returns the number of pending and dead-lettered events
+func (q *Queue) Stats() Stats {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return Stats{
+		Pending:     len(q.pending),
+		DeadLetters: len(q.dead),
+	}
+}
+
+// RegisterMetrics exports the number of pending and dead-lettered events of q
+func RegisterMetrics(q *Queue) {
+	for state, count := range map[string]func(Stats) int{
+		"pending": func(s Stats) int { return s.Pending },
+		"dead":    func(s Stats) int { return s.DeadLetters },
+	} {
+		metrics.Factory.NewGaugeFunc(prometheus.GaugeOpts{
+			Name:        "gateway_webhook_queue_events",
+			Help:        "Webhook events waiting to be processed or dead-lettered, by state.",
+			ConstLabels: prometheus.Labels{"state": state},
+		}, func() float64 {
+			return float64(count(q.Stats()))
+		})
+	}
+}
+
+// DeadLetters returns the events that exhausted their attempts, most recent failure first
+func (q *Queue) DeadLetters() []Event {
+	q.mu.Lock()
+	events := make([]Event, 0, len(q.dead))
+	for _, event := range q.dead {
+		events = append(events, *event)
+	}
+	q.mu.Unlock()
+
+	sort.Slice(events, func(i, j int) bool {
+		return failedAt(events[i]).After(failedAt(events[j]))
+	})
+	return events
+}
+
+// Replay moves the dead letter with the given event ID back to the queue, with a fresh set of attempts
+func (q *Queue) Replay(eventID string) error {
+	q.mu.Lock()
+	var key string
+	for k, event := range q.dead {
+		if event.ID == eventID {
+			key = k
+			break
+		}
+	}
+	q.mu.Unlock()
+
+	if key == "" {
+		return ErrNotFound
+	}
+	return q.replay(key)
+}
+
+// ReplayAll moves every dead letter back to the queue and returns how many were replayed
+func (q *Queue) ReplayAll() (int, error) {
+	q.mu.Lock()
+	keys := make([]string, 0, len(q.dead))
+	for key := range q.dead {
+		keys = append(keys, key)
+	}
+	q.mu.Unlock()
+
+	replayed := 0
+	for _, key := range keys {
+		err := q.replay(key)
+		if errors.Is(err, ErrNotFound) {
+			// Superseded by a new delivery meanwhile
+			continue
+		}
+		if err != nil {
+			return replayed, err
+		}
+		replayed++
+	}
+	return replayed, nil
+}
+
+func (q *Queue) replay(key string) error {
+	unlock := q.lockEvent(key)
+	defer unlock()
+
+	q.mu.Lock()
+	event, ok := q.dead[key]
+	q.mu.Unlock()
+
+	if !ok {
+		return ErrNotFound
+	}
+
+	replayed := *event
+	replayed.Attempts = 0
+	replayed.NextAttempt = time.Now()
+	replayed.FailedAt = nil
+
+	if err := q.store.save(pendingDir, &replayed); err != nil {
+		return err
+	}
+	if err := q.store.remove(deadDir, event); err != nil {
+		utils.Error("Failed to remove dead webhook event", map[string]interface{}{
+			"error": err,
+			"event": event.ID,
+		})
+	}
+
+	q.mu.Lock()
+	delete(q.dead, key)
+	q.pending[key] = &replayed
+	q.mu.Unlock()
+
+	q.notify()
+	return nil
+}
+
+// Close stops the workers. Events being processed when ctx expires are cancelled, and stay queued
+// for the next run. Closing the queue again waits for the workers too.
+func (q *Queue) Close(ctx context.Context) error {
+	q.closeOnce.Do(func() { close(q.done) })
+
+	stopped := make(chan struct{})
+	go func() {
+		q.wg.Wait()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+		q.cancel()
+		return nil
+	case <-ctx.Done():
+		q.cancel()
+		<-stopped
+		return ctx.Err()
+	}
+}
+
+func (q *Queue) notify() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (q *Queue) work() {
+	defer q.wg.Done()
+
+	for {
+		select {
+		case <-q.done:
+			return
+		default:
+		}
+
+		event, wait := q.next()
+		if event != nil {
+			q.process(event)
+			continue
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-q.done:
+			timer.Stop()
+			return
+		case <-q.wake:
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
+
+// next takes the oldest due event, or returns how long to wait for the next one to become due
+func (q *Queue) next() (*Event, time.Duration) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	now := time.Now()
+	wait := idleWait
+	var due *Event
+	for key, event := range q.pending {
+		if q.inFlight[key] {
+			continue
+		}
+		if event.NextAttempt.After(now) {
+			wait = min(wait, event.NextAttempt.Sub(now))
+			continue
+		}
+		if due == nil || event.ReceivedAt.Before(due.ReceivedAt) {
+			due = event
+		}
+	}
+
+	if due != nil {
+		q.inFlight[due.key()] = true
+	}
+	return due, wait
+}
+
+func (q *Queue) process(event *Event) {
+	ctx := q.ctx
+	if event.RequestID != "" {
+		ctx = utils.ContextWithRequestID(ctx, event.RequestID)
+	}
+
+	err := q.handle(ctx, *event)
+
+	key := event.key()
+	unlock := q.lockEvent(key)
+	defer unlock()
+
+	if err == nil {
+		if err := q.store.remove(pendingDir, event); err != nil {
+			utils.ErrorContext(ctx, "Failed to remove processed webhook event", map[string]interface{}{
+				"error": err,
+				"event": event.ID,
+			})
+		}
+
+		q.mu.Lock()
+		delete(q.pending, key)
+		delete(q.inFlight, key)
+		q.mu.Unlock()
+
+		queueAttempts.WithLabelValues(event.Source, "succeeded").Inc()
+		return
+	}
+
+	// Events interrupted by shutdown are not charged an attempt
+	if q.ctx.Err() != nil {
+		q.mu.Lock()
+		delete(q.inFlight, key)
+		q.mu.Unlock()
+		return
+	}
+
+	// Workers only share the event through the maps, which are updated once the file is written
+	failed := *event
+	failed.Attempts++
+	failed.LastError = err.Error()
+
+	if failed.Attempts >= q.opts.MaxAttempts || errors.As(err, new(*permanentError)) {
+		now := time.Now()
+		failed.FailedAt = &now
+		if err := q.store.move(pendingDir, deadDir, &failed); err != nil {
+			utils.ErrorContext(ctx, "Failed to dead-letter webhook event", map[string]interface{}{
+				"error": err,
+				"event": event.ID,
+			})
+		}
+
+		q.mu.Lock()
+		delete(q.pending, key)
+		delete(q.inFlight, key)
+		q.dead[key] = &failed
+		q.mu.Unlock()
+
+		queueAttempts.WithLabelValues(event.Source, "dead_lettered").Inc()
+
+		utils.ErrorContext(ctx, "Webhook event cannot be processed, moved to dead letters", map[string]interface{}{
+			"error":    err,
+			"event":    event.ID,
+			"type":     event.Type,
+			"attempts": failed.Attempts,
+		})
+		return
+	}
+
+	failed.NextAttempt = time.Now().Add(q.backoff(failed.Attempts))
+	if err := q.store.save(pendingDir, &failed); err != nil {
+		utils.ErrorContext(ctx, "Failed to persist webhook event retry", map[string]interface{}{
+			"error": err,
+			"event": event.ID,
+		})
+	}
+
+	q.mu.Lock()
+	q.pending[key] = &failed
+	delete(q.inFlight, key)
+	q.mu.Unlock()
+
+	queueAttempts.WithLabelValues(event.Source, "retried").Inc()
+
+	utils.WarnContext(ctx, "Webhook event failed, retrying", map[string]interface{}{
+		"error":        err,
+		"event":        event.ID,
+		"type":         event.Type,
+		"attempts":     failed.Attempts,
+		"next_attempt": failed.NextAttempt,
+	})
+}
+
+// handle runs the handler, turning a panic into a failed attempt so that it cannot stop the gateway
+func (q *Queue) handle(ctx context.Context, event Event) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic while processing webhook event: %v", r)
+		}
+	}()
+
+	return q.handler(ctx, event)
+}
+
+// backoff doubles the delay after each failed attempt, up to MaxBackoff
+func (q *Queue) backoff(attempts int) time.Duration {
+	delay := q.opts.InitialBackoff
+	for i := 1; i < attempts && delay < q.opts.MaxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, q.opts.MaxBackoff)
+}
+
+func failedAt(event Event) time.Time {
+	if event.FailedAt == nil {
+		return time.Time{}
+	}
+	return *event.FailedAt
+}
@@ -0,0 +1,368 @@
+package webhookqueue
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/PharmaKart/gateway-svc/pkg/config"
+	"github.com/PharmaKart/gateway-svc/pkg/utils"
+)
+
+func TestMain(m *testing.M) {
+	utils.InitLogger(&config.Config{LogLevel: "panic"})
+	os.Exit(m.Run())
+}
+
+// recorder is a Handler that fails each event a number of times and records every attempt
+type recorder struct {
+	mu       sync.Mutex
+	failures map[string][]error
+	attempts map[string]int
+	done     chan string
+}
+
+func newRecorder() *recorder {
+	return &recorder{
+		failures: make(map[string][]error),
+		attempts: make(map[string]int),
+		done:     make(chan string, 100),
+	}
+}
+
+// failWith makes the next attempts of the event fail with errs, in order
+func (r *recorder) failWith(eventID string, errs ...error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.failures[eventID] = append(r.failures[eventID], errs...)
+}
+
+func (r *recorder) handle(ctx context.Context, event Event) error {
+	r.mu.Lock()
+	r.attempts[event.ID]++
+	var err error
+	if failures := r.failures[event.ID]; len(failures) > 0 {
+		err = failures[0]
+		r.failures[event.ID] = failures[1:]
+	}
+	r.mu.Unlock()
+
+	if err == nil {
+		r.done <- event.ID
+	}
+	return err
+}
+
+func (r *recorder) attemptsOf(eventID string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.attempts[eventID]
+}
+
+func (r *recorder) waitDone(t *testing.T, eventID string) {
+	t.Helper()
+
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case id := <-r.done:
+			if id == eventID {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("event %s was not processed", eventID)
+		}
+	}
+}
+
+var testOptions = Options{
+	Workers:        2,
+	MaxAttempts:    3,
+	InitialBackoff: time.Millisecond,
+	MaxBackoff:     5 * time.Millisecond,
+}
+
+func openQueue(t *testing.T, dir string, handler Handler, opts Options) *Queue {
+	t.Helper()
+
+	q, err := New(dir, handler, opts)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	t.Cleanup(func() { q.Close(context.Background()) })
+	return q
+}
+
+// waitFor polls until condition holds
+func waitFor(t *testing.T, what string, condition func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func files(t *testing.T, dir, sub string) int {
+	t.Helper()
+
+	matches, err := filepath.Glob(filepath.Join(dir, sub, "*.json"))
+	if err != nil {
+		t.Fatalf("glob: %v", err)
+	}
+	return len(matches)
+}
+
+func TestQueueRetriesUntilSuccess(t *testing.T) {
+	dir := t.TempDir()
+	r := newRecorder()
+	r.failWith("evt_1", errors.New("payment-svc is down"), errors.New("payment-svc is down"))
+	q := openQueue(t, dir, r.handle, testOptions)
+
+	if err := q.Enqueue(Event{ID: "evt_1", Source: "stripe", Type: "charge.succeeded", Payload: []byte(`{}`)}); err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+	r.waitDone(t, "evt_1")
+	waitFor(t, "the event to leave the queue", func() bool { return q.Stats() == Stats{} })
+
+	if got := r.attemptsOf("evt_1"); got != 3 {
+		t.Fatalf("attempts = %d, want 3", got)
+	}
+	if n := files(t, dir, pendingDir) + files(t, dir, deadDir); n != 0 {
+		t.Fatalf("%d event files left, want none", n)
+	}
+}
+
+func TestQueueDeadLetters(t *testing.T) {
+	tests := []struct {
+		name         string
+		errs         []error
+		wantAttempts int
+	}{
+		{
+			name:         "attempts exhausted",
+			errs:         []error{errors.New("down"), errors.New("down"), errors.New("still down")},
+			wantAttempts: 3,
+		},
+		{
+			name:         "permanent failure",
+			errs:         []error{Permanent(errors.New("malformed event"))},
+			wantAttempts: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			r := newRecorder()
+			r.failWith("evt_1", tt.errs...)
+			q := openQueue(t, dir, r.handle, testOptions)
+
+			if err := q.Enqueue(Event{ID: "evt_1", Source: "stripe", Type: "charge.refunded"}); err != nil {
+				t.Fatalf("Enqueue: %v", err)
+			}
+			waitFor(t, "the dead letter", func() bool { return q.Stats().DeadLetters == 1 })
+
+			dead := q.DeadLetters()
+			if len(dead) != 1 {
+				t.Fatalf("dead letters = %d, want 1", len(dead))
+			}
+			last := tt.errs[len(tt.errs)-1].Error()
+			if dead[0].Attempts != tt.wantAttempts || dead[0].LastError != last || dead[0].FailedAt == nil {
+				t.Fatalf("dead letter = %+v, want %d attempts failing with %q", dead[0], tt.wantAttempts, last)
+			}
+			if r.attemptsOf("evt_1") != tt.wantAttempts {
+				t.Fatalf("handler attempts = %d, want %d", r.attemptsOf("evt_1"), tt.wantAttempts)
+			}
+			if q.Stats().Pending != 0 || files(t, dir, pendingDir) != 0 || files(t, dir, deadDir) != 1 {
+				t.Fatalf("stats = %+v, want the event only in the dead letters", q.Stats())
+			}
+
+			// A replayed event gets a fresh set of attempts
+			if err := q.Replay("evt_1"); err != nil {
+				t.Fatalf("Replay: %v", err)
+			}
+			r.waitDone(t, "evt_1")
+			waitFor(t, "the replayed event to leave the queue", func() bool { return q.Stats() == Stats{} })
+
+			if err := q.Replay("evt_1"); !errors.Is(err, ErrNotFound) {
+				t.Fatalf("second Replay error = %v, want %v", err, ErrNotFound)
+			}
+		})
+	}
+}
+
+func TestQueueReplayAll(t *testing.T) {
+	r := newRecorder()
+	q := openQueue(t, t.TempDir(), r.handle, Options{MaxAttempts: 1})
+
+	for _, id := range []string{"evt_1", "evt_2", "evt_3"} {
+		r.failWith(id, errors.New("down"))
+		if err := q.Enqueue(Event{ID: id, Source: "stripe"}); err != nil {
+			t.Fatalf("Enqueue: %v", err)
+		}
+	}
+	waitFor(t, "the dead letters", func() bool { return q.Stats().DeadLetters == 3 })
+
+	replayed, err := q.ReplayAll()
+	if err != nil || replayed != 3 {
+		t.Fatalf("ReplayAll = %d, %v, want 3", replayed, err)
+	}
+	waitFor(t, "the replayed events to leave the queue", func() bool { return q.Stats() == Stats{} })
+}
+
+func TestQueueEnqueue(t *testing.T) {
+	block := make(chan struct{})
+	r := newRecorder()
+	handler := func(ctx context.Context, event Event) error {
+		<-block
+		return r.handle(ctx, event)
+	}
+	q := openQueue(t, t.TempDir(), handler, Options{MaxAttempts: 1})
+
+	// Redeliveries of a pending event are not queued twice
+	for i := 0; i < 3; i++ {
+		if err := q.Enqueue(Event{ID: "evt_1", Source: "stripe"}); err != nil {
+			t.Fatalf("Enqueue: %v", err)
+		}
+	}
+	// Event IDs are scoped by source
+	if err := q.Enqueue(Event{ID: "evt_1", Source: "paypal"}); err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+	if stats := q.Stats(); stats.Pending != 2 {
+		t.Fatalf("pending = %d, want 2", stats.Pending)
+	}
+
+	close(block)
+	waitFor(t, "the events to be processed", func() bool { return q.Stats() == Stats{} })
+	if got := r.attemptsOf("evt_1"); got != 2 {
+		t.Fatalf("evt_1 was processed %d times, want once per source", got)
+	}
+}
+
+func TestQueueNewDeliverySupersedesDeadLetter(t *testing.T) {
+	r := newRecorder()
+	r.failWith("evt_1", errors.New("down"))
+	q := openQueue(t, t.TempDir(), r.handle, Options{MaxAttempts: 1})
+
+	if err := q.Enqueue(Event{ID: "evt_1", Source: "stripe"}); err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+	waitFor(t, "the dead letter", func() bool { return q.Stats().DeadLetters == 1 })
+
+	if err := q.Enqueue(Event{ID: "evt_1", Source: "stripe"}); err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+	r.waitDone(t, "evt_1")
+	waitFor(t, "the event to leave the queue", func() bool { return q.Stats() == Stats{} })
+}
+
+func TestQueueRecoversFromPanics(t *testing.T) {
+	var once sync.Once
+	r := newRecorder()
+	handler := func(ctx context.Context, event Event) error {
+		once.Do(func() { panic("nil map") })
+		return r.handle(ctx, event)
+	}
+	q := openQueue(t, t.TempDir(), handler, testOptions)
+
+	if err := q.Enqueue(Event{ID: "evt_1", Source: "stripe"}); err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+	r.waitDone(t, "evt_1")
+}
+
+func TestQueueCloseTwice(t *testing.T) {
+	q := openQueue(t, t.TempDir(), newRecorder().handle, testOptions)
+
+	// Shutdown closes the queue before the cleanup of openQueue does
+	for i := 0; i < 2; i++ {
+		if err := q.Close(context.Background()); err != nil {
+			t.Fatalf("close %d: %v", i+1, err)
+		}
+	}
+}
+
+func TestQueueResumesAfterRestart(t *testing.T) {
+	dir := t.TempDir()
+
+	// The first run stops while an event is being processed, and leaves a dead letter behind
+	started := make(chan struct{})
+	first, err := New(dir, func(ctx context.Context, event Event) error {
+		if event.ID == "evt_dead" {
+			return Permanent(errors.New("malformed"))
+		}
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	}, Options{Workers: 1, MaxAttempts: 3})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if err := first.Enqueue(Event{ID: "evt_dead", Source: "stripe"}); err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+	waitFor(t, "the dead letter", func() bool { return first.Stats().DeadLetters == 1 })
+	if err := first.Enqueue(Event{ID: "evt_1", Source: "stripe", RequestID: "req-1"}); err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := first.Close(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Close error = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	r := newRecorder()
+	var requestID string
+	second := openQueue(t, dir, func(ctx context.Context, event Event) error {
+		requestID = utils.RequestIDFromContext(ctx)
+		return r.handle(ctx, event)
+	}, testOptions)
+
+	if stats := second.Stats(); stats.DeadLetters != 1 {
+		t.Fatalf("stats after restart = %+v, want the dead letter kept", stats)
+	}
+	r.waitDone(t, "evt_1")
+	if requestID != "req-1" {
+		t.Fatalf("request ID = %q, want the one of the delivery", requestID)
+	}
+	if r.attemptsOf("evt_dead") != 0 {
+		t.Fatal("dead letter was processed without a replay")
+	}
+	waitFor(t, "the resumed event to leave the queue", func() bool { return second.Stats().Pending == 0 })
+
+	// The interrupted attempt was not charged
+	dead := second.DeadLetters()
+	if len(dead) != 1 || dead[0].ID != "evt_dead" {
+		t.Fatalf("dead letters = %+v, want evt_dead", dead)
+	}
+}
+
+func TestQueueBackoff(t *testing.T) {
+	q := &Queue{opts: Options{InitialBackoff: time.Second, MaxBackoff: 10 * time.Second}}
+
+	for attempts, want := range map[int]time.Duration{
+		1: time.Second,
+		2: 2 * time.Second,
+		3: 4 * time.Second,
+		4: 8 * time.Second,
+		5: 10 * time.Second,
+		9: 10 * time.Second,
+	} {
+		if got := q.backoff(attempts); got != want {
+			t.Errorf("backoff(%d) = %v, want %v", attempts, got, want)
+		}
+	}
+}
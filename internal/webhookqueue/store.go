@@ -0,0 +1,126 @@
+package webhookqueue
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	pendingDir = "pending"
+	deadDir    = "dead"
+)
+
+// fileStore keeps one JSON file per event, in a directory for pending events and one for dead letters.
+// Files are written to a temporary name, synced and renamed, so a crash never leaves a partial event.
+type fileStore struct {
+	dir string
+}
+
+func newFileStore(dir string) (*fileStore, error) {
+	for _, sub := range []string{pendingDir, deadDir} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0o700); err != nil {
+			return nil, err
+		}
+	}
+
+	return &fileStore{dir: dir}, nil
+}
+
+// fileName encodes the event key, since event IDs are chosen by the provider
+func fileName(key string) string {
+	return hex.EncodeToString([]byte(key)) + ".json"
+}
+
+func (s *fileStore) save(sub string, event *Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Join(s.dir, sub)
+	tmp, err := os.CreateTemp(dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp.Name(), filepath.Join(dir, fileName(event.key()))); err != nil {
+		return err
+	}
+	return syncDir(dir)
+}
+
+func (s *fileStore) remove(sub string, event *Event) error {
+	err := os.Remove(filepath.Join(s.dir, sub, fileName(event.key())))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// move saves event under to before removing it from from, so that it is never lost in between
+func (s *fileStore) move(from, to string, event *Event) error {
+	if err := s.save(to, event); err != nil {
+		return err
+	}
+	return s.remove(from, event)
+}
+
+// load reads every event in sub. Unreadable files are skipped and returned as errors.
+func (s *fileStore) load(sub string) ([]*Event, []error) {
+	dir := filepath.Join(s.dir, sub)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, []error{err}
+	}
+
+	var events []*Event
+	var errs []error
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+
+		path := filepath.Join(dir, entry.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		var event Event
+		if err := json.Unmarshal(data, &event); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", path, err))
+			continue
+		}
+		events = append(events, &event)
+	}
+	return events, errs
+}
+
+func syncDir(dir string) error {
+	f, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return f.Sync()
+}
@@ -3,20 +3,129 @@ package config
 import (
 	"log"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
 type Config struct {
 	Port                string
+	MetricsPort         string
 	AuthServiceURL      string
 	ProductServiceURL   string
 	OrderServiceURL     string
 	PaymentServiceURL   string
 	ReminderServiceURL  string
 	StripeWebhookSecret string
+	PayPalWebhookID     string
 	S3Bucket            string
 	AwsRegion           string
+
+	// Timeouts
+	RequestTimeout         time.Duration
+	AuthServiceTimeout     time.Duration
+	ProductServiceTimeout  time.Duration
+	OrderServiceTimeout    time.Duration
+	PaymentServiceTimeout  time.Duration
+	ReminderServiceTimeout time.Duration
+	GrpcMethodTimeouts     map[string]time.Duration
+
+	// Retries of idempotent gRPC calls
+	RetryMaxAttempts       int
+	RetryInitialBackoff    time.Duration
+	RetryMaxBackoff        time.Duration
+	RetryBackoffMultiplier float64
+	RetryableCodes         []string
+
+	// Circuit breakers
+	CircuitBreakerFailureThreshold int
+	CircuitBreakerOpenTimeout      time.Duration
+	CircuitBreakerHalfOpenMaxCalls int
+
+	// Graceful shutdown
+	ShutdownDrainDelay  time.Duration
+	ShutdownGracePeriod time.Duration
+
+	// Health checks
+	HealthCheckTimeout   time.Duration
+	CriticalDependencies []string
+
+	// Error responses
+	ErrorFormat        string
+	ProblemTypeBaseURI string
+
+	// Rate limiting. By default a customer may place 5 orders at once, e.g. retrying a failed
+	// checkout, then one every 10 seconds.
+	RateLimitEnabled    bool
+	RateLimitRPS        float64
+	RateLimitBurst      int
+	UserRateLimitRPS    float64
+	UserRateLimitBurst  int
+	AuthRateLimitRPS    float64
+	AuthRateLimitBurst  int
+	OrderRateLimitRPS   float64
+	OrderRateLimitBurst int
+	RateLimitStore      string
+	RedisAddr           string
+	RedisPassword       string
+	RedisDB             int
+
+	// Proxies whose X-Forwarded-For header is trusted to identify clients. Clients are identified by
+	// the connection address when empty.
+	TrustedProxies []string
+
+	// Token verification cache
+	TokenCacheSize        int
+	TokenCacheTTL         time.Duration
+	TokenCacheNegativeTTL time.Duration
+	TokenRevocationTTL    time.Duration
+	TokenRevocationStore  string
+
+	// Local JWT verification, enabled when JWKSURL is set
+	JWKSURL             string
+	JWKSRefreshInterval time.Duration
+	JWTIssuer           string
+	JWTAudience         string
+	JWTLeeway           time.Duration
+
+	// Distributed tracing, exported over OTLP/HTTP when OTLPEndpoint is set
+	TracingEnabled     bool
+	OTLPEndpoint       string
+	TracingServiceName string
+	TraceSampleRatio   float64
+
+	// Logging
+	LogLevel              string
+	LogFormat             string
+	LogSamplingInitial    int
+	LogSamplingThereafter int
+	LogSamplingInterval   time.Duration
+
+	// Request paths left out of the access log, with a trailing * matching any suffix
+	AccessLogExcludePaths []string
+
+	// Stripe endpoint secrets that are still accepted while the current secret is rotated in, and how old
+	// a signature may be
+	StripeWebhookPreviousSecrets []string
+	StripeWebhookTolerance       time.Duration
+
+	// How far the transmission time of a PayPal delivery may be from now
+	PayPalWebhookTolerance time.Duration
+
+	// Webhook deduplication
+	WebhookEventStore     string
+	WebhookEventLease     time.Duration
+	WebhookEventRetention time.Duration
+
+	// Durable queue of verified webhook events awaiting processing
+	WebhookQueueDir               string
+	WebhookQueueRequirePersistent bool
+	WebhookQueueWorkers           int
+	WebhookQueueMaxAttempts       int
+	WebhookQueueInitialBackoff    time.Duration
+	WebhookQueueMaxBackoff        time.Duration
 }
 
 func LoadConfig() *Config {
@@ -27,14 +136,100 @@ func LoadConfig() *Config {
 
 	return &Config{
 		Port:                getEnv("PORT", "8080"),
+		MetricsPort:         getEnv("METRICS_PORT", "9090"),
 		AuthServiceURL:      getEnv("AUTH_SERVICE_URL", "localhost:50051"),
 		ProductServiceURL:   getEnv("PRODUCT_SERVICE_URL", "localhost:50052"),
 		OrderServiceURL:     getEnv("ORDER_SERVICE_URL", "localhost:50053"),
 		PaymentServiceURL:   getEnv("PAYMENT_SERVICE_URL", "localhost:50054"),
 		ReminderServiceURL:  getEnv("REMINDER_SERVICE_URL", "localhost:50055"),
 		StripeWebhookSecret: getEnv("STRIPE_WEBHOOK_SECRET", "whsec_your_stripe_webhook_secret"),
+		PayPalWebhookID:     getEnv("PAYPAL_WEBHOOK_ID", ""),
 		S3Bucket:            getEnv("S3_BUCKET_NAME", "your_s3_bucket"),
 		AwsRegion:           getEnv("AWS_REGION", "ca-central-1"),
+
+		RequestTimeout:         getEnvDuration("REQUEST_TIMEOUT", 30*time.Second),
+		AuthServiceTimeout:     getEnvDuration("AUTH_SERVICE_TIMEOUT", 3*time.Second),
+		ProductServiceTimeout:  getEnvDuration("PRODUCT_SERVICE_TIMEOUT", 5*time.Second),
+		OrderServiceTimeout:    getEnvDuration("ORDER_SERVICE_TIMEOUT", 10*time.Second),
+		PaymentServiceTimeout:  getEnvDuration("PAYMENT_SERVICE_TIMEOUT", 10*time.Second),
+		ReminderServiceTimeout: getEnvDuration("REMINDER_SERVICE_TIMEOUT", 5*time.Second),
+		GrpcMethodTimeouts:     getEnvDurationMap("GRPC_METHOD_TIMEOUTS"),
+
+		RetryMaxAttempts:       getEnvInt("RETRY_MAX_ATTEMPTS", 3),
+		RetryInitialBackoff:    getEnvDuration("RETRY_INITIAL_BACKOFF", 100*time.Millisecond),
+		RetryMaxBackoff:        getEnvDuration("RETRY_MAX_BACKOFF", time.Second),
+		RetryBackoffMultiplier: getEnvFloat("RETRY_BACKOFF_MULTIPLIER", 2),
+		RetryableCodes:         getEnvList("RETRYABLE_CODES", []string{"UNAVAILABLE", "RESOURCE_EXHAUSTED"}),
+
+		CircuitBreakerFailureThreshold: getEnvInt("CIRCUIT_BREAKER_FAILURE_THRESHOLD", 5),
+		CircuitBreakerOpenTimeout:      getEnvDuration("CIRCUIT_BREAKER_OPEN_TIMEOUT", 30*time.Second),
+		CircuitBreakerHalfOpenMaxCalls: getEnvInt("CIRCUIT_BREAKER_HALF_OPEN_MAX_CALLS", 1),
+
+		ShutdownDrainDelay:  getEnvDuration("SHUTDOWN_DRAIN_DELAY", 5*time.Second),
+		ShutdownGracePeriod: getEnvDuration("SHUTDOWN_GRACE_PERIOD", 30*time.Second),
+
+		HealthCheckTimeout:   getEnvDuration("HEALTH_CHECK_TIMEOUT", 2*time.Second),
+		CriticalDependencies: getEnvList("CRITICAL_DEPENDENCIES", []string{"auth-svc", "product-svc", "order-svc", "payment-svc"}),
+
+		ErrorFormat:        getEnv("ERROR_FORMAT", "legacy"),
+		ProblemTypeBaseURI: getEnv("PROBLEM_TYPE_BASE_URI", "https://api.pharmakart.com/problems/"),
+
+		RateLimitEnabled:    getEnvBool("RATE_LIMIT_ENABLED", true),
+		RateLimitRPS:        getEnvFloat("RATE_LIMIT_RPS", 20),
+		RateLimitBurst:      getEnvInt("RATE_LIMIT_BURST", 40),
+		UserRateLimitRPS:    getEnvFloat("USER_RATE_LIMIT_RPS", 10),
+		UserRateLimitBurst:  getEnvInt("USER_RATE_LIMIT_BURST", 20),
+		AuthRateLimitRPS:    getEnvFloat("AUTH_RATE_LIMIT_RPS", 0.1),
+		AuthRateLimitBurst:  getEnvInt("AUTH_RATE_LIMIT_BURST", 5),
+		OrderRateLimitRPS:   getEnvFloat("ORDER_RATE_LIMIT_RPS", 0.1),
+		OrderRateLimitBurst: getEnvInt("ORDER_RATE_LIMIT_BURST", 5),
+		RateLimitStore:      getEnv("RATE_LIMIT_STORE", "memory"),
+		RedisAddr:           getEnv("REDIS_ADDR", "localhost:6379"),
+		RedisPassword:       getEnv("REDIS_PASSWORD", ""),
+		RedisDB:             getEnvInt("REDIS_DB", 0),
+
+		TrustedProxies: getEnvList("TRUSTED_PROXIES", nil),
+
+		TokenCacheSize:        getEnvInt("TOKEN_CACHE_SIZE", 10000),
+		TokenCacheTTL:         getEnvDuration("TOKEN_CACHE_TTL", 5*time.Minute),
+		TokenCacheNegativeTTL: getEnvDuration("TOKEN_CACHE_NEGATIVE_TTL", 30*time.Second),
+		TokenRevocationTTL:    getEnvDuration("TOKEN_REVOCATION_TTL", 24*time.Hour),
+		TokenRevocationStore:  getEnv("TOKEN_REVOCATION_STORE", "memory"),
+
+		JWKSURL:             getEnv("JWKS_URL", ""),
+		JWKSRefreshInterval: getEnvDuration("JWKS_REFRESH_INTERVAL", 10*time.Minute),
+		JWTIssuer:           getEnv("JWT_ISSUER", ""),
+		JWTAudience:         getEnv("JWT_AUDIENCE", ""),
+		JWTLeeway:           getEnvDuration("JWT_LEEWAY", 30*time.Second),
+
+		TracingEnabled:     getEnvBool("TRACING_ENABLED", true),
+		OTLPEndpoint:       getEnv("OTEL_EXPORTER_OTLP_ENDPOINT", ""),
+		TracingServiceName: getEnv("OTEL_SERVICE_NAME", "gateway-svc"),
+		TraceSampleRatio:   getEnvFloat("TRACE_SAMPLE_RATIO", 1),
+
+		LogLevel:              getEnv("LOG_LEVEL", "info"),
+		LogFormat:             getEnv("LOG_FORMAT", "json"),
+		LogSamplingInitial:    getEnvInt("LOG_SAMPLING_INITIAL", 0),
+		LogSamplingThereafter: getEnvInt("LOG_SAMPLING_THEREAFTER", 100),
+		LogSamplingInterval:   getEnvDuration("LOG_SAMPLING_INTERVAL", time.Second),
+
+		AccessLogExcludePaths: getEnvList("ACCESS_LOG_EXCLUDE_PATHS", []string{"/health", "/livez", "/readyz", "/swagger/*"}),
+
+		StripeWebhookPreviousSecrets: getEnvList("STRIPE_WEBHOOK_PREVIOUS_SECRETS", nil),
+		StripeWebhookTolerance:       getEnvDuration("STRIPE_WEBHOOK_TOLERANCE", 5*time.Minute),
+
+		PayPalWebhookTolerance: getEnvDuration("PAYPAL_WEBHOOK_TOLERANCE", 5*time.Minute),
+
+		WebhookEventStore:     getEnv("WEBHOOK_EVENT_STORE", "memory"),
+		WebhookEventLease:     getEnvDuration("WEBHOOK_EVENT_LEASE", 30*time.Second),
+		WebhookEventRetention: getEnvDuration("WEBHOOK_EVENT_RETENTION", 72*time.Hour),
+
+		WebhookQueueDir:               getEnv("WEBHOOK_QUEUE_DIR", "data/webhook-queue"),
+		WebhookQueueRequirePersistent: getEnvBool("WEBHOOK_QUEUE_REQUIRE_PERSISTENT", false),
+		WebhookQueueWorkers:           getEnvInt("WEBHOOK_QUEUE_WORKERS", 4),
+		WebhookQueueMaxAttempts:       getEnvInt("WEBHOOK_QUEUE_MAX_ATTEMPTS", 10),
+		WebhookQueueInitialBackoff:    getEnvDuration("WEBHOOK_QUEUE_INITIAL_BACKOFF", 5*time.Second),
+		WebhookQueueMaxBackoff:        getEnvDuration("WEBHOOK_QUEUE_MAX_BACKOFF", 30*time.Minute),
 	}
 }
 
@@ -45,3 +240,69 @@ func getEnv(key, defaultValue string) string {
 	}
 	return value
 }
+
+func getEnvInt(key string, defaultValue int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
+func getEnvFloat(key string, defaultValue float64) float64 {
+	value, err := strconv.ParseFloat(os.Getenv(key), 64)
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
+func getEnvBool(key string, defaultValue bool) bool {
+	value, err := strconv.ParseBool(os.Getenv(key))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	value, err := time.ParseDuration(os.Getenv(key))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
+func getEnvList(key string, defaultValue []string) []string {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	var values []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			values = append(values, item)
+		}
+	}
+	return values
+}
+
+// getEnvDurationMap parses a comma separated list of key=duration pairs, skipping invalid entries
+func getEnvDurationMap(key string) map[string]time.Duration {
+	values := make(map[string]time.Duration)
+	for _, pair := range strings.Split(os.Getenv(key), ",") {
+		name, raw, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok {
+			continue
+		}
+
+		value, err := time.ParseDuration(strings.TrimSpace(raw))
+		if err != nil {
+			log.Printf("Ignoring invalid duration %q for %s in %s", raw, name, key)
+			continue
+		}
+		values[strings.TrimSpace(name)] = value
+	}
+	return values
+}
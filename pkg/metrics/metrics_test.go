@@ -0,0 +1,46 @@
+package metrics
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestHandler(t *testing.T) {
+	requests := Factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "test_requests_total",
+		Help: "Requests made by the test.",
+	}, []string{"route"})
+	defer Registry.Unregister(requests)
+	requests.WithLabelValues("/orders/:id").Add(2)
+
+	server := httptest.NewServer(Handler())
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatalf("scrape: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("read scrape: %v", err)
+	}
+
+	for _, want := range []string{
+		`test_requests_total{route="/orders/:id"} 2`,
+		"go_goroutines ",
+		"go_memstats_heap_alloc_bytes ",
+		"process_cpu_seconds_total ",
+		"process_open_fds ",
+	} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("scrape does not contain %q", want)
+		}
+	}
+}
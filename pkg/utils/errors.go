@@ -1,16 +1,22 @@
 package utils
 
 import (
+	"math"
 	"net/http"
+	"strconv"
 
 	"github.com/PharmaKart/gateway-svc/internal/proto"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 // ErrorResponse represents an error response
 type ErrorResponse struct {
-	Type    string            `json:"type"`
-	Message string            `json:"message"`
-	Details map[string]string `json:"details,omitempty"`
+	Type      string            `json:"type"`
+	Message   string            `json:"message"`
+	Details   map[string]string `json:"details,omitempty"`
+	RequestID string            `json:"request_id,omitempty"`
 }
 
 // Convert proto error to HTTP response
@@ -47,3 +53,115 @@ func ConvertProtoErrorToResponse(protoError *proto.Error) (ErrorResponse, int) {
 
 	return errorResp, statusCode
 }
+
+// StatusClientClosedRequest is returned when the client went away before the backend answered
+const StatusClientClosedRequest = 499
+
+// ConvertGrpcErrorToResponse converts an error returned by a gRPC client call to an HTTP response.
+// Messages of server-side failures are replaced by fallbackMessage so that backend internals do not leak.
+func ConvertGrpcErrorToResponse(err error, fallbackMessage string) (ErrorResponse, int) {
+	st, ok := status.FromError(err)
+	if !ok {
+		return ErrorResponse{
+			Type:    "INTERNAL_ERROR",
+			Message: fallbackMessage,
+		}, http.StatusInternalServerError
+	}
+
+	errorResp := ErrorResponse{
+		Message: st.Message(),
+	}
+
+	var statusCode int
+	switch st.Code() {
+	case codes.InvalidArgument, codes.OutOfRange:
+		errorResp.Type = "VALIDATION_ERROR"
+		statusCode = http.StatusBadRequest
+	case codes.FailedPrecondition:
+		errorResp.Type = "BAD_REQUEST_ERROR"
+		statusCode = http.StatusBadRequest
+	case codes.NotFound:
+		errorResp.Type = "NOT_FOUND_ERROR"
+		statusCode = http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		errorResp.Type = "CONFLICT_ERROR"
+		statusCode = http.StatusConflict
+	case codes.Unauthenticated:
+		errorResp.Type = "AUTH_ERROR"
+		statusCode = http.StatusUnauthorized
+	case codes.PermissionDenied:
+		errorResp.Type = "AUTH_ERROR"
+		statusCode = http.StatusForbidden
+	case codes.ResourceExhausted:
+		errorResp.Type = "RATE_LIMIT_ERROR"
+		statusCode = http.StatusTooManyRequests
+	case codes.Canceled:
+		errorResp.Type = "REQUEST_CANCELLED"
+		errorResp.Message = "Request was cancelled"
+		statusCode = StatusClientClosedRequest
+	case codes.Unavailable:
+		errorResp.Type = "SERVICE_UNAVAILABLE"
+		errorResp.Message = fallbackMessage
+		statusCode = http.StatusServiceUnavailable
+	case codes.DeadlineExceeded:
+		errorResp.Type = "GATEWAY_TIMEOUT"
+		errorResp.Message = fallbackMessage
+		statusCode = http.StatusGatewayTimeout
+	case codes.Unimplemented:
+		errorResp.Type = "NOT_IMPLEMENTED"
+		errorResp.Message = fallbackMessage
+		statusCode = http.StatusNotImplemented
+	default:
+		errorResp.Type = "INTERNAL_ERROR"
+		errorResp.Message = fallbackMessage
+		statusCode = http.StatusInternalServerError
+	}
+
+	// Details of server-side failures describe backend internals, so they are only forwarded for errors
+	// the client can act on
+	if statusCode != http.StatusInternalServerError {
+		errorResp.Details = convertStatusDetails(st)
+	}
+
+	return errorResp, statusCode
+}
+
+// convertStatusDetails flattens rich error details into the response details map
+func convertStatusDetails(st *status.Status) map[string]string {
+	details := make(map[string]string)
+
+	for _, detail := range st.Details() {
+		switch d := detail.(type) {
+		case *errdetails.BadRequest:
+			for _, violation := range d.GetFieldViolations() {
+				details[violation.GetField()] = violation.GetDescription()
+			}
+		case *errdetails.PreconditionFailure:
+			for _, violation := range d.GetViolations() {
+				details[violation.GetSubject()] = violation.GetDescription()
+			}
+		case *errdetails.ErrorInfo:
+			details["reason"] = d.GetReason()
+			for key, value := range d.GetMetadata() {
+				details[key] = value
+			}
+		case *errdetails.ResourceInfo:
+			details["resource_type"] = d.GetResourceType()
+			details["resource_name"] = d.GetResourceName()
+		case *errdetails.RetryInfo:
+			if delay := d.GetRetryDelay(); delay != nil {
+				details["retry_after"] = strconv.Itoa(int(math.Ceil(delay.AsDuration().Seconds())))
+			}
+		case *errdetails.QuotaFailure:
+			for _, violation := range d.GetViolations() {
+				details[violation.GetSubject()] = violation.GetDescription()
+			}
+		}
+	}
+
+	if len(details) == 0 {
+		return nil
+	}
+
+	return details
+}
@@ -0,0 +1,168 @@
+package utils
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/protoadapt"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+func statusError(t *testing.T, code codes.Code, message string, details ...protoadapt.MessageV1) error {
+	t.Helper()
+
+	st, err := status.New(code, message).WithDetails(details...)
+	if err != nil {
+		t.Fatalf("add status details: %v", err)
+	}
+	return st.Err()
+}
+
+func TestConvertGrpcErrorToResponse(t *testing.T) {
+	errorInfo := &errdetails.ErrorInfo{
+		Reason:   "ORDER_LOCKED",
+		Metadata: map[string]string{"order_id": "o1"},
+	}
+	internalInfo := &errdetails.ErrorInfo{
+		Reason:   "DB_ERROR",
+		Metadata: map[string]string{"query": "SELECT * FROM orders"},
+	}
+
+	tests := []struct {
+		name        string
+		err         error
+		wantStatus  int
+		wantType    string
+		wantMessage string
+		wantDetails map[string]string
+	}{
+		{
+			name: "invalid argument with field violations",
+			err: statusError(t, codes.InvalidArgument, "invalid order", &errdetails.BadRequest{
+				FieldViolations: []*errdetails.BadRequest_FieldViolation{{Field: "quantity", Description: "must be positive"}},
+			}),
+			wantStatus:  http.StatusBadRequest,
+			wantType:    "VALIDATION_ERROR",
+			wantMessage: "invalid order",
+			wantDetails: map[string]string{"quantity": "must be positive"},
+		},
+		{
+			name:        "failed precondition with error info",
+			err:         statusError(t, codes.FailedPrecondition, "order is locked", errorInfo),
+			wantStatus:  http.StatusBadRequest,
+			wantType:    "BAD_REQUEST_ERROR",
+			wantMessage: "order is locked",
+			wantDetails: map[string]string{"reason": "ORDER_LOCKED", "order_id": "o1"},
+		},
+		{
+			name:        "not found",
+			err:         statusError(t, codes.NotFound, "order not found"),
+			wantStatus:  http.StatusNotFound,
+			wantType:    "NOT_FOUND_ERROR",
+			wantMessage: "order not found",
+		},
+		{
+			name:        "already exists",
+			err:         statusError(t, codes.AlreadyExists, "duplicate"),
+			wantStatus:  http.StatusConflict,
+			wantType:    "CONFLICT_ERROR",
+			wantMessage: "duplicate",
+		},
+		{
+			name:        "unauthenticated",
+			err:         statusError(t, codes.Unauthenticated, "bad token"),
+			wantStatus:  http.StatusUnauthorized,
+			wantType:    "AUTH_ERROR",
+			wantMessage: "bad token",
+		},
+		{
+			name:        "permission denied",
+			err:         statusError(t, codes.PermissionDenied, "not yours"),
+			wantStatus:  http.StatusForbidden,
+			wantType:    "AUTH_ERROR",
+			wantMessage: "not yours",
+		},
+		{
+			name:        "resource exhausted with retry info",
+			err:         statusError(t, codes.ResourceExhausted, "slow down", &errdetails.RetryInfo{RetryDelay: durationpb.New(1500 * time.Millisecond)}),
+			wantStatus:  http.StatusTooManyRequests,
+			wantType:    "RATE_LIMIT_ERROR",
+			wantMessage: "slow down",
+			wantDetails: map[string]string{"retry_after": "2"},
+		},
+		{
+			name:        "cancelled",
+			err:         statusError(t, codes.Canceled, "context canceled"),
+			wantStatus:  StatusClientClosedRequest,
+			wantType:    "REQUEST_CANCELLED",
+			wantMessage: "Request was cancelled",
+		},
+		{
+			name:        "unavailable",
+			err:         statusError(t, codes.Unavailable, "connection refused to 10.0.0.5"),
+			wantStatus:  http.StatusServiceUnavailable,
+			wantType:    "SERVICE_UNAVAILABLE",
+			wantMessage: "Failed to place order",
+		},
+		{
+			name:        "deadline exceeded",
+			err:         statusError(t, codes.DeadlineExceeded, "deadline exceeded"),
+			wantStatus:  http.StatusGatewayTimeout,
+			wantType:    "GATEWAY_TIMEOUT",
+			wantMessage: "Failed to place order",
+		},
+		{
+			name:        "internal drops details",
+			err:         statusError(t, codes.Internal, "pq: relation does not exist", internalInfo),
+			wantStatus:  http.StatusInternalServerError,
+			wantType:    "INTERNAL_ERROR",
+			wantMessage: "Failed to place order",
+		},
+		{
+			name:        "unknown drops details",
+			err:         statusError(t, codes.Unknown, "panic: nil map", internalInfo),
+			wantStatus:  http.StatusInternalServerError,
+			wantType:    "INTERNAL_ERROR",
+			wantMessage: "Failed to place order",
+		},
+		{
+			name:        "data loss drops details",
+			err:         statusError(t, codes.DataLoss, "corrupt row", internalInfo),
+			wantStatus:  http.StatusInternalServerError,
+			wantType:    "INTERNAL_ERROR",
+			wantMessage: "Failed to place order",
+		},
+		{
+			name:        "not a status error",
+			err:         errors.New("dial tcp: connection refused"),
+			wantStatus:  http.StatusInternalServerError,
+			wantType:    "INTERNAL_ERROR",
+			wantMessage: "Failed to place order",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, statusCode := ConvertGrpcErrorToResponse(tt.err, "Failed to place order")
+
+			if statusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", statusCode, tt.wantStatus)
+			}
+			if resp.Type != tt.wantType {
+				t.Errorf("type = %q, want %q", resp.Type, tt.wantType)
+			}
+			if resp.Message != tt.wantMessage {
+				t.Errorf("message = %q, want %q", resp.Message, tt.wantMessage)
+			}
+			if !reflect.DeepEqual(resp.Details, tt.wantDetails) {
+				t.Errorf("details = %v, want %v", resp.Details, tt.wantDetails)
+			}
+		})
+	}
+}
@@ -1,30 +1,165 @@
 package utils
 
 import (
+	"context"
 	"os"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/PharmaKart/gateway-svc/pkg/config"
 	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/trace"
 )
 
-var Logger *logrus.Logger
+var (
+	Logger  *logrus.Logger
+	sampler *logSampler
+)
 
-func InitLogger() {
+// InitLogger configures the level, format and sampling of the logger from cfg.
+// Every entry is redacted before it is written.
+func InitLogger(cfg *config.Config) {
 	Logger = logrus.New()
-	Logger.SetLevel(logrus.DebugLevel)
-	Logger.SetFormatter(&logrus.JSONFormatter{
-		PrettyPrint: true,
-	})
 	Logger.SetOutput(os.Stdout)
+	Logger.AddHook(contextHook{})
+
+	level, err := logrus.ParseLevel(cfg.LogLevel)
+	if err != nil {
+		level = logrus.InfoLevel
+	}
+	Logger.SetLevel(level)
+
+	var formatter logrus.Formatter = &logrus.JSONFormatter{}
+	if strings.EqualFold(cfg.LogFormat, "text") {
+		formatter = &logrus.TextFormatter{FullTimestamp: true}
+	}
+	Logger.SetFormatter(&redactingFormatter{next: formatter})
+
+	sampler = newLogSampler(cfg.LogSamplingInitial, cfg.LogSamplingThereafter, cfg.LogSamplingInterval)
+
+	if err != nil {
+		Warn("Unknown log level, using info", map[string]interface{}{
+			"log_level": cfg.LogLevel,
+		})
+	}
+}
+
+func Debug(message string, fields map[string]interface{}) {
+	logEntry(nil, logrus.DebugLevel, message, fields)
 }
 
 func Info(message string, fields map[string]interface{}) {
-	Logger.WithFields(fields).Info(message)
+	logEntry(nil, logrus.InfoLevel, message, fields)
 }
 
 func Warn(message string, fields map[string]interface{}) {
-	Logger.WithFields(fields).Warn(message)
+	logEntry(nil, logrus.WarnLevel, message, fields)
 }
 
 func Error(message string, fields map[string]interface{}) {
-	Logger.WithFields(fields).Error(message)
+	logEntry(nil, logrus.ErrorLevel, message, fields)
+}
+
+// DebugContext logs a debug message, tagged with the request and trace IDs carried by ctx
+func DebugContext(ctx context.Context, message string, fields map[string]interface{}) {
+	logEntry(ctx, logrus.DebugLevel, message, fields)
+}
+
+// InfoContext logs an info message, tagged with the request and trace IDs carried by ctx
+func InfoContext(ctx context.Context, message string, fields map[string]interface{}) {
+	logEntry(ctx, logrus.InfoLevel, message, fields)
+}
+
+// WarnContext logs a warning, tagged with the request and trace IDs carried by ctx
+func WarnContext(ctx context.Context, message string, fields map[string]interface{}) {
+	logEntry(ctx, logrus.WarnLevel, message, fields)
+}
+
+// ErrorContext logs an error, tagged with the request and trace IDs carried by ctx
+func ErrorContext(ctx context.Context, message string, fields map[string]interface{}) {
+	logEntry(ctx, logrus.ErrorLevel, message, fields)
+}
+
+func logEntry(ctx context.Context, level logrus.Level, message string, fields map[string]interface{}) {
+	if !Logger.IsLevelEnabled(level) || !sampler.allow(level, message) {
+		return
+	}
+
+	entry := Logger.WithFields(fields)
+	if ctx != nil {
+		entry = entry.WithContext(ctx)
+	}
+	entry.Log(level, message)
+}
+
+// contextHook adds the request and trace IDs to entries logged with a request context
+type contextHook struct{}
+
+func (contextHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (contextHook) Fire(entry *logrus.Entry) error {
+	if entry.Context == nil {
+		return nil
+	}
+
+	if requestID := RequestIDFromContext(entry.Context); requestID != "" {
+		entry.Data["request_id"] = requestID
+	}
+	if spanContext := trace.SpanContextFromContext(entry.Context); spanContext.IsValid() {
+		entry.Data["trace_id"] = spanContext.TraceID().String()
+		entry.Data["span_id"] = spanContext.SpanID().String()
+	}
+	return nil
+}
+
+// logSampler keeps the first initial entries with the same level and message in each interval, and
+// every thereafter-th entry after that. Errors are never sampled.
+type logSampler struct {
+	initial    int
+	thereafter int
+	interval   time.Duration
+
+	mu          sync.Mutex
+	windowStart time.Time
+	counts      map[string]int
+}
+
+func newLogSampler(initial, thereafter int, interval time.Duration) *logSampler {
+	if initial <= 0 || interval <= 0 {
+		return nil
+	}
+
+	return &logSampler{
+		initial:    initial,
+		thereafter: thereafter,
+		interval:   interval,
+		counts:     make(map[string]int),
+	}
+}
+
+func (s *logSampler) allow(level logrus.Level, message string) bool {
+	if s == nil || level <= logrus.ErrorLevel {
+		return true
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(s.windowStart) >= s.interval {
+		s.windowStart = now
+		s.counts = make(map[string]int)
+	}
+
+	key := level.String() + "|" + message
+	s.counts[key]++
+	count := s.counts[key]
+
+	if count <= s.initial {
+		return true
+	}
+	return s.thereafter > 0 && (count-s.initial)%s.thereafter == 0
 }
@@ -0,0 +1,183 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PharmaKart/gateway-svc/pkg/config"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// captureLogs initializes the logger from cfg and returns the buffer it writes to
+func captureLogs(t *testing.T, cfg *config.Config) *bytes.Buffer {
+	t.Helper()
+
+	InitLogger(cfg)
+	var out bytes.Buffer
+	Logger.SetOutput(&out)
+	return &out
+}
+
+func decodeEntries(t *testing.T, out *bytes.Buffer) []map[string]interface{} {
+	t.Helper()
+
+	var entries []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var entry map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("decode entry %q: %v", line, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func TestLoggerRedactsFields(t *testing.T) {
+	out := captureLogs(t, &config.Config{LogLevel: "info"})
+
+	type customer struct {
+		Email string `json:"email"`
+		Phone string `json:"phone_number"`
+		Name  string `json:"name"`
+	}
+
+	Info("Contact jane.doe@example.com about https://cdn.test/prescriptions/1.pdf", map[string]interface{}{
+		"password":      "hunter2",
+		"Authorization": "Bearer abc",
+		"refresh_token": "refresh",
+		"date_of_birth": "1990-01-01",
+		"email":         "jane.doe@example.com",
+		"phone":         "+1 555 123 4567",
+		"prescription":  "https://cdn.test/p/1.pdf",
+		"customer":      customer{Email: "john@example.com", Phone: "5551234", Name: "John"},
+		"nested":        map[string]interface{}{"api_key": "key", "note": "mail bob@example.org"},
+		"order_id":      "order-1",
+	})
+
+	entries := decodeEntries(t, out)
+	if len(entries) != 1 {
+		t.Fatalf("logged %d entries, want 1", len(entries))
+	}
+	entry := entries[0]
+
+	want := map[string]interface{}{
+		"msg":           "Contact j***@example.com about [REDACTED]",
+		"password":      redacted,
+		"Authorization": redacted,
+		"refresh_token": redacted,
+		"date_of_birth": redacted,
+		"email":         "j***@example.com",
+		"phone":         "***67",
+		"prescription":  redacted,
+		"order_id":      "order-1",
+	}
+	for key, value := range want {
+		if entry[key] != value {
+			t.Errorf("%s = %v, want %v", key, entry[key], value)
+		}
+	}
+
+	c, _ := entry["customer"].(map[string]interface{})
+	if c["email"] != "j***@example.com" || c["phone_number"] != "***34" || c["name"] != "John" {
+		t.Errorf("customer = %v, want masked email and phone", c)
+	}
+	n, _ := entry["nested"].(map[string]interface{})
+	if n["api_key"] != redacted || n["note"] != "mail b***@example.org" {
+		t.Errorf("nested = %v, want redacted key and masked email", n)
+	}
+}
+
+func TestLoggerLevelAndFormat(t *testing.T) {
+	out := captureLogs(t, &config.Config{LogLevel: "warn", LogFormat: "text"})
+
+	Info("dropped", nil)
+	Warn("kept", map[string]interface{}{"token": "secret-value"})
+
+	logged := out.String()
+	if strings.Contains(logged, "dropped") {
+		t.Error("info entry logged at warn level")
+	}
+	if !strings.Contains(logged, "msg=kept") || !strings.Contains(logged, `token="`+redacted+`"`) {
+		t.Errorf("text output = %q, want the redacted warning", logged)
+	}
+	if strings.Contains(logged, "secret-value") {
+		t.Error("secret logged in text format")
+	}
+}
+
+func TestLoggerFallsBackToInfoOnUnknownLevel(t *testing.T) {
+	out := captureLogs(t, &config.Config{LogLevel: "verbose"})
+
+	Debug("dropped", nil)
+	Info("kept", nil)
+
+	entries := decodeEntries(t, out)
+	if len(entries) != 1 || entries[0]["msg"] != "kept" {
+		t.Fatalf("entries = %v, want only the info entry", entries)
+	}
+}
+
+func TestLoggerAddsContextIDs(t *testing.T) {
+	out := captureLogs(t, &config.Config{LogLevel: "info"})
+
+	traceID, _ := trace.TraceIDFromHex("0af7651916cd43dd8448eb211c80319c")
+	spanID, _ := trace.SpanIDFromHex("b7ad6b7169203331")
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     spanID,
+		TraceFlags: trace.FlagsSampled,
+	}))
+	ctx = ContextWithRequestID(ctx, "req-1")
+
+	InfoContext(ctx, "with context", nil)
+	Info("without context", nil)
+
+	entries := decodeEntries(t, out)
+	if len(entries) != 2 {
+		t.Fatalf("logged %d entries, want 2", len(entries))
+	}
+	if entries[0]["request_id"] != "req-1" || entries[0]["trace_id"] != traceID.String() || entries[0]["span_id"] != spanID.String() {
+		t.Errorf("entry = %v, want request, trace and span IDs", entries[0])
+	}
+	if _, ok := entries[1]["request_id"]; ok {
+		t.Errorf("entry without context has a request ID: %v", entries[1])
+	}
+}
+
+func TestLoggerSampling(t *testing.T) {
+	out := captureLogs(t, &config.Config{
+		LogLevel:              "info",
+		LogSamplingInitial:    2,
+		LogSamplingThereafter: 3,
+		LogSamplingInterval:   time.Hour,
+	})
+
+	for i := 0; i < 10; i++ {
+		Info("repeated", nil)
+		Error("failure", nil)
+	}
+	Info("other", nil)
+
+	counts := make(map[string]int)
+	for _, entry := range decodeEntries(t, out) {
+		counts[entry["msg"].(string)]++
+	}
+
+	// The first 2, then the 5th and 8th of the remaining 8
+	if counts["repeated"] != 4 {
+		t.Errorf("logged %d repeated entries, want 4", counts["repeated"])
+	}
+	if counts["failure"] != 10 {
+		t.Errorf("logged %d errors, want all 10", counts["failure"])
+	}
+	if counts["other"] != 1 {
+		t.Errorf("logged %d other entries, want 1", counts["other"])
+	}
+}
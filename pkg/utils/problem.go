@@ -0,0 +1,114 @@
+package utils
+
+import (
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// ErrorFormatLegacy writes errors as ErrorResponse objects
+	ErrorFormatLegacy = "legacy"
+	// ErrorFormatProblem writes errors as RFC 7807 problem details
+	ErrorFormatProblem = "problem"
+
+	problemContentType = "application/problem+json"
+)
+
+var (
+	defaultErrorFormat = ErrorFormatLegacy
+	problemTypeBaseURI = "https://api.pharmakart.com/problems/"
+)
+
+// ProblemDetails is an RFC 7807 problem details object
+type ProblemDetails struct {
+	Type      string            `json:"type"`
+	Title     string            `json:"title"`
+	Status    int               `json:"status"`
+	Detail    string            `json:"detail,omitempty"`
+	Instance  string            `json:"instance,omitempty"`
+	Code      string            `json:"code"`
+	Errors    map[string]string `json:"errors,omitempty"`
+	RequestID string            `json:"request_id,omitempty"`
+	TraceID   string            `json:"trace_id,omitempty"`
+}
+
+// InitErrorFormat sets the error format used when the client does not ask for one,
+// and the base URI that problem types are resolved against
+func InitErrorFormat(format, typeBaseURI string) {
+	if format == ErrorFormatProblem {
+		defaultErrorFormat = ErrorFormatProblem
+	}
+	if typeBaseURI != "" {
+		problemTypeBaseURI = strings.TrimSuffix(typeBaseURI, "/") + "/"
+	}
+}
+
+// ConvertErrorResponseToProblem converts an error response to RFC 7807 problem details
+func ConvertErrorResponseToProblem(c *gin.Context, statusCode int, errorResp ErrorResponse) ProblemDetails {
+	slug := strings.ReplaceAll(strings.ToLower(errorResp.Type), "_", "-")
+
+	words := strings.Split(strings.ToLower(errorResp.Type), "_")
+	for i, word := range words {
+		if word != "" {
+			words[i] = strings.ToUpper(word[:1]) + word[1:]
+		}
+	}
+
+	requestID := errorResp.RequestID
+	if requestID == "" {
+		requestID = c.GetHeader(RequestIDHeader)
+	}
+
+	var traceID string
+	if spanContext := trace.SpanContextFromContext(c.Request.Context()); spanContext.IsValid() {
+		traceID = spanContext.TraceID().String()
+	}
+
+	return ProblemDetails{
+		Type:      problemTypeBaseURI + slug,
+		Title:     strings.Join(words, " "),
+		Status:    statusCode,
+		Detail:    errorResp.Message,
+		Instance:  c.Request.URL.Path,
+		Code:      errorResp.Type,
+		Errors:    errorResp.Details,
+		RequestID: requestID,
+		TraceID:   traceID,
+	}
+}
+
+// WriteError writes an error response in the format negotiated with the client
+func WriteError(c *gin.Context, statusCode int, errorResp ErrorResponse) {
+	if errorResp.RequestID == "" {
+		errorResp.RequestID = c.GetString("request_id")
+	}
+
+	if !wantsProblem(c) {
+		c.JSON(statusCode, errorResp)
+		return
+	}
+
+	// gin keeps an explicitly set content type when rendering JSON
+	c.Header("Content-Type", problemContentType)
+	c.JSON(statusCode, ConvertErrorResponseToProblem(c, statusCode, errorResp))
+}
+
+// AbortWithError writes an error response and stops the handler chain
+func AbortWithError(c *gin.Context, statusCode int, errorResp ErrorResponse) {
+	WriteError(c, statusCode, errorResp)
+	c.Abort()
+}
+
+// wantsProblem reports whether problem details should be written for this request
+func wantsProblem(c *gin.Context) bool {
+	accept := c.GetHeader("Accept")
+	if strings.Contains(accept, problemContentType) {
+		return true
+	}
+	if strings.Contains(accept, "application/json") {
+		return false
+	}
+	return defaultErrorFormat == ErrorFormatProblem
+}
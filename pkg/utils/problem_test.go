@@ -0,0 +1,128 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestWriteErrorNegotiatesFormat(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name          string
+		defaultFormat string
+		accept        string
+		wantProblem   bool
+	}{
+		{"legacy by default", ErrorFormatLegacy, "", false},
+		{"problem requested", ErrorFormatLegacy, "application/problem+json", true},
+		{"problem preferred over json", ErrorFormatLegacy, "application/problem+json, application/json", true},
+		{"problem by default", ErrorFormatProblem, "", true},
+		{"json requested with problem default", ErrorFormatProblem, "application/json", false},
+		{"wildcard uses the default", ErrorFormatProblem, "*/*", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func(format, base string) {
+				defaultErrorFormat, problemTypeBaseURI = format, base
+			}(defaultErrorFormat, problemTypeBaseURI)
+			defaultErrorFormat = ErrorFormatLegacy
+			InitErrorFormat(tt.defaultFormat, "https://errors.test/problems")
+
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodPost, "/api/v1/orders", nil)
+			if tt.accept != "" {
+				c.Request.Header.Set("Accept", tt.accept)
+			}
+			c.Set("request_id", "req-1")
+
+			WriteError(c, http.StatusBadRequest, ErrorResponse{
+				Type:    "VALIDATION_ERROR",
+				Message: "invalid order",
+				Details: map[string]string{"quantity": "must be positive"},
+			})
+
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("status = %d, want %d", w.Code, http.StatusBadRequest)
+			}
+
+			if !tt.wantProblem {
+				if contentType := w.Header().Get("Content-Type"); contentType != "application/json; charset=utf-8" {
+					t.Fatalf("content type = %q, want JSON", contentType)
+				}
+				var resp ErrorResponse
+				if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+					t.Fatalf("decode response: %v", err)
+				}
+				if resp.Type != "VALIDATION_ERROR" || resp.RequestID != "req-1" {
+					t.Fatalf("response = %+v", resp)
+				}
+				return
+			}
+
+			if contentType := w.Header().Get("Content-Type"); contentType != "application/problem+json" {
+				t.Fatalf("content type = %q, want application/problem+json", contentType)
+			}
+			var problem ProblemDetails
+			if err := json.Unmarshal(w.Body.Bytes(), &problem); err != nil {
+				t.Fatalf("decode problem: %v", err)
+			}
+			want := ProblemDetails{
+				Type:      "https://errors.test/problems/validation-error",
+				Title:     "Validation Error",
+				Status:    http.StatusBadRequest,
+				Detail:    "invalid order",
+				Instance:  "/api/v1/orders",
+				Code:      "VALIDATION_ERROR",
+				Errors:    map[string]string{"quantity": "must be positive"},
+				RequestID: "req-1",
+			}
+			if !reflect.DeepEqual(problem, want) {
+				t.Fatalf("problem = %+v, want %+v", problem, want)
+			}
+		})
+	}
+}
+
+func TestProblemCarriesTraceID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	traceID, _ := trace.TraceIDFromHex("0af7651916cd43dd8448eb211c80319c")
+	spanID, _ := trace.SpanIDFromHex("b7ad6b7169203331")
+	spanContext := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     spanID,
+		TraceFlags: trace.FlagsSampled,
+	})
+
+	tests := []struct {
+		name        string
+		ctx         context.Context
+		wantTraceID string
+	}{
+		{"traced request", trace.ContextWithSpanContext(context.Background(), spanContext), traceID.String()},
+		{"untraced request", context.Background(), ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodGet, "/api/v1/orders", nil).WithContext(tt.ctx)
+			c.Request.Header.Set(RequestIDHeader, "req-1")
+
+			problem := ConvertErrorResponseToProblem(c, http.StatusNotFound, ErrorResponse{Type: "NOT_FOUND"})
+			if problem.RequestID != "req-1" || problem.TraceID != tt.wantTraceID {
+				t.Fatalf("request_id, trace_id = %q, %q, want %q, %q", problem.RequestID, problem.TraceID, "req-1", tt.wantTraceID)
+			}
+		})
+	}
+}
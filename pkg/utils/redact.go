@@ -0,0 +1,132 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const redacted = "[REDACTED]"
+
+var (
+	emailPattern           = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+	prescriptionURLPattern = regexp.MustCompile(`(?i)https?://\S*prescription\S*`)
+)
+
+// secretKeys are field names whose values are never logged
+var secretKeys = []string{"password", "token", "secret", "authorization", "apikey", "cookie", "dob", "dateofbirth", "birthdate"}
+
+// redactingFormatter masks credentials and personal data in an entry before passing it to the next formatter
+type redactingFormatter struct {
+	next logrus.Formatter
+}
+
+func (f *redactingFormatter) Format(entry *logrus.Entry) ([]byte, error) {
+	clean := *entry
+	clean.Message = redactString(entry.Message)
+	clean.Data = make(logrus.Fields, len(entry.Data))
+	for key, value := range entry.Data {
+		clean.Data[key] = redactField(key, value)
+	}
+
+	return f.next.Format(&clean)
+}
+
+// redactField masks value according to the name of its field, then scans it for personal data
+func redactField(key string, value interface{}) interface{} {
+	if value == nil {
+		return nil
+	}
+
+	name := strings.NewReplacer("_", "", "-", "").Replace(strings.ToLower(key))
+	for _, secret := range secretKeys {
+		if strings.Contains(name, secret) {
+			return redacted
+		}
+	}
+
+	switch {
+	case strings.Contains(name, "email"):
+		if s, ok := value.(string); ok {
+			return maskEmail(s)
+		}
+	case strings.Contains(name, "phone") || strings.Contains(name, "mobile"):
+		if s, ok := value.(string); ok {
+			return maskPhone(s)
+		}
+	case strings.Contains(name, "prescription"):
+		if _, ok := value.(string); ok {
+			return redacted
+		}
+	}
+
+	return redactValue(value)
+}
+
+// redactValue scans strings for personal data and walks nested values. Structs such as gRPC responses
+// are converted to their JSON form so that their fields can be masked by name.
+func redactValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case nil, bool, int, int32, int64, uint, uint32, uint64, float32, float64, time.Time, time.Duration:
+		return v
+	case string:
+		return redactString(v)
+	case error:
+		return redactString(v.Error())
+	case map[string]interface{}:
+		clean := make(map[string]interface{}, len(v))
+		for key, nested := range v {
+			clean[key] = redactField(key, nested)
+		}
+		return clean
+	case []interface{}:
+		clean := make([]interface{}, len(v))
+		for i, nested := range v {
+			clean[i] = redactValue(nested)
+		}
+		return clean
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return redactString(fmt.Sprintf("%+v", value))
+	}
+
+	var generic interface{}
+	if err := json.Unmarshal(data, &generic); err != nil {
+		return redactString(string(data))
+	}
+	return redactValue(generic)
+}
+
+func redactString(s string) string {
+	s = prescriptionURLPattern.ReplaceAllString(s, redacted)
+	return emailPattern.ReplaceAllStringFunc(s, maskEmail)
+}
+
+// maskEmail keeps the first character of the local part and the domain
+func maskEmail(email string) string {
+	at := strings.LastIndex(email, "@")
+	if at <= 0 {
+		return redacted
+	}
+	return email[:1] + "***" + email[at:]
+}
+
+// maskPhone keeps the last two digits
+func maskPhone(phone string) string {
+	digits := make([]rune, 0, len(phone))
+	for _, r := range phone {
+		if r >= '0' && r <= '9' {
+			digits = append(digits, r)
+		}
+	}
+	if len(digits) <= 2 {
+		return redacted
+	}
+	return "***" + string(digits[len(digits)-2:])
+}
@@ -8,12 +8,20 @@ import (
 	"time"
 
 	"github.com/PharmaKart/gateway-svc/pkg/config"
+	"github.com/PharmaKart/gateway-svc/pkg/metrics"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
 )
 
+var s3UploadDuration = metrics.Factory.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "gateway_s3_upload_duration_seconds",
+	Help:    "Duration of image uploads to S3.",
+	Buckets: []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30},
+}, []string{"folder", "result"})
+
 func GetIntQueryParam(c *gin.Context, key string, defaultValue int) int {
 	value, err := strconv.Atoi(c.Query(key))
 	if err != nil {
@@ -52,6 +60,7 @@ func UploadImageToS3(c *gin.Context, cfg *config.Config, bucketFolder string, fi
 	// Upload the file to S3
 	s3Service := s3.New(sess)
 
+	start := time.Now()
 	_, err = s3Service.PutObject(&s3.PutObjectInput{
 		Bucket:      aws.String(bucket),
 		Key:         aws.String(fileName),
@@ -59,8 +68,10 @@ func UploadImageToS3(c *gin.Context, cfg *config.Config, bucketFolder string, fi
 		ContentType: aws.String(file.Header.Get("Content-Type")),
 	})
 	if err != nil {
+		s3UploadDuration.WithLabelValues(bucketFolder, "error").Observe(time.Since(start).Seconds())
 		return "", err
 	}
+	s3UploadDuration.WithLabelValues(bucketFolder, "success").Observe(time.Since(start).Seconds())
 
 	url := fmt.Sprintf("https://%s.s3.%s.amazonaws.com/%s", bucket, region, fileName)
 